@@ -0,0 +1,63 @@
+// Command iesinfo validates IES photometric files and
+// prints a summary of the intensity distribution they describe
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/robquant/tracer/pkg/ies"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: iesinfo file.ies...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	failed := false
+	for _, name := range flag.Args() {
+		if err := describe(name); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func describe(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	p, err := ies.Parse(in)
+	if err != nil {
+		return err
+	}
+	fmt.Println(name)
+	for _, key := range []string{"MANUFAC", "LUMCAT", "LUMINAIRE", "LAMP"} {
+		if v := p.Keyword(key); v != "" {
+			fmt.Printf("  %-10s %s\n", key, strings.ReplaceAll(v, "\n", "\n             "))
+		}
+	}
+	if lumens := p.Lumens(); lumens > 0 {
+		fmt.Printf("  lamp flux  %g lm\n", lumens)
+	} else {
+		fmt.Printf("  lamp flux  absolute photometry\n")
+	}
+	v, h := p.VerticalAngles(), p.HorizontalAngles()
+	fmt.Printf("  vertical   %d angles from %g to %g degrees\n", len(v), v[0], v[len(v)-1])
+	fmt.Printf("  horizontal %d angles from %g to %g degrees\n", len(h), h[0], h[len(h)-1])
+	lo, hi := p.Range()
+	fmt.Printf("  candela    %g to %g\n", lo, hi)
+	return nil
+}
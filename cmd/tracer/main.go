@@ -1,69 +1,46 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"image"
-	"image/color"
-	"image/png"
 	"log"
-	"math"
 	"math/rand"
 	"os"
-	"runtime"
+	"os/signal"
 	"runtime/pprof"
-	"sync"
 	"time"
 
 	"github.com/chewxy/math32"
 	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/imageio"
+	"github.com/robquant/tracer/pkg/scene"
 	"github.com/robquant/tracer/pkg/tracer"
 )
 
-func colorAt(r *geo.Ray, world *tracer.BvhNode, maxDepth int, rng *rand.Rand) tracer.Color {
-	attenuation := tracer.NewColor(1, 1, 1)
-	currentRay := *r
-	var rec tracer.HitRecord
-	for depth := 0; depth < maxDepth; depth++ {
-		if !world.Hit(&currentRay, 0.001, math.MaxFloat32, &rec) {
-			break
-		}
-		ok, atten, scattered := rec.Material().Scatter(&currentRay, &rec, rng)
-		if !ok {
-			return tracer.Black
-		}
-		attenuation = attenuation.MulVec(atten)
-		currentRay = scattered
-	}
-	unitDirection := currentRay.Dir().Normed()
-	t := 0.5 * (unitDirection.Y() + 1.0)
-	c1 := geo.NewVec3(1.0, 1.0, 1.0).Mul(1.0 - t)
-	c2 := geo.NewVec3(0.5, 0.7, 1.0).Mul(t)
-	sky := tracer.Color{Vec3: c1.Add(c2)}
-	return attenuation.MulVec(sky.Vec3)
-}
-
-func randomMaterial() tracer.Material {
-	choose := rand.Float32()
+func randomMaterial(rng *rand.Rand) tracer.Material {
+	choose := rng.Float32()
 	if choose < 0.8 {
-		ar := rand.Float32() * rand.Float32()
-		ag := rand.Float32() * rand.Float32()
-		ab := rand.Float32() * rand.Float32()
+		ar := rng.Float32() * rng.Float32()
+		ag := rng.Float32() * rng.Float32()
+		ab := rng.Float32() * rng.Float32()
 		return tracer.NewLambertian(ar, ag, ab)
 	} else if choose < 0.95 {
-		return tracer.NewMetal(0.5*(1+rand.Float32()), 0.5*(1+rand.Float32()), 0.5*(1+rand.Float32()), 0.5*rand.Float32())
+		return tracer.NewMetal(0.5*(1+rng.Float32()), 0.5*(1+rng.Float32()), 0.5*(1+rng.Float32()), 0.5*rng.Float32())
 	}
 	return tracer.NewDielectric(1.5)
 }
 
-func randomScene() tracer.HitableList {
+func randomScene(rng *rand.Rand) tracer.HitableList {
 	scene := tracer.NewHitableList()
 	scene = append(scene, tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.5, 0.5, 0.5)))
 	for a := -11; a < 11; a++ {
 		for b := -11; b < 11; b++ {
-			center := geo.NewVec3(float32(a)+0.9*rand.Float32(), 0.2, float32(b)+rand.Float32())
+			center := geo.NewVec3(float32(a)+0.9*rng.Float32(), 0.2, float32(b)+rng.Float32())
 			if center.Sub(geo.NewVec3(4, 0.2, 0)).Len() > 0.9 {
-				scene = append(scene, tracer.NewSphere(center, 0.2, randomMaterial()))
+				scene = append(scene, tracer.NewSphere(center, 0.2, randomMaterial(rng)))
 			}
 		}
 	}
@@ -73,6 +50,43 @@ func randomScene() tracer.HitableList {
 	return scene
 }
 
+// randomSceneCamera returns a camera of type typ for the random scene,
+// fov zero selects the default field of view of the type
+func randomSceneCamera(aspectRatio float32, typ, projection string, fov float32) (tracer.Camera, error) {
+	radius := float32(15)
+	angle := 60.
+	lookAt := geo.NewVec3(0, 0, 0)
+	x := math32.Sin(float32(angle)*math32.Pi/180) * radius
+	z := math32.Cos(float32(angle)*math32.Pi/180) * radius
+	lookFrom := geo.NewVec3(x, 2., z)
+	if projection != "" && typ != "fisheye" {
+		return nil, errors.New("a projection only applies to fisheye cameras")
+	}
+	switch typ {
+	case "", "perspective":
+		distToFocus := float32(10.0)
+		aperture := float32(1 / 10.0)
+		return tracer.NewCamera(lookFrom, lookAt, geo.UnitY, cmp.Or(fov, 20), aspectRatio, aperture, distToFocus), nil
+	case "orthographic":
+		height := 2 * lookFrom.Sub(lookAt).Len() * math32.Tan(cmp.Or(fov, 20)*math32.Pi/360)
+		return tracer.NewOrthographicCamera(lookFrom, lookAt, geo.UnitY, height, aspectRatio), nil
+	case "fisheye":
+		p := tracer.FisheyeEquidistant
+		if projection != "" {
+			var err error
+			if p, err = tracer.ParseFisheyeProjection(projection); err != nil {
+				return nil, err
+			}
+		}
+		return tracer.NewFisheyeCamera(lookFrom, lookAt, geo.UnitY, cmp.Or(fov, 180), aspectRatio, p), nil
+	case "equirectangular":
+		return tracer.NewEquirectangularCamera(lookFrom, lookAt, geo.UnitY), nil
+	case "cylindrical":
+		return tracer.NewCylindricalCamera(lookFrom, lookAt, geo.UnitY, cmp.Or(fov, 360), aspectRatio), nil
+	}
+	return nil, fmt.Errorf("unknown camera %q", typ)
+}
+
 func main() {
 	if pr := os.Getenv("CPUPROFILE"); pr != "" {
 		p, err := os.Create(pr)
@@ -86,78 +100,106 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
+	defaults := tracer.DefaultRenderOptions()
 	var nx, ny, ns, np int
-	var outfname string
-	flag.IntVar(&nx, "nx", 600, "X resolution")
-	flag.IntVar(&ny, "ny", 400, "Y resolution")
-	flag.IntVar(&ns, "ns", 10, "samples per pixel")
-	flag.IntVar(&np, "np", runtime.NumCPU(), "number of parallel renderers")
-	flag.StringVar(&outfname, "out", "image.png", "output file name")
+	var seed int64
+	var spectral bool
+	var outfname, scenefname string
+	var cameraType, projection string
+	var fov float64
+	flag.IntVar(&nx, "nx", defaults.Width, "X resolution")
+	flag.IntVar(&ny, "ny", defaults.Height, "Y resolution")
+	flag.IntVar(&ns, "ns", defaults.Samples, "samples per pixel")
+	flag.IntVar(&np, "np", defaults.Workers, "number of parallel renderers")
+	flag.StringVar(&outfname, "out", "image.png", "output file name, the extension selects PNG, Radiance HDR or OpenEXR")
+	imgOpts := imageio.DefaultOptions()
+	exrFloat := flag.Bool("exrfloat", false, "write 32 bit float instead of half EXR channels")
+	exrRaw := flag.Bool("exrraw", false, "write uncompressed EXR files")
+	var exposure, whitePoint float64
+	var toneMap string
+	flag.Float64Var(&exposure, "exposure", 0, "exposure adjustment in stops for PNG output")
+	flag.StringVar(&toneMap, "tonemap", "linear", "tone mapping operator for PNG output: linear, reinhard, reinhard-extended, aces or hable")
+	flag.Float64Var(&whitePoint, "white", 0, "white point of the reinhard-extended and hable operators, 0 for their default")
+	flag.StringVar(&scenefname, "scene", "", "JSON scene file, renders a random scene if empty")
+	flag.StringVar(&cameraType, "camera", "", "camera type overriding the scene: perspective, orthographic, fisheye, equirectangular or cylindrical")
+	flag.StringVar(&projection, "projection", "", "projection of fisheye cameras: equidistant or equisolid")
+	flag.Float64Var(&fov, "fov", 0, "field of view in degrees overriding the scene, horizontal for cylindrical and diagonal for fisheye cameras")
+	flag.BoolVar(&spectral, "spectral", defaults.Spectral, "render wavelengths instead of RGB colors to show dispersion")
+	flag.Int64Var(&seed, "seed", defaults.Seed, "seed of the random scene and the sampling, equal seeds give identical images")
 	flag.Parse()
+	if *exrFloat {
+		imgOpts.EXRPixelType = imageio.Float
+	}
+	if *exrRaw {
+		imgOpts.EXRCompression = imageio.NoCompression
+	}
 
-	blockSize := 50
-
-	radius := float32(15)
-	world := tracer.NewBvhNodeFromList(randomScene())
-	angle := 60.
-	img := image.NewRGBA(image.Rect(0, 0, nx, ny))
-	lookAt := geo.NewVec3(0, 0, 0)
-	x := math32.Sin(float32(angle)*math32.Pi/180) * radius
-	z := math32.Cos(float32(angle)*math32.Pi/180) * radius
-	lookFrom := geo.NewVec3(x, 2., z)
-	distToFocus := float32(10.0)
-	aperture := float32(1 / 10.0)
-	camera := tracer.NewCamera(lookFrom, lookAt, geo.UnitY, 20, float32(nx)/float32(ny), aperture, distToFocus)
-
-	start := time.Now()
-	wg := sync.WaitGroup{}
-	blockQueue := make(chan image.Rectangle)
-	for cpu := 0; cpu < np; cpu++ {
-		wg.Add(1)
-		go func(queue <-chan image.Rectangle) {
-			randGen := rand.New(rand.NewSource(time.Now().UnixNano()))
-			for block := range queue {
-				for y := block.Min.Y; y < block.Max.Y; y++ {
-					for x := block.Min.X; x < block.Max.X; x++ {
-						col := tracer.NewColor(0, 0, 0)
-						for s := 0; s < ns; s++ {
-							u := (float32(x) + randGen.Float32()) / float32(nx)
-							v := (float32(ny-y) + randGen.Float32()) / float32(ny)
-							ray := camera.GetRay(u, v, randGen)
-							col = col.Add(colorAt(&ray, &world, 50, randGen))
-						}
-						col.Scale(1. / float32(ns))
-						col = tracer.NewColor(math32.Sqrt(col.R()), math32.Sqrt(col.G()), math32.Sqrt(col.B()))
-						ir := uint8(math.Round(float64(255 * col.R())))
-						ig := uint8(math.Round(float64(255 * col.G())))
-						ib := uint8(math.Round(float64(255 * col.B())))
-						img.SetRGBA(x, y, color.RGBA{ir, ig, ib, 255})
-					}
-				}
-			}
-			wg.Done()
-		}(blockQueue)
+	var sceneFile *scene.Scene
+	opts := defaults
+	if scenefname != "" {
+		var err error
+		if sceneFile, err = scene.Load(scenefname); err != nil {
+			log.Fatal(err)
+		}
+		opts = sceneFile.Options
+		imgOpts.Display = sceneFile.Display
 	}
-	for x := 0; x <= nx; x += blockSize {
-		for y := 0; y <= ny; y += blockSize {
-			r := image.Rect(x, y, min(x+blockSize, nx), min(y+blockSize, ny))
-			blockQueue <- r
+	// Flags given on the command line override the scene file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "nx":
+			opts.Width = nx
+		case "ny":
+			opts.Height = ny
+		case "ns":
+			opts.Samples = ns
+		case "np":
+			opts.Workers = np
+		case "seed":
+			opts.Seed = seed
+		case "spectral":
+			opts.Spectral = spectral
+		case "exposure":
+			imgOpts.Display.Exposure = float32(exposure)
+		case "white":
+			imgOpts.Display.WhitePoint = float32(whitePoint)
+		case "tonemap":
+			op, err := tracer.ParseToneOperator(toneMap)
+			if err != nil {
+				log.Fatal(err)
+			}
+			imgOpts.Display.Operator = op
+		}
+	})
+	var world *tracer.Scene
+	var camera tracer.Camera
+	var err error
+	aspectRatio := float32(opts.Width) / float32(opts.Height)
+	if sceneFile != nil {
+		if err := sceneFile.OverrideCamera(cameraType, projection, float32(fov)); err != nil {
+			log.Fatal(err)
+		}
+		world = sceneFile.Scene
+		if camera, err = sceneFile.Camera(aspectRatio); err != nil {
+			log.Fatal(err)
 		}
+	} else {
+		if camera, err = randomSceneCamera(aspectRatio, cameraType, projection, float32(fov)); err != nil {
+			log.Fatal(err)
+		}
+		world = tracer.NewScene(randomScene(tracer.NewRand(opts.Seed)))
 	}
-	close(blockQueue)
-	wg.Wait()
 
-	f, err := os.Create(outfname)
-	if err != nil {
-		log.Fatal(err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	if err := png.Encode(f, img); err != nil {
-		f.Close()
+	start := time.Now()
+	film, err := tracer.Render(ctx, world, camera, opts)
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := f.Close(); err != nil {
+	if err := imageio.Save(outfname, film, imgOpts); err != nil {
 		log.Fatal(err)
 	}
 	fmt.Printf("%s took %v\n", outfname, time.Since(start))
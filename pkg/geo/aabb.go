@@ -123,3 +123,17 @@ func (a *Aabb) Area() float32 {
 	size := a.max.Sub(a.min)
 	return 2 * (size.x*size.y + size.x*size.z + size.y*size.z)
 }
+
+// Padded returns a copy of a which is at least delta wide
+// along every axis, so that flat boxes can still be hit
+func (a *Aabb) Padded(delta float32) Aabb {
+	small := [3]float32{a.min.x, a.min.y, a.min.z}
+	big := [3]float32{a.max.x, a.max.y, a.max.z}
+	for i := range small {
+		if big[i]-small[i] < delta {
+			small[i] -= delta / 2
+			big[i] += delta / 2
+		}
+	}
+	return Aabb{NewVec3(small[0], small[1], small[2]), NewVec3(big[0], big[1], big[2])}
+}
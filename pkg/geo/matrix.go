@@ -0,0 +1,163 @@
+package geo
+
+import "github.com/chewxy/math32"
+
+// Mat4 is a 4x4 matrix in row major order used for affine transforms.
+// Points are treated as column vectors with an implicit w = 1.
+type Mat4 struct {
+	m [4][4]float32
+}
+
+// Identity returns the identity matrix
+func Identity() Mat4 {
+	return Mat4{[4][4]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}}
+}
+
+// NewMat4 constructs a Mat4 from its rows
+func NewMat4(rows [4][4]float32) Mat4 {
+	return Mat4{rows}
+}
+
+// Translation returns a matrix translating by t
+func Translation(t Vec3) Mat4 {
+	m := Identity()
+	m.m[0][3], m.m[1][3], m.m[2][3] = t.x, t.y, t.z
+	return m
+}
+
+// Scaling returns a matrix scaling each axis by the components of s
+func Scaling(s Vec3) Mat4 {
+	m := Identity()
+	m.m[0][0], m.m[1][1], m.m[2][2] = s.x, s.y, s.z
+	return m
+}
+
+// Rotation returns a matrix rotating counter clockwise
+// by degrees around axis, which need not be normalized
+func Rotation(axis Vec3, degrees float32) Mat4 {
+	a := axis.Normed()
+	sin, cos := math32.Sincos(degrees * math32.Pi / 180)
+	t := 1 - cos
+	return Mat4{[4][4]float32{
+		{t*a.x*a.x + cos, t*a.x*a.y - sin*a.z, t*a.x*a.z + sin*a.y, 0},
+		{t*a.x*a.y + sin*a.z, t*a.y*a.y + cos, t*a.y*a.z - sin*a.x, 0},
+		{t*a.x*a.z - sin*a.y, t*a.y*a.z + sin*a.x, t*a.z*a.z + cos, 0},
+		{0, 0, 0, 1},
+	}}
+}
+
+// At returns the element in row i and column j
+func (m Mat4) At(i, j int) float32 {
+	return m.m[i][j]
+}
+
+// Mul returns the matrix product m * o, which applies o first
+func (m Mat4) Mul(o Mat4) Mat4 {
+	var r Mat4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			r.m[i][j] = m.m[i][0]*o.m[0][j] + m.m[i][1]*o.m[1][j] + m.m[i][2]*o.m[2][j] + m.m[i][3]*o.m[3][j]
+		}
+	}
+	return r
+}
+
+// Transposed returns the transpose of m
+func (m Mat4) Transposed() Mat4 {
+	var r Mat4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			r.m[i][j] = m.m[j][i]
+		}
+	}
+	return r
+}
+
+// Inverse returns the inverse of m and false if m is singular
+func (m Mat4) Inverse() (Mat4, bool) {
+	// Gauss-Jordan elimination with partial pivoting
+	a := m.m
+	inv := Identity().m
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math32.Abs(a[row][col]) > math32.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math32.Abs(a[pivot][col]) < 1e-12 {
+			return Mat4{}, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+		f := 1 / a[col][col]
+		for j := 0; j < 4; j++ {
+			a[col][j] *= f
+			inv[col][j] *= f
+		}
+		for row := 0; row < 4; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col]
+			for j := 0; j < 4; j++ {
+				a[row][j] -= f * a[col][j]
+				inv[row][j] -= f * inv[col][j]
+			}
+		}
+	}
+	return Mat4{inv}, true
+}
+
+// MulPoint transforms the point p including the translation of m
+func (m Mat4) MulPoint(p Vec3) Vec3 {
+	return Vec3{
+		m.m[0][0]*p.x + m.m[0][1]*p.y + m.m[0][2]*p.z + m.m[0][3],
+		m.m[1][0]*p.x + m.m[1][1]*p.y + m.m[1][2]*p.z + m.m[1][3],
+		m.m[2][0]*p.x + m.m[2][1]*p.y + m.m[2][2]*p.z + m.m[2][3],
+	}
+}
+
+// MulDir transforms the direction d ignoring the translation of m
+func (m Mat4) MulDir(d Vec3) Vec3 {
+	return Vec3{
+		m.m[0][0]*d.x + m.m[0][1]*d.y + m.m[0][2]*d.z,
+		m.m[1][0]*d.x + m.m[1][1]*d.y + m.m[1][2]*d.z,
+		m.m[2][0]*d.x + m.m[2][1]*d.y + m.m[2][2]*d.z,
+	}
+}
+
+// NormalMatrix returns the inverse transpose of m, which transforms
+// surface normals so they stay perpendicular to transformed surfaces.
+// It returns false if m is singular.
+func (m Mat4) NormalMatrix() (Mat4, bool) {
+	inv, ok := m.Inverse()
+	return inv.Transposed(), ok
+}
+
+// TransformBox returns the axis aligned box enclosing the eight corners of
+// a transformed by m. It encloses everything inside a, but when m rotates
+// it is larger than the box of the transformed contents of a.
+func (m Mat4) TransformBox(a Aabb) Aabb {
+	var small, big Vec3
+	for i := 0; i < 8; i++ {
+		corner := a.min
+		if i&1 != 0 {
+			corner.x = a.max.x
+		}
+		if i&2 != 0 {
+			corner.y = a.max.y
+		}
+		if i&4 != 0 {
+			corner.z = a.max.z
+		}
+		p := m.MulPoint(corner)
+		if i == 0 {
+			small, big = p, p
+			continue
+		}
+		small = Vec3{min(small.x, p.x), min(small.y, p.y), min(small.z, p.z)}
+		big = Vec3{max(big.x, p.x), max(big.y, p.y), max(big.z, p.z)}
+	}
+	return Aabb{small, big}
+}
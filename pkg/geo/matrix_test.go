@@ -0,0 +1,93 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func near(a, b float32) bool {
+	return math32.Abs(a-b) < 1e-5
+}
+
+func nearVec(a, b Vec3) bool {
+	return near(a.x, b.x) && near(a.y, b.y) && near(a.z, b.z)
+}
+
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Mat4
+	}{
+		{"identity", Identity()},
+		{"translation", Translation(Vec3{1, -2, 3})},
+		{"scaling", Scaling(Vec3{2, 0.5, -4})},
+		{"rotation", Rotation(Vec3{1, 1, 0}, 30)},
+		{"composed", Translation(Vec3{1, 2, 3}).Mul(Rotation(Vec3{0, 1, 1}, 75)).Mul(Scaling(Vec3{3, 1, 0.25}))},
+		// A zero on the diagonal needs a row swap
+		{"permutation", NewMat4([4][4]float32{{0, 1, 0, 0}, {0, 0, 1, 0}, {1, 0, 0, 0}, {0, 0, 0, 1}})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, ok := tt.m.Inverse()
+			if !ok {
+				t.Fatal("matrix is reported singular")
+			}
+			for _, p := range [][4][4]float32{tt.m.Mul(inv).m, inv.Mul(tt.m).m} {
+				for i := 0; i < 4; i++ {
+					for j := 0; j < 4; j++ {
+						if want := Identity().m[i][j]; !near(p[i][j], want) {
+							t.Fatalf("product element %d,%d is %g, want %g", i, j, p[i][j], want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestInverseSingular(t *testing.T) {
+	for _, m := range []Mat4{
+		Scaling(Vec3{1, 0, 1}),
+		NewMat4([4][4]float32{{1, 2, 3, 0}, {2, 4, 6, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}),
+	} {
+		if _, ok := m.Inverse(); ok {
+			t.Errorf("Inverse of %v succeeded", m)
+		}
+		if _, ok := m.NormalMatrix(); ok {
+			t.Errorf("NormalMatrix of %v succeeded", m)
+		}
+	}
+}
+
+func TestNormalMatrix(t *testing.T) {
+	// Non-uniform scaling tilts normals differently than surfaces
+	m := Translation(Vec3{4, 5, 6}).Mul(Rotation(Vec3{1, 0, 1}, 40)).Mul(Scaling(Vec3{3, 1, 0.5}))
+	nm, ok := m.NormalMatrix()
+	if !ok {
+		t.Fatal("matrix is reported singular")
+	}
+	normal := Vec3{1, 2, 2}.Normed()
+	tangents := []Vec3{normal.Cross(UnitX), normal.Cross(UnitY), normal.Cross(UnitZ)}
+	n := nm.MulDir(normal)
+	for _, tangent := range tangents {
+		if d := n.Normed().Dot(m.MulDir(tangent).Normed()); !near(d, 0) {
+			t.Errorf("transformed normal is not perpendicular to transformed tangent %v: dot %g", tangent, d)
+		}
+	}
+	if nm.MulDir(normal).Dot(m.MulDir(normal)) <= 0 {
+		t.Error("transformed normal flipped to the other side of the surface")
+	}
+}
+
+func TestTransformBox(t *testing.T) {
+	// Rotating a cube by 45 degrees about z widens its box by the
+	// diagonal, which is the bound for any object inside the cube
+	m := Translation(Vec3{0, 0, 2}).Mul(Rotation(UnitZ, 45))
+	got := m.TransformBox(Aabb{Vec3{-1, -1, -1}, Vec3{1, 1, 1}})
+	s := math32.Sqrt2
+	want := Aabb{Vec3{-s, -s, 1}, Vec3{s, s, 3}}
+	if !nearVec(got.min, want.min) || !nearVec(got.max, want.max) {
+		t.Errorf("got box %v, want %v", got, want)
+	}
+}
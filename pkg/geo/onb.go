@@ -0,0 +1,56 @@
+package geo
+
+import "github.com/chewxy/math32"
+
+// ONB is a right handed orthonormal basis u, v, w
+// used to express directions relative to a normal
+type ONB struct {
+	u, v, w Vec3
+}
+
+// NewONB constructs an ONB whose w axis is the unit vector w
+func NewONB(w Vec3) ONB {
+	a := UnitX
+	if math32.Abs(w.x) > 0.9 {
+		a = UnitY
+	}
+	v := w.Cross(a).Normed()
+	return ONB{u: v.Cross(w), v: v, w: w}
+}
+
+// NewONBFromTangent constructs an ONB whose w axis is the unit vector w
+// and whose u axis is the part of tangent perpendicular to it. Without
+// such a part it is the same as NewONB.
+func NewONBFromTangent(w, tangent Vec3) ONB {
+	u := tangent.Sub(w.Mul(w.Dot(tangent)))
+	if u.LenSq() < 1e-12*tangent.LenSq() || tangent.LenSq() == 0 {
+		return NewONB(w)
+	}
+	u = u.Normed()
+	return ONB{u: u, v: w.Cross(u), w: w}
+}
+
+// U returns the first axis of o
+func (o ONB) U() Vec3 {
+	return o.u
+}
+
+// V returns the second axis of o
+func (o ONB) V() Vec3 {
+	return o.v
+}
+
+// W returns the third axis of o
+func (o ONB) W() Vec3 {
+	return o.w
+}
+
+// Local transforms a from the coordinates of o to world coordinates
+func (o ONB) Local(a Vec3) Vec3 {
+	return o.u.Mul(a.x).Add(o.v.Mul(a.y)).Add(o.w.Mul(a.z))
+}
+
+// ToLocal transforms a from world coordinates to the coordinates of o
+func (o ONB) ToLocal(a Vec3) Vec3 {
+	return Vec3{a.Dot(o.u), a.Dot(o.v), a.Dot(o.w)}
+}
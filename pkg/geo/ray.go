@@ -1,16 +1,17 @@
 package geo
 
-// Ray represents a ray with an origin orig
-// and a direction dir
+// Ray represents a ray with an origin orig,
+// a direction dir and the time it exists at
 type Ray struct {
 	orig, dir Vec3
 	lenSq     float32
+	time      float32
 }
 
-// NewRay constructs a new Ray from on origin and direction
-func NewRay(orig, dir Vec3) Ray {
+// NewRay constructs a new Ray from on origin, direction and time
+func NewRay(orig, dir Vec3, time float32) Ray {
 	lenSq := dir.LenSq()
-	return Ray{orig: orig, dir: dir, lenSq: lenSq}
+	return Ray{orig: orig, dir: dir, lenSq: lenSq, time: time}
 }
 
 // Orig returns the rays origin
@@ -23,6 +24,11 @@ func (r *Ray) Dir() Vec3 {
 	return r.dir
 }
 
+// Time returns the point in time the ray exists at
+func (r *Ray) Time() float32 {
+	return r.time
+}
+
 // At calculates a position on the ray
 // as orig + t * dir
 func (r *Ray) At(t float32) Vec3 {
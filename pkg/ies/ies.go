@@ -0,0 +1,313 @@
+// Package ies reads IES LM-63 photometric files, which describe how the
+// luminous intensity of a luminaire varies with direction
+package ies
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// typeC is the photometric type whose vertical angles are measured from
+// the nadir, used for nearly all architectural and area lighting
+const typeC = 1
+
+// Profile is the intensity distribution of a type C photometric file.
+// Vertical angles are measured from the nadir, straight below the
+// luminaire, and horizontal angles counter clockwise around it.
+type Profile struct {
+	keywords   map[string]string
+	lumens     float32
+	vertical   []float32
+	horizontal []float32
+	// candela holds the intensity of every vertical
+	// angle for one horizontal angle after the other
+	candela []float32
+}
+
+// Load reads the photometric file name from fsys
+func Load(fsys fs.FS, name string) (*Profile, error) {
+	in, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return Parse(in)
+}
+
+// Parse reads a photometric file in any version of LM-63. The candela
+// values are scaled by the candela multiplier and ballast factor.
+func Parse(r io.Reader) (*Profile, error) {
+	p := &Profile{keywords: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var key string
+	tilt := ""
+	for tilt == "" && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "TILT="):
+			tilt = strings.TrimPrefix(line, "TILT=")
+		case strings.HasPrefix(line, "["):
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			value := strings.TrimSpace(line[end+1:])
+			if k := strings.ToUpper(line[1:end]); k != "MORE" {
+				key = k
+				p.keywords[key] = value
+			} else if key != "" {
+				p.keywords[key] += "\n" + value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ies: %w", err)
+	}
+	if tilt == "" {
+		return nil, errors.New("ies: missing TILT line")
+	}
+
+	var fields []string
+	for scanner.Scan() {
+		fields = append(fields, strings.FieldsFunc(scanner.Text(), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ies: %w", err)
+	}
+	numbers := &numberReader{fields: fields}
+
+	switch tilt {
+	case "NONE":
+	case "INCLUDE":
+		// The tilt factors only matter for lamps which are not
+		// mounted in the orientation they were measured in
+		numbers.next()
+		pairs := numbers.count()
+		numbers.skip(2 * pairs)
+	default:
+		return nil, fmt.Errorf("ies: unsupported external tilt file %q", tilt)
+	}
+
+	numbers.next() // number of lamps
+	p.lumens = numbers.next()
+	multiplier := numbers.next()
+	nv := numbers.count()
+	nh := numbers.count()
+	photometricType := numbers.count()
+	numbers.skip(4) // units, width, length and height
+	ballast := numbers.next()
+	numbers.skip(2) // ballast lamp factor and input watts
+	if numbers.err != nil {
+		return nil, numbers.err
+	}
+	if photometricType != typeC {
+		return nil, fmt.Errorf("ies: unsupported photometric type %d, only type C is supported", photometricType)
+	}
+	if nv < 1 || nh < 1 {
+		return nil, errors.New("ies: no angles")
+	}
+	// Check the counts against the file before allocating,
+	// without multiplying them in case they are large
+	if left := len(numbers.fields); nv+nh > left || nh > (left-nv-nh)/nv {
+		return nil, fmt.Errorf("ies: %d vertical and %d horizontal angles need more values than the file has: %w",
+			nv, nh, io.ErrUnexpectedEOF)
+	}
+	p.vertical = numbers.list(nv)
+	p.horizontal = numbers.list(nh)
+	p.candela = numbers.list(nv * nh)
+	if numbers.err != nil {
+		return nil, numbers.err
+	}
+	for i := range p.candela {
+		p.candela[i] *= multiplier * ballast
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// validate checks the angles follow the layouts defined for type C
+func (p *Profile) validate() error {
+	for _, angles := range [][]float32{p.vertical, p.horizontal} {
+		for i := 1; i < len(angles); i++ {
+			if angles[i] <= angles[i-1] {
+				return errors.New("ies: angles must increase")
+			}
+		}
+	}
+	if first, last := p.vertical[0], p.vertical[len(p.vertical)-1]; first < 0 || last > 180 {
+		return errors.New("ies: vertical angles must lie between 0 and 180")
+	}
+	first, last := p.horizontal[0], p.horizontal[len(p.horizontal)-1]
+	if first != 0 {
+		return errors.New("ies: horizontal angles must start at 0")
+	}
+	if last != 0 && last != 90 && last != 180 && (last < 180 || last > 360) {
+		return fmt.Errorf("ies: horizontal angles must end at 0, 90, 180 or 360, not %g", last)
+	}
+	return nil
+}
+
+// Keyword returns the value of a keyword like MANUFAC or LUMINAIRE,
+// with the lines of [MORE] continuations joined by newlines
+func (p *Profile) Keyword(name string) string {
+	return p.keywords[strings.ToUpper(name)]
+}
+
+// Lumens returns the rated flux of each lamp, -1 for absolute photometry
+func (p *Profile) Lumens() float32 {
+	return p.lumens
+}
+
+// VerticalAngles returns the measured vertical angles in degrees
+func (p *Profile) VerticalAngles() []float32 {
+	return p.vertical
+}
+
+// HorizontalAngles returns the measured horizontal angles in degrees
+func (p *Profile) HorizontalAngles() []float32 {
+	return p.horizontal
+}
+
+// Candela returns the intensity measured at the vertical
+// angle with index v and the horizontal angle with index h
+func (p *Profile) Candela(v, h int) float32 {
+	return p.candela[h*len(p.vertical)+v]
+}
+
+// Range returns the smallest and largest intensity in candela
+func (p *Profile) Range() (lo, hi float32) {
+	lo, hi = p.candela[0], p.candela[0]
+	for _, c := range p.candela[1:] {
+		lo = min(lo, c)
+		hi = max(hi, c)
+	}
+	return lo, hi
+}
+
+// Intensity returns the intensity in candela at the vertical and
+// horizontal angle in degrees, interpolating bilinearly between the
+// measured angles. The horizontal angles are completed by the symmetry
+// implied by the last of them. There is no light outside the vertical
+// angles.
+func (p *Profile) Intensity(vertical, horizontal float32) float32 {
+	if vertical < p.vertical[0] || vertical > p.vertical[len(p.vertical)-1] {
+		return 0
+	}
+	v, fv := segment(p.vertical, vertical)
+
+	horizontal -= 360 * float32(int(horizontal/360))
+	if horizontal < 0 {
+		horizontal += 360
+	}
+	n := len(p.horizontal)
+	last := p.horizontal[n-1]
+	var h0, h1 int
+	var fh float32
+	switch {
+	case n == 1:
+		// Rotationally symmetric
+	case last > 180 && horizontal > last:
+		// Wrap around from the last angle to 360, which is 0
+		h0, h1 = n-1, 0
+		fh = (horizontal - last) / (360 - last)
+	default:
+		if last <= 180 && horizontal > 180 {
+			horizontal = 360 - horizontal
+		}
+		if last <= 90 && horizontal > 90 {
+			horizontal = 180 - horizontal
+		}
+		h0, fh = segment(p.horizontal, horizontal)
+		h1 = h0 + 1
+	}
+
+	at := func(h int) float32 {
+		c := p.Candela(v, h)
+		if fv > 0 {
+			c += fv * (p.Candela(v+1, h) - c)
+		}
+		return c
+	}
+	c := at(h0)
+	if fh > 0 {
+		c += fh * (at(h1) - c)
+	}
+	return c
+}
+
+// segment returns the index of the last angle up to x and the
+// fraction of the way from it to the next, which x lies at
+func segment(angles []float32, x float32) (int, float32) {
+	n := len(angles)
+	if n == 1 {
+		return 0, 0
+	}
+	i := sort.Search(n, func(i int) bool { return angles[i] > x }) - 1
+	i = min(max(i, 0), n-2)
+	f := (x - angles[i]) / (angles[i+1] - angles[i])
+	return i, min(max(f, 0), 1)
+}
+
+// numberReader parses the numeric part of a photometric file,
+// remembering the first error
+type numberReader struct {
+	fields []string
+	err    error
+}
+
+func (n *numberReader) next() float32 {
+	if n.err != nil {
+		return 0
+	}
+	if len(n.fields) == 0 {
+		n.err = fmt.Errorf("ies: %w", io.ErrUnexpectedEOF)
+		return 0
+	}
+	v, err := strconv.ParseFloat(n.fields[0], 32)
+	if err != nil {
+		n.err = fmt.Errorf("ies: invalid number %q", n.fields[0])
+	}
+	n.fields = n.fields[1:]
+	return float32(v)
+}
+
+// count reads a number of values, which cannot be more than the file has left
+func (n *numberReader) count() int {
+	v := n.next()
+	switch {
+	case n.err != nil:
+		return 0
+	case v > float32(len(n.fields)):
+		n.err = fmt.Errorf("ies: count %g exceeds the remaining values: %w", v, io.ErrUnexpectedEOF)
+		return 0
+	case v < 0 || v != float32(int(v)):
+		n.err = fmt.Errorf("ies: invalid count %g", v)
+		return 0
+	}
+	return int(v)
+}
+
+func (n *numberReader) skip(count int) {
+	for i := 0; i < count && n.err == nil; i++ {
+		n.next()
+	}
+}
+
+func (n *numberReader) list(count int) []float32 {
+	values := make([]float32, count)
+	for i := range values {
+		values[i] = n.next()
+	}
+	return values
+}
@@ -0,0 +1,152 @@
+package ies
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// header is the start of a photometric file up to the TILT line
+const header = `IESNA:LM-63-2002
+[TEST] 1
+[MANUFAC] Acme
+[MORE] Lighting
+`
+
+// quad has vertical angles 0 and 90 at horizontal angles 0 and 90,
+// with a candela multiplier of 2 and a ballast factor of 0.25
+const quad = `1 1000 2 2 2 1 1 0 0 0
+0.25 1 10
+0 90
+0 90
+1 2
+3 4
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse(strings.NewReader(header + "TILT=NONE\n" + quad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Keyword("manufac"); got != "Acme\nLighting" {
+		t.Errorf("got MANUFAC %q", got)
+	}
+	if p.Lumens() != 1000 {
+		t.Errorf("got %g lumens, want 1000", p.Lumens())
+	}
+	if got := p.VerticalAngles(); !reflect.DeepEqual(got, []float32{0, 90}) {
+		t.Errorf("got vertical angles %v", got)
+	}
+	if got := p.HorizontalAngles(); !reflect.DeepEqual(got, []float32{0, 90}) {
+		t.Errorf("got horizontal angles %v", got)
+	}
+	if got := p.Candela(1, 1); got != 2 {
+		t.Errorf("got candela %g at 90, 90, want 2", got)
+	}
+}
+
+func TestParseTiltInclude(t *testing.T) {
+	// Lamp to luminaire geometry and three pairs of angles and factors,
+	// with the values split over lines and separated by commas
+	tilt := "TILT=INCLUDE\n1\n3\n0, 45,\n90\n1 0.9\n0.8\n"
+	p, err := Parse(strings.NewReader(header + tilt + quad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Lumens() != 1000 || p.Candela(0, 1) != 1.5 {
+		t.Errorf("tilt values were not skipped: %g lumens, candela %v", p.Lumens(), p.candela)
+	}
+	if _, err := Parse(strings.NewReader(header + "TILT=INCLUDE\n1\n3\n0 45 90\n1 0.9\n")); err == nil {
+		t.Error("accepted truncated tilt values")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"missing tilt", header + quad, "ies: missing TILT line"},
+		{"tilt file", header + "TILT=lamp.tlt\n" + quad, `ies: unsupported external tilt file "lamp.tlt"`},
+		{"type B", header + "TILT=NONE\n1 1000 1 2 2 2 1 0 0 0\n1 1 10\n0 90\n0 90\n1 2 3 4\n", "ies: unsupported photometric type 2"},
+		{"no angles", header + "TILT=NONE\n1 1000 1 0 1 1 1 0 0 0\n1 1 10\n0\n", "ies: no angles"},
+		{"fractional count", header + "TILT=NONE\n1 1000 1 1.5 1 1 1 0 0 0\n1 1 10\n0\n0\n1\n", "ies: invalid count 1.5"},
+		{"invalid number", header + "TILT=NONE\n1 1000 1 2 1 1 1 0 0 0\n1 1 10\n0 x\n0\n1 2\n", `ies: invalid number "x"`},
+		{"truncated", header + "TILT=NONE\n" + quad[:len(quad)-4], "ies: 2 vertical and 2 horizontal angles need more values"},
+		{"decreasing angles", header + "TILT=NONE\n1 1000 1 2 1 1 1 0 0 0\n1 1 10\n90 0\n0\n1 2\n", "ies: angles must increase"},
+		{"vertical past 180", header + "TILT=NONE\n1 1000 1 2 1 1 1 0 0 0\n1 1 10\n0 190\n0\n1 2\n", "ies: vertical angles must lie between 0 and 180"},
+		{"horizontal start", header + "TILT=NONE\n1 1000 1 1 2 1 1 0 0 0\n1 1 10\n0\n10 90\n1 2\n", "ies: horizontal angles must start at 0"},
+		{"horizontal end", header + "TILT=NONE\n1 1000 1 1 2 1 1 0 0 0\n1 1 10\n0\n0 45\n1 2\n", "ies: horizontal angles must end at 0, 90, 180 or 360, not 45"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHugeCounts(t *testing.T) {
+	// Counts far beyond the values in the file must fail before they
+	// are allocated, also when their product overflows
+	for _, counts := range []string{"100000 100000", "3037000500 3037000500", "2 1e30", "4 3"} {
+		input := header + "TILT=NONE\n1 1000 1 " + counts + " 1 1 0 0 0\n1 1 10\n0 90\n0 90\n1 2 3 4\n"
+		_, err := Parse(strings.NewReader(input))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("counts %s: got error %v, want unexpected EOF", counts, err)
+		}
+	}
+}
+
+func TestIntensity(t *testing.T) {
+	profile := func(vertical, horizontal, candela string) *Profile {
+		t.Helper()
+		nv, nh := len(strings.Fields(vertical)), len(strings.Fields(horizontal))
+		input := fmt.Sprintf("%sTILT=NONE\n1 1000 1 %d %d 1 1 0 0 0\n1 1 10\n%s\n%s\n%s\n",
+			header, nv, nh, vertical, horizontal, candela)
+		p, err := Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := []struct {
+		name                 string
+		p                    *Profile
+		vertical, horizontal float32
+		want                 float32
+	}{
+		{"symmetric", profile("0 90", "0", "5 7"), 45, 123, 6},
+		{"symmetric outside", profile("0 90", "0", "5 7"), 100, 0, 0},
+		// A quadrant is mirrored across the 0-180 and the 90-270 planes
+		{"quadrant", profile("0 90", "0 90", "1 2 3 4"), 45, 0, 1.5},
+		{"quadrant inside", profile("0 90", "0 90", "1 2 3 4"), 45, 45, 2.5},
+		{"quadrant end", profile("0 90", "0 90", "1 2 3 4"), 45, 90, 3.5},
+		{"quadrant mirrored", profile("0 90", "0 90", "1 2 3 4"), 45, 135, 2.5},
+		{"quadrant opposite", profile("0 90", "0 90", "1 2 3 4"), 45, 180, 1.5},
+		{"quadrant third", profile("0 90", "0 90", "1 2 3 4"), 45, 270, 3.5},
+		{"quadrant fourth", profile("0 90", "0 90", "1 2 3 4"), 45, 315, 2.5},
+		{"quadrant negative", profile("0 90", "0 90", "1 2 3 4"), 45, -45, 2.5},
+		// A half is mirrored across the 0-180 plane
+		{"half", profile("0", "0 90 180", "1 2 3"), 0, 135, 2.5},
+		{"half mirrored", profile("0", "0 90 180", "1 2 3"), 0, 225, 2.5},
+		{"half wrapped", profile("0", "0 90 180", "1 2 3"), 0, 630, 2},
+		// A full profile wraps around from its last angle to 0
+		{"full", profile("0", "0 120 240", "1 2 3"), 0, 60, 1.5},
+		{"full last", profile("0", "0 120 240", "1 2 3"), 0, 300, 2},
+		{"full wrap", profile("0", "0 120 240", "1 2 3"), 0, 359, 3 - 2*119.0/120},
+		{"full 360", profile("0", "0 180 360", "1 2 1"), 0, 270, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.Intensity(tt.vertical, tt.horizontal); math.Abs(float64(got-tt.want)) > 1e-4 {
+				t.Errorf("Intensity(%g, %g) = %g, want %g", tt.vertical, tt.horizontal, got, tt.want)
+			}
+		})
+	}
+}
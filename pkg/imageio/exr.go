@@ -0,0 +1,458 @@
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+// PixelType is the channel data type of an OpenEXR file
+type PixelType int32
+
+const (
+	// Half stores 16 bit floats
+	Half PixelType = 1
+	// Float stores 32 bit floats
+	Float PixelType = 2
+)
+
+// Compression is the compression method of an OpenEXR file
+type Compression uint8
+
+const (
+	// NoCompression stores raw scanlines
+	NoCompression Compression = 0
+	// ZIPCompression deflates blocks of 16 scanlines
+	ZIPCompression Compression = 3
+)
+
+// Compression methods which can only be read
+const (
+	rleCompression  Compression = 1
+	zipsCompression Compression = 2
+)
+
+var exrMagic = []byte{0x76, 0x2f, 0x31, 0x01}
+
+// EXR channels are stored in alphabetical order
+var exrChannels = []string{"B", "G", "R"}
+
+// linesPerBlock is the number of scanlines compressed together
+func (c Compression) linesPerBlock() int {
+	if c == ZIPCompression {
+		return 16
+	}
+	return 1
+}
+
+// maxExpansion is the largest factor by which decompressing a chunk can
+// grow it, deflate expands at most about 1032 times and RLE 64 times
+func (c Compression) maxExpansion() int64 {
+	switch c {
+	case rleCompression:
+		return 64
+	case zipsCompression, ZIPCompression:
+		return 1032
+	}
+	return 1
+}
+
+// WriteEXR writes f as a single part scanline OpenEXR image
+func WriteEXR(w io.Writer, f *tracer.Film, pixelType PixelType, compression Compression) error {
+	if pixelType != Half && pixelType != Float {
+		return errors.New("imageio: unsupported EXR pixel type")
+	}
+	if compression != NoCompression && compression != ZIPCompression {
+		return errors.New("imageio: unsupported EXR compression")
+	}
+	width, height := f.Width(), f.Height()
+
+	var hdr bytes.Buffer
+	hdr.Write(exrMagic)
+	binary.Write(&hdr, binary.LittleEndian, uint32(2))
+
+	var chlist bytes.Buffer
+	for _, name := range exrChannels {
+		chlist.WriteString(name)
+		chlist.WriteByte(0)
+		binary.Write(&chlist, binary.LittleEndian, int32(pixelType))
+		// pLinear and reserved bytes, followed by x and y sampling
+		chlist.Write([]byte{0, 0, 0, 0})
+		binary.Write(&chlist, binary.LittleEndian, [2]int32{1, 1})
+	}
+	chlist.WriteByte(0)
+	window := [4]int32{0, 0, int32(width - 1), int32(height - 1)}
+
+	writeAttr(&hdr, "channels", "chlist", chlist.Bytes())
+	writeAttr(&hdr, "compression", "compression", []byte{byte(compression)})
+	writeAttr(&hdr, "dataWindow", "box2i", window)
+	writeAttr(&hdr, "displayWindow", "box2i", window)
+	writeAttr(&hdr, "lineOrder", "lineOrder", []byte{0})
+	writeAttr(&hdr, "pixelAspectRatio", "float", float32(1))
+	writeAttr(&hdr, "screenWindowCenter", "v2f", [2]float32{0, 0})
+	writeAttr(&hdr, "screenWindowWidth", "float", float32(1))
+	hdr.WriteByte(0)
+
+	linesPerBlock := compression.linesPerBlock()
+	blocks := (height + linesPerBlock - 1) / linesPerBlock
+	chunks := make([][]byte, blocks)
+	for b := range chunks {
+		y0 := b * linesPerBlock
+		y1 := min(y0+linesPerBlock, height)
+		raw := exrBlock(f, y0, y1, pixelType)
+		data := raw
+		if compression == ZIPCompression {
+			var err error
+			if data, err = zipCompress(raw); err != nil {
+				return err
+			}
+			// Blocks which do not shrink are stored uncompressed
+			if len(data) >= len(raw) {
+				data = raw
+			}
+		}
+		chunk := make([]byte, 8+len(data))
+		binary.LittleEndian.PutUint32(chunk, uint32(y0))
+		binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+		copy(chunk[8:], data)
+		chunks[b] = chunk
+	}
+
+	offset := uint64(hdr.Len() + 8*blocks)
+	for _, c := range chunks {
+		binary.Write(&hdr, binary.LittleEndian, offset)
+		offset += uint64(len(c))
+	}
+	if _, err := w.Write(hdr.Bytes()); err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		if _, err := w.Write(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAttr(b *bytes.Buffer, name, typ string, value any) {
+	b.WriteString(name)
+	b.WriteByte(0)
+	b.WriteString(typ)
+	b.WriteByte(0)
+	if raw, ok := value.([]byte); ok {
+		binary.Write(b, binary.LittleEndian, int32(len(raw)))
+		b.Write(raw)
+		return
+	}
+	binary.Write(b, binary.LittleEndian, int32(binary.Size(value)))
+	binary.Write(b, binary.LittleEndian, value)
+}
+
+// exrBlock returns the uncompressed pixel data of rows y0 to y1,
+// each row holds all values of the B, G and R channels in turn
+func exrBlock(f *tracer.Film, y0, y1 int, pixelType PixelType) []byte {
+	size := 4
+	if pixelType == Half {
+		size = 2
+	}
+	width := f.Width()
+	buf := make([]byte, 0, (y1-y0)*width*len(exrChannels)*size)
+	for y := y0; y < y1; y++ {
+		for c := range exrChannels {
+			for x := 0; x < width; x++ {
+				col := f.At(x, y)
+				v := [3]float32{col.B(), col.G(), col.R()}[c]
+				if pixelType == Half {
+					buf = binary.LittleEndian.AppendUint16(buf, floatToHalf(v))
+				} else {
+					buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+				}
+			}
+		}
+	}
+	return buf
+}
+
+// zipCompress applies the OpenEXR byte interleaving and
+// delta predictor to raw before deflating it
+func zipCompress(raw []byte) ([]byte, error) {
+	tmp := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			tmp[i/2] = b
+		} else {
+			tmp[half+i/2] = b
+		}
+	}
+	for i := len(tmp) - 1; i > 0; i-- {
+		tmp[i] = byte(int(tmp[i]) - int(tmp[i-1]) + 128)
+	}
+	var out bytes.Buffer
+	zw := zlib.NewWriter(&out)
+	if _, err := zw.Write(tmp); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// exrChannel is an entry of the channel list of an OpenEXR file
+type exrChannel struct {
+	name      string
+	pixelType PixelType
+}
+
+func (c exrChannel) size() int {
+	if c.pixelType == Half {
+		return 2
+	}
+	return 4
+}
+
+// ReadEXR decodes a single part scanline OpenEXR image with uncompressed,
+// RLE or ZIP compressed half, float or uint channels. Images without
+// R, G and B channels must have a luminance channel Y.
+func ReadEXR(r io.Reader) (*tracer.Film, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || !bytes.Equal(data[:4], exrMagic) {
+		return nil, errors.New("imageio: not an OpenEXR file")
+	}
+	// Tiled, deep and multi part files
+	if version := binary.LittleEndian.Uint32(data[4:]); version&0x1a00 != 0 {
+		return nil, errors.New("imageio: unsupported OpenEXR file layout")
+	}
+	var channels []exrChannel
+	var compression Compression
+	var window [4]int32
+	pos := 8
+	cstring := func() (string, error) {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		s := string(data[pos : pos+end])
+		pos += end + 1
+		return s, nil
+	}
+	for {
+		name, err := cstring()
+		if err != nil {
+			return nil, fmt.Errorf("imageio: EXR header: %w", err)
+		}
+		if name == "" {
+			break
+		}
+		typ, err := cstring()
+		if err != nil || pos+4 > len(data) {
+			return nil, fmt.Errorf("imageio: EXR attribute %s: %w", name, io.ErrUnexpectedEOF)
+		}
+		size := int(int32(binary.LittleEndian.Uint32(data[pos:])))
+		pos += 4
+		if size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("imageio: EXR attribute %s: %w", name, io.ErrUnexpectedEOF)
+		}
+		value := data[pos : pos+size]
+		pos += size
+		switch {
+		case name == "channels" && typ == "chlist":
+			if channels, err = parseChannels(value); err != nil {
+				return nil, err
+			}
+		case name == "compression" && size == 1:
+			compression = Compression(value[0])
+		case name == "dataWindow" && size == 16:
+			binary.Read(bytes.NewReader(value), binary.LittleEndian, &window)
+		}
+	}
+	switch compression {
+	case NoCompression, rleCompression, zipsCompression, ZIPCompression:
+	default:
+		return nil, fmt.Errorf("imageio: unsupported EXR compression %d", compression)
+	}
+	width64 := int64(window[2]) - int64(window[0]) + 1
+	height64 := int64(window[3]) - int64(window[1]) + 1
+	if width64 <= 0 || height64 <= 0 {
+		return nil, errors.New("imageio: invalid EXR data window")
+	}
+	// rgb holds the indices of the channels making up the color
+	rgb := [3]int{-1, -1, -1}
+	luma := -1
+	for i, c := range channels {
+		switch c.name {
+		case "R":
+			rgb[0] = i
+		case "G":
+			rgb[1] = i
+		case "B":
+			rgb[2] = i
+		case "Y":
+			luma = i
+		}
+	}
+	if rgb[0] < 0 || rgb[1] < 0 || rgb[2] < 0 {
+		if luma < 0 {
+			return nil, errors.New("imageio: EXR file has no RGB or Y channels")
+		}
+		rgb = [3]int{luma, luma, luma}
+	}
+	pixelSize := int64(0)
+	for _, c := range channels {
+		pixelSize += int64(c.size())
+	}
+	// Check the window against the file before allocating, the offset
+	// table and the compressed rows must fit in it
+	linesPerBlock := int64(compression.linesPerBlock())
+	blocks64 := (height64 + linesPerBlock - 1) / linesPerBlock
+	if blocks64 > int64(len(data)-pos)/8 {
+		return nil, errors.New("imageio: truncated EXR offset table")
+	}
+	limit := compression.maxExpansion() * int64(len(data))
+	if width64 > limit/pixelSize || height64 > limit/(width64*pixelSize) {
+		return nil, fmt.Errorf("imageio: EXR data window of %dx%d pixels does not fit in the file", width64, height64)
+	}
+	width, height, blocks := int(width64), int(height64), int(blocks64)
+	rowSize := width * int(pixelSize)
+
+	f := tracer.NewFilm(width, height)
+	for b := 0; b < blocks; b++ {
+		offset := binary.LittleEndian.Uint64(data[pos+8*b:])
+		if offset > uint64(len(data)-8) {
+			return nil, errors.New("imageio: invalid EXR chunk offset")
+		}
+		chunk := data[offset:]
+		y0 := int(int32(binary.LittleEndian.Uint32(chunk))) - int(window[1])
+		size := int(binary.LittleEndian.Uint32(chunk[4:]))
+		if y0 < 0 || y0 >= height || size > len(chunk)-8 {
+			return nil, errors.New("imageio: invalid EXR chunk")
+		}
+		lines := min(int(linesPerBlock), height-y0)
+		raw, err := decompress(chunk[8:8+size], lines*rowSize, compression)
+		if err != nil {
+			return nil, fmt.Errorf("imageio: EXR chunk at line %d: %w", y0, err)
+		}
+		for l := 0; l < lines; l++ {
+			row := raw[l*rowSize:]
+			var values [3][]byte
+			start := 0
+			for i, c := range channels {
+				for j, k := range rgb {
+					if k == i {
+						values[j] = row[start:]
+					}
+				}
+				start += width * c.size()
+			}
+			for x := 0; x < width; x++ {
+				var col [3]float32
+				for j := range col {
+					col[j] = channelValue(channels[rgb[j]].pixelType, values[j], x)
+				}
+				f.Set(x, y0+l, tracer.NewColor(col[0], col[1], col[2]))
+			}
+		}
+	}
+	return f, nil
+}
+
+func parseChannels(value []byte) ([]exrChannel, error) {
+	var channels []exrChannel
+	for len(value) > 0 && value[0] != 0 {
+		end := bytes.IndexByte(value, 0)
+		if end < 0 || len(value) < end+17 {
+			return nil, errors.New("imageio: invalid EXR channel list")
+		}
+		c := exrChannel{name: string(value[:end])}
+		value = value[end+1:]
+		c.pixelType = PixelType(binary.LittleEndian.Uint32(value))
+		if c.pixelType < 0 || c.pixelType > Float {
+			return nil, fmt.Errorf("imageio: unknown EXR pixel type %d", c.pixelType)
+		}
+		if binary.LittleEndian.Uint32(value[8:]) != 1 || binary.LittleEndian.Uint32(value[12:]) != 1 {
+			return nil, errors.New("imageio: subsampled EXR channels are not supported")
+		}
+		channels = append(channels, c)
+		value = value[16:]
+	}
+	return channels, nil
+}
+
+// channelValue returns value x of a row of one channel
+func channelValue(pixelType PixelType, row []byte, x int) float32 {
+	switch pixelType {
+	case Half:
+		return halfToFloat(binary.LittleEndian.Uint16(row[2*x:]))
+	case Float:
+		return math.Float32frombits(binary.LittleEndian.Uint32(row[4*x:]))
+	}
+	return float32(binary.LittleEndian.Uint32(row[4*x:]))
+}
+
+// decompress returns the rawSize bytes of pixel data in a chunk
+func decompress(data []byte, rawSize int, compression Compression) ([]byte, error) {
+	// Chunks which do not shrink are stored uncompressed
+	if compression == NoCompression || len(data) == rawSize {
+		if len(data) != rawSize {
+			return nil, errors.New("wrong size")
+		}
+		return data, nil
+	}
+	var tmp []byte
+	if compression == rleCompression {
+		tmp = make([]byte, 0, rawSize)
+		for i := 0; i < len(data); {
+			n := int(int8(data[i]))
+			if n < 0 {
+				if i+1-n > len(data) {
+					return nil, io.ErrUnexpectedEOF
+				}
+				tmp = append(tmp, data[i+1:i+1-n]...)
+				i += 1 - n
+				continue
+			}
+			if i+1 >= len(data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			for ; n >= 0; n-- {
+				tmp = append(tmp, data[i+1])
+			}
+			i += 2
+		}
+	} else {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if tmp, err = io.ReadAll(io.LimitReader(zr, int64(rawSize)+1)); err != nil {
+			return nil, err
+		}
+	}
+	if len(tmp) != rawSize {
+		return nil, errors.New("wrong size")
+	}
+	// Undo the delta predictor and byte interleaving of zipCompress
+	for i := 1; i < len(tmp); i++ {
+		tmp[i] = byte(int(tmp[i-1]) + int(tmp[i]) - 128)
+	}
+	raw := make([]byte, rawSize)
+	half := (rawSize + 1) / 2
+	for i := range raw {
+		if i%2 == 0 {
+			raw[i] = tmp[i/2]
+		} else {
+			raw[i] = tmp[half+i/2]
+		}
+	}
+	return raw, nil
+}
@@ -0,0 +1,68 @@
+package imageio
+
+import "math"
+
+// floatToHalf converts f to an IEEE 754 half precision float,
+// rounding to nearest even
+func floatToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	switch {
+	case exp == 0xff:
+		// Inf or NaN, keep NaNs quiet and non-zero
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp-127 > 15:
+		// Overflow
+		return sign | 0x7c00
+	case exp-127 >= -14:
+		// Normal half
+		half := uint32(exp-127+15)<<10 | mant>>13
+		round := mant & 0x1fff
+		if round > 0x1000 || (round == 0x1000 && half&1 == 1) {
+			// May carry into the exponent which correctly yields Inf
+			half++
+		}
+		return sign | uint16(half)
+	case exp-127 >= -25:
+		// Denormal half
+		mant |= 0x800000
+		shift := uint32(-(exp - 127) - 14 + 13)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	return sign
+}
+
+// halfToFloat converts an IEEE 754 half precision float to float32
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Normalize the denormal
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
@@ -0,0 +1,239 @@
+package imageio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+// toRGBE encodes a color as shared exponent RGBE
+func toRGBE(c tracer.Color) [4]byte {
+	v := max(c.R(), c.G(), c.B())
+	if v < 1e-32 {
+		return [4]byte{}
+	}
+	m, e := math32.Frexp(v)
+	scale := m * 256 / v
+	return [4]byte{
+		byte(max(c.R(), 0) * scale),
+		byte(max(c.G(), 0) * scale),
+		byte(max(c.B(), 0) * scale),
+		byte(e + 128),
+	}
+}
+
+// WriteHDR writes f as a Radiance RGBE image using run length encoded scanlines
+func WriteHDR(w io.Writer, f *tracer.Film) error {
+	width, height := f.Width(), f.Height()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+	scanline := make([][4]byte, width)
+	channel := make([]byte, width)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scanline[x] = toRGBE(f.At(x, y))
+		}
+		// Run length encoding is only defined for these widths
+		if width < 8 || width > 0x7fff {
+			for _, p := range scanline {
+				bw.Write(p[:])
+			}
+			continue
+		}
+		bw.Write([]byte{2, 2, byte(width >> 8), byte(width & 0xff)})
+		for c := 0; c < 4; c++ {
+			for x := range scanline {
+				channel[x] = scanline[x][c]
+			}
+			writeRLE(bw, channel)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeRLE encodes one channel of a scanline as a sequence of runs
+// (count > 128) and literal dumps (count <= 128)
+func writeRLE(w *bufio.Writer, data []byte) {
+	const minRun = 4
+	i := 0
+	for i < len(data) {
+		// Find the next run long enough to be worth encoding
+		begRun := i
+		runCount := 0
+		for runCount < minRun && begRun < len(data) {
+			begRun += runCount
+			runCount = 1
+			for begRun+runCount < len(data) && runCount < 127 && data[begRun] == data[begRun+runCount] {
+				runCount++
+			}
+		}
+		if runCount < minRun {
+			begRun = len(data)
+		}
+		// Dump the literal bytes before the run
+		for i < begRun {
+			n := min(begRun-i, 128)
+			w.WriteByte(byte(n))
+			w.Write(data[i : i+n])
+			i += n
+		}
+		if runCount >= minRun {
+			w.WriteByte(byte(128 + runCount))
+			w.WriteByte(data[begRun])
+			i += runCount
+		}
+	}
+}
+
+// fromRGBE decodes a shared exponent RGBE pixel the way Radiance does
+func fromRGBE(p [4]byte) tracer.Color {
+	if p[3] == 0 {
+		return tracer.Black
+	}
+	f := math32.Ldexp(1, int(p[3])-136)
+	return tracer.NewColor((float32(p[0])+0.5)*f, (float32(p[1])+0.5)*f, (float32(p[2])+0.5)*f)
+}
+
+const (
+	// maxHDRWidth bounds the scanlines of files read, which is twice
+	// as wide as run length encoded scanlines can be
+	maxHDRWidth = 1 << 16
+	// maxHDRPixels bounds the size of files read, 16384x16384 pixels
+	maxHDRPixels = 1 << 28
+)
+
+// ReadHDR decodes a Radiance RGBE image in the standard -Y +X orientation
+func ReadHDR(r io.Reader) (*tracer.Film, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(magic, "#?") {
+		return nil, errors.New("imageio: not a Radiance HDR file")
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("imageio: HDR header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("imageio: unsupported HDR format %q", format)
+		}
+	}
+	var width, height int
+	resolution, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("imageio: HDR resolution: %w", err)
+	}
+	if _, err := fmt.Sscanf(resolution, "-Y %d +X %d", &height, &width); err != nil ||
+		width <= 0 || height <= 0 || width > maxHDRWidth || height > maxHDRPixels/width {
+		return nil, fmt.Errorf("imageio: unsupported HDR resolution %q", strings.TrimSpace(resolution))
+	}
+	// The pixels grow with the scanlines read, so that a file
+	// claiming a large resolution fails before it takes up memory
+	var pixels [][4]byte
+	scanline := make([][4]byte, width)
+	for y := 0; y < height; y++ {
+		if err := readScanline(br, scanline); err != nil {
+			return nil, fmt.Errorf("imageio: HDR scanline %d: %w", y, err)
+		}
+		pixels = append(pixels, scanline...)
+	}
+	f := tracer.NewFilm(width, height)
+	for i, p := range pixels {
+		f.Set(i%width, i/width, fromRGBE(p))
+	}
+	return f, nil
+}
+
+// readScanline reads one scanline which is either run length encoded
+// per channel, flat, or encoded with the old repeat pixel scheme
+func readScanline(br *bufio.Reader, scanline [][4]byte) error {
+	width := len(scanline)
+	var p [4]byte
+	if _, err := io.ReadFull(br, p[:]); err != nil {
+		return err
+	}
+	if width < 8 || width > 0x7fff || p[0] != 2 || p[1] != 2 || p[2]&0x80 != 0 {
+		return readFlatScanline(br, scanline, p)
+	}
+	if int(p[2])<<8|int(p[3]) != width {
+		return errors.New("scanline width mismatch")
+	}
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			n, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if n > 128 {
+				count := int(n) - 128
+				if x+count > width {
+					return errors.New("run exceeds scanline")
+				}
+				v, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				for ; count > 0; count-- {
+					scanline[x][c] = v
+					x++
+				}
+				continue
+			}
+			if n == 0 || x+int(n) > width {
+				return errors.New("invalid literal run")
+			}
+			for ; n > 0; n-- {
+				v, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				scanline[x][c] = v
+				x++
+			}
+		}
+	}
+	return nil
+}
+
+// readFlatScanline reads uncompressed pixels starting with first, where
+// a pixel of 1, 1, 1, n repeats the previous pixel n times, shifted left
+// by 8 bits for every consecutive repeat pixel
+func readFlatScanline(br *bufio.Reader, scanline [][4]byte, first [4]byte) error {
+	p := first
+	shift := 0
+	for x := 0; x < len(scanline); {
+		if p[0] == 1 && p[1] == 1 && p[2] == 1 {
+			if x == 0 {
+				return errors.New("repeat at start of scanline")
+			}
+			count := int(p[3]) << shift
+			if x+count > len(scanline) {
+				return errors.New("repeat exceeds scanline")
+			}
+			for ; count > 0; count-- {
+				scanline[x] = scanline[x-1]
+				x++
+			}
+			shift += 8
+		} else {
+			scanline[x] = p
+			x++
+			shift = 0
+		}
+		if x < len(scanline) {
+			if _, err := io.ReadFull(br, p[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
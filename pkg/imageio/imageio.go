@@ -0,0 +1,87 @@
+// Package imageio writes rendered films to image files
+// and reads high dynamic range images
+package imageio
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+// Options control the encoding of Save
+type Options struct {
+	// Display is the display transform of 8 bit PNG files,
+	// floating point formats store linear radiance
+	Display tracer.DisplayTransform
+	// EXRPixelType is the channel type of OpenEXR files, defaults to Half
+	EXRPixelType PixelType
+	// EXRCompression is the compression of OpenEXR files
+	EXRCompression Compression
+}
+
+// DefaultOptions returns clamped sRGB PNG and half float, ZIP compressed EXR output
+func DefaultOptions() Options {
+	return Options{EXRPixelType: Half, EXRCompression: ZIPCompression}
+}
+
+// WritePNG writes f as a tone mapped 8 bit sRGB PNG image
+func WritePNG(w io.Writer, f *tracer.Film, dt tracer.DisplayTransform) error {
+	return png.Encode(w, f.Image(dt))
+}
+
+// Save writes f to filename choosing the format from the extension:
+// .exr for OpenEXR, .hdr for Radiance RGBE and .png for 8 bit PNG
+func Save(filename string, f *tracer.Film, opts Options) error {
+	var write func(io.Writer) error
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".png":
+		write = func(w io.Writer) error { return WritePNG(w, f, opts.Display) }
+	case ".hdr":
+		write = func(w io.Writer) error { return WriteHDR(w, f) }
+	case ".exr":
+		write = func(w io.Writer) error { return WriteEXR(w, f, opts.EXRPixelType, opts.EXRCompression) }
+	default:
+		return fmt.Errorf("imageio: unsupported output format %q", ext)
+	}
+
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Load reads the high dynamic range image name from fsys,
+// choosing the format from the extension: .exr or .hdr
+func Load(fsys fs.FS, name string) (*tracer.Film, error) {
+	var read func(io.Reader) (*tracer.Film, error)
+	switch ext := strings.ToLower(path.Ext(name)); ext {
+	case ".hdr":
+		read = ReadHDR
+	case ".exr":
+		read = ReadEXR
+	default:
+		return nil, fmt.Errorf("imageio: unsupported input format %q", ext)
+	}
+	in, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	f, err := read(in)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,301 @@
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+func TestFloatToHalf(t *testing.T) {
+	tests := []struct {
+		f    float32
+		want uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},
+		// Rounds up to the first value past the largest half
+		{65520, 0x7c00},
+		{1e6, 0x7c00},
+		{-1e6, 0xfc00},
+		// Halfway between 1 and the next half rounds to even
+		{1 + 1.0/2048, 0x3c00},
+		{1 + 3.0/2048, 0x3c02},
+		{float32(math.Ldexp(1, -14)), 0x0400},
+		{float32(math.Ldexp(1023, -24)), 0x03ff},
+		{float32(math.Ldexp(1, -24)), 0x0001},
+		{float32(math.Ldexp(3, -25)), 0x0002},
+		{float32(math.Ldexp(1, -25)), 0x0000},
+		{float32(math.Ldexp(1, -30)), 0x0000},
+		{float32(math.Inf(1)), 0x7c00},
+		{float32(math.Inf(-1)), 0xfc00},
+	}
+	for _, tt := range tests {
+		if got := floatToHalf(tt.f); got != tt.want {
+			t.Errorf("floatToHalf(%g) = %#04x, want %#04x", tt.f, got, tt.want)
+		}
+	}
+	if h := floatToHalf(float32(math.NaN())); h&0x7c00 != 0x7c00 || h&0x3ff == 0 {
+		t.Errorf("floatToHalf(NaN) = %#04x, want a NaN", h)
+	}
+}
+
+func TestHalfRoundTrip(t *testing.T) {
+	for i := 0; i <= 0xffff; i++ {
+		h := uint16(i)
+		f := halfToFloat(h)
+		if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+			if !math.IsNaN(float64(f)) {
+				t.Errorf("halfToFloat(%#04x) = %g, want NaN", h, f)
+			}
+			continue
+		}
+		if got := floatToHalf(f); got != h {
+			t.Errorf("floatToHalf(halfToFloat(%#04x) = %g) = %#04x", h, f, got)
+		}
+	}
+}
+
+// predict applies the interleaving and delta predictor which
+// precede the compression of ZIP and RLE chunks
+func predict(raw []byte) []byte {
+	tmp := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i, b := range raw {
+		if i%2 == 0 {
+			tmp[i/2] = b
+		} else {
+			tmp[half+i/2] = b
+		}
+	}
+	for i := len(tmp) - 1; i > 0; i-- {
+		tmp[i] = byte(int(tmp[i]) - int(tmp[i-1]) + 128)
+	}
+	return tmp
+}
+
+// encodeRLE run length encodes data like OpenEXR, with runs of three
+// or more equal bytes and literal dumps of everything else
+func encodeRLE(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		run := 1
+		for i+run < len(data) && run < 128 && data[i+run] == data[i] {
+			run++
+		}
+		if run >= 3 {
+			out = append(out, byte(run-1), data[i])
+			i += run
+			continue
+		}
+		j := i
+		for j < len(data) && j-i < 127 && !(j+2 < len(data) && data[j] == data[j+1] && data[j] == data[j+2]) {
+			j++
+		}
+		out = append(out, byte(int8(-(j - i))))
+		out = append(out, data[i:j]...)
+		i = j
+	}
+	return out
+}
+
+func testData(n int) []byte {
+	raw := make([]byte, n)
+	for i := range raw {
+		// Long runs and noise
+		if i%50 < 30 {
+			raw[i] = 7
+		} else {
+			raw[i] = byte(i * 31 % 251)
+		}
+	}
+	return raw
+}
+
+func TestDecompress(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 64, 1001} {
+		raw := testData(n)
+		zipped, err := zipCompress(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks := map[Compression][]byte{
+			NoCompression:   raw,
+			ZIPCompression:  zipped,
+			zipsCompression: zipped,
+			rleCompression:  encodeRLE(predict(raw)),
+		}
+		for compression, data := range chunks {
+			got, err := decompress(data, n, compression)
+			if err != nil {
+				t.Errorf("decompress %d bytes with compression %d: %v", n, compression, err)
+				continue
+			}
+			if !bytes.Equal(got, raw) {
+				t.Errorf("decompress %d bytes with compression %d differs", n, compression)
+			}
+		}
+	}
+	if _, err := decompress([]byte{5}, 10, rleCompression); err == nil {
+		t.Error("decompress accepted a truncated RLE chunk")
+	}
+}
+
+// testFilm returns a film of values which half floats represent
+// exactly, with zeros and values beyond the range of 8 bits
+func testFilm(width, height int) *tracer.Film {
+	f := tracer.NewFilm(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			f.Set(x, y, tracer.NewColor(float32(x)/4, float32(y)*16, float32((x+y)%3)/8))
+		}
+	}
+	return f
+}
+
+func TestEXRRoundTrip(t *testing.T) {
+	// 37 rows span several 16 line ZIP blocks
+	want := testFilm(7, 37)
+	for _, pixelType := range []PixelType{Half, Float} {
+		for _, compression := range []Compression{NoCompression, ZIPCompression} {
+			var buf bytes.Buffer
+			if err := WriteEXR(&buf, want, pixelType, compression); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadEXR(&buf)
+			if err != nil {
+				t.Fatalf("pixel type %d, compression %d: %v", pixelType, compression, err)
+			}
+			if got.Width() != want.Width() || got.Height() != want.Height() {
+				t.Fatalf("got %dx%d, want %dx%d", got.Width(), got.Height(), want.Width(), want.Height())
+			}
+			for y := 0; y < want.Height(); y++ {
+				for x := 0; x < want.Width(); x++ {
+					if got.At(x, y) != want.At(x, y) {
+						t.Fatalf("pixel type %d, compression %d: pixel %d,%d is %v, want %v",
+							pixelType, compression, x, y, got.At(x, y), want.At(x, y))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestHDRRoundTrip(t *testing.T) {
+	// Scanlines narrower than 8 pixels are written without run length encoding
+	for _, width := range []int{5, 40} {
+		want := testFilm(width, 3)
+		var buf bytes.Buffer
+		if err := WriteHDR(&buf, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadHDR(&buf)
+		if err != nil {
+			t.Fatalf("width %d: %v", width, err)
+		}
+		if got.Width() != want.Width() || got.Height() != want.Height() {
+			t.Fatalf("got %dx%d, want %dx%d", got.Width(), got.Height(), want.Width(), want.Height())
+		}
+		for y := 0; y < want.Height(); y++ {
+			for x := 0; x < want.Width(); x++ {
+				w, g := want.At(x, y), got.At(x, y)
+				// The shared exponent keeps 8 bits relative to the largest channel
+				tol := max(w.R(), w.G(), w.B()) / 128
+				if d := w.Sub(g.Vec3); max(abs(d.X()), abs(d.Y()), abs(d.Z())) > tol {
+					t.Fatalf("width %d: pixel %d,%d is %v, want %v", width, x, y, g, w)
+				}
+			}
+		}
+	}
+}
+
+func abs(f float32) float32 {
+	return float32(math.Abs(float64(f)))
+}
+
+// forgeEXR returns an EXR file written by WriteEXR whose data window
+// is replaced by window
+func forgeEXR(t *testing.T, window [4]int32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteEXR(&buf, testFilm(7, 37), Half, NoCompression); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	attr := []byte("dataWindow\x00box2i\x00")
+	i := bytes.Index(data, attr)
+	if i < 0 {
+		t.Fatal("no data window in the written file")
+	}
+	value := data[i+len(attr)+4:]
+	for j, v := range window {
+		binary.LittleEndian.PutUint32(value[4*j:], uint32(v))
+	}
+	return data
+}
+
+func TestReadEXRMalformed(t *testing.T) {
+	valid := forgeEXR(t, [4]int32{0, 0, 6, 36})
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not EXR", []byte("#?RADIANCE\n")},
+		{"truncated header", valid[:40]},
+		{"truncated pixels", valid[:len(valid)-10]},
+		{"huge window", forgeEXR(t, [4]int32{0, 0, 1e9, 1e9})},
+		// The extent of the window overflows int32
+		{"overflowing window", forgeEXR(t, [4]int32{math.MinInt32, math.MinInt32, math.MaxInt32, math.MaxInt32})},
+		{"empty window", forgeEXR(t, [4]int32{5, 0, 4, 36})},
+		{"too many rows", forgeEXR(t, [4]int32{0, 0, 6, 500})},
+		{"too wide", forgeEXR(t, [4]int32{0, 0, 5000, 36})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadEXR(bytes.NewReader(tt.data)); err == nil {
+				t.Error("ReadEXR accepted a malformed file")
+			}
+		})
+	}
+	if _, err := ReadEXR(bytes.NewReader(valid)); err != nil {
+		t.Errorf("ReadEXR rejected the unmodified file: %v", err)
+	}
+}
+
+func TestReadHDRMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHDR(&buf, testFilm(40, 3)); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.String()
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n"
+	tests := []struct {
+		name, data string
+	}{
+		{"empty", ""},
+		{"not HDR", "P6\n"},
+		{"other format", "#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x01\x01\x01\x80"},
+		{"flipped", header + "+Y 2 +X 2\n"},
+		{"zero width", header + "-Y 2 +X 0\n"},
+		// Both resolutions would take gigabytes but the files end at once
+		{"too many pixels", header + "-Y 100000 +X 60000\n"},
+		{"too wide", header + "-Y 1 +X 100000000\n"},
+		{"truncated", valid[:len(valid)-20]},
+		{"missing scanlines", header + "-Y 10000 +X 10000\n\x80\x80\x80\x80\x01\x01\x01\xff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadHDR(strings.NewReader(tt.data)); err == nil {
+				t.Error("ReadHDR accepted a malformed file")
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package obj
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+// MaterialDef holds the properties of a single material from an MTL file
+type MaterialDef struct {
+	Name string
+	// Kd, Ks and Ke are the diffuse, specular and emissive colors
+	Kd, Ks, Ke geo.Vec3
+	// Ns is the specular exponent
+	Ns float32
+	// Ni is the index of refraction
+	Ni float32
+	// D is the opacity, 1 is fully opaque
+	D float32
+	// Illum is the illumination model
+	Illum int
+	// MapKd is the path of the diffuse texture map
+	MapKd string
+
+	diffuseMap tracer.Texture
+}
+
+func newMaterialDef(name string) *MaterialDef {
+	return &MaterialDef{Name: name, Kd: geo.NewVec3(0.8, 0.8, 0.8), Ni: 1, D: 1, Illum: 2}
+}
+
+// Material maps d onto the closest tracer.Material: emissive materials
+// become a DiffuseLight, transparent ones (d < 1 or a refractive illum
+// model) a Dielectric, mirror-like ones (illum 3 or a specular but no
+// diffuse color) a Metal of color Ks, or Kd if Ks is black, and
+// everything else a Lambertian, textured with map_Kd if the map was
+// loaded by Parse.
+func (d *MaterialDef) Material() tracer.Material {
+	switch {
+	case d.Ke.LenSq() > 0:
+		return tracer.NewDiffuseLight(d.Ke.X(), d.Ke.Y(), d.Ke.Z())
+	case d.D < 1 || d.Illum == 4 || d.Illum == 6 || d.Illum == 7 || d.Illum == 9:
+		ior := d.Ni
+		if ior <= 1 {
+			ior = 1.5
+		}
+		return tracer.NewDielectric(ior)
+	case d.Illum == 3 || (d.Ks.LenSq() > 0 && d.Kd.LenSq() == 0):
+		// Map the Phong exponent onto the fuzz factor, high exponents are sharp reflections
+		fuzz := 1 - min(d.Ns, 1000)/1000
+		// Exporters often write illum 3 without Ks
+		albedo := d.Ks
+		if albedo.LenSq() == 0 {
+			albedo = d.Kd
+		}
+		if albedo.LenSq() == 0 {
+			albedo = geo.NewVec3(1, 1, 1)
+		}
+		return tracer.NewMetal(albedo.X(), albedo.Y(), albedo.Z(), fuzz*fuzz)
+	}
+	if d.diffuseMap != nil {
+		return tracer.NewLambertianTexture(d.diffuseMap)
+	}
+	return tracer.NewLambertian(d.Kd.X(), d.Kd.Y(), d.Kd.Z())
+}
+
+// DecodeMTL parses a material library from r, name is only used in error messages
+func DecodeMTL(r io.Reader, name string) (map[string]*MaterialDef, error) {
+	defs := make(map[string]*MaterialDef)
+	var cur *MaterialDef
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] != "newmtl" && cur == nil {
+			return nil, fmt.Errorf("%s:%d: %s before newmtl", name, lineNo, fields[0])
+		}
+		var err error
+		switch fields[0] {
+		case "newmtl":
+			if len(fields) != 2 {
+				err = fmt.Errorf("newmtl expects a name")
+				break
+			}
+			cur = newMaterialDef(fields[1])
+			defs[cur.Name] = cur
+		case "Kd":
+			cur.Kd, err = parseColor(fields[1:])
+		case "Ks":
+			cur.Ks, err = parseColor(fields[1:])
+		case "Ke":
+			cur.Ke, err = parseColor(fields[1:])
+		case "Ns":
+			cur.Ns, err = parseScalar(fields[1:])
+		case "Ni":
+			cur.Ni, err = parseScalar(fields[1:])
+		case "d":
+			cur.D, err = parseScalar(fields[1:])
+		case "Tr":
+			var tr float32
+			tr, err = parseScalar(fields[1:])
+			cur.D = 1 - tr
+		case "illum":
+			if len(fields) != 2 {
+				err = fmt.Errorf("illum expects one value")
+				break
+			}
+			cur.Illum, err = strconv.Atoi(fields[1])
+		case "map_Kd":
+			if len(fields) < 2 {
+				err = fmt.Errorf("map_Kd expects a file name")
+				break
+			}
+			// Options like -s or -o precede the file name which comes last
+			cur.MapKd = fields[len(fields)-1]
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return defs, nil
+}
+
+// parseColor parses an RGB triple, a single value is used for all channels
+func parseColor(fields []string) (geo.Vec3, error) {
+	if len(fields) == 1 {
+		f, err := parseScalar(fields)
+		return geo.NewVec3(f, f, f), err
+	}
+	return parseVec3(fields)
+}
@@ -0,0 +1,321 @@
+// Package obj loads Wavefront OBJ models and their MTL material libraries
+package obj
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+// Group is the part of a model sharing the same group name and material
+type Group struct {
+	Name     string
+	Material *MaterialDef
+	Mesh     *tracer.Mesh
+}
+
+// Model is a parsed OBJ file. All groups share the same
+// vertex, normal and texture coordinate arrays.
+type Model struct {
+	Groups []Group
+}
+
+// Hitables returns the triangles of all groups of m
+func (m *Model) Hitables() tracer.HitableList {
+	l := tracer.NewHitableList()
+	for _, g := range m.Groups {
+		l = append(l, g.Mesh.Triangles()...)
+	}
+	return l
+}
+
+// Load reads the OBJ file at filename together with
+// the material libraries it references
+func Load(filename string) (tracer.HitableList, error) {
+	m, err := Parse(os.DirFS(filepath.Dir(filename)), filepath.Base(filename))
+	if err != nil {
+		return nil, err
+	}
+	return m.Hitables(), nil
+}
+
+// Parse reads the OBJ file name from fsys. Material libraries
+// are looked up in fsys relative to the directory of name.
+func Parse(fsys fs.FS, name string) (*Model, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p := parser{
+		fsys:      fsys,
+		name:      name,
+		materials: make(map[string]*MaterialDef),
+		groups:    make(map[groupKey]int),
+	}
+	return p.parse(f)
+}
+
+type groupKey struct {
+	name     string
+	material *MaterialDef
+}
+
+type groupFaces struct {
+	key   groupKey
+	faces []tracer.MeshFace
+}
+
+type parser struct {
+	fsys      fs.FS
+	name      string
+	materials map[string]*MaterialDef
+	vertices  []geo.Vec3
+	normals   []geo.Vec3
+	uvs       [][2]float32
+	groups    map[groupKey]int
+	faces     []groupFaces
+	group     string
+	material  *MaterialDef
+}
+
+func (p *parser) parse(r io.Reader) (*Model, error) {
+	p.group = "default"
+	// Faces before the first usemtl, each parse gets its own
+	p.material = newMaterialDef("default")
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if err := p.parseLine(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", p.name, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", p.name, err)
+	}
+
+	model := &Model{}
+	materials := make(map[*MaterialDef]tracer.Material)
+	for _, g := range p.faces {
+		m, ok := materials[g.key.material]
+		if !ok {
+			m = g.key.material.Material()
+			materials[g.key.material] = m
+		}
+		mesh := tracer.NewMesh(p.vertices, p.normals, p.uvs, m)
+		for _, f := range g.faces {
+			// Indices have been checked while parsing
+			if err := mesh.AddFace(f); err != nil {
+				return nil, fmt.Errorf("%s: %w", p.name, err)
+			}
+		}
+		model.Groups = append(model.Groups, Group{Name: g.key.name, Material: g.key.material, Mesh: mesh})
+	}
+	return model, nil
+}
+
+func (p *parser) parseLine(line string) error {
+	fields := strings.Fields(stripComment(line))
+	if len(fields) == 0 {
+		return nil
+	}
+	switch fields[0] {
+	case "v":
+		if len(fields) < 4 {
+			return errors.New("vertex needs 3 coordinates")
+		}
+		v, err := parseVec3(fields[1:4])
+		if err != nil {
+			return err
+		}
+		p.vertices = append(p.vertices, v)
+	case "vn":
+		v, err := parseVec3(fields[1:])
+		if err != nil {
+			return err
+		}
+		p.normals = append(p.normals, v)
+	case "vt":
+		if len(fields) < 2 {
+			return errors.New("texture coordinate needs at least one value")
+		}
+		var uv [2]float32
+		for i := 0; i < 2 && i+1 < len(fields); i++ {
+			c, err := strconv.ParseFloat(fields[i+1], 32)
+			if err != nil {
+				return err
+			}
+			uv[i] = float32(c)
+		}
+		p.uvs = append(p.uvs, uv)
+	case "f":
+		return p.parseFace(fields[1:])
+	case "g", "o":
+		if len(fields) > 1 {
+			p.group = strings.Join(fields[1:], " ")
+		} else {
+			p.group = "default"
+		}
+	case "usemtl":
+		if len(fields) != 2 {
+			return errors.New("usemtl expects a material name")
+		}
+		m, ok := p.materials[fields[1]]
+		if !ok {
+			return fmt.Errorf("unknown material %q", fields[1])
+		}
+		p.material = m
+	case "mtllib":
+		for _, lib := range fields[1:] {
+			if err := p.loadMaterials(lib); err != nil {
+				return err
+			}
+		}
+	}
+	// Everything else (smoothing groups, lines, points, ...) is ignored
+	return nil
+}
+
+func (p *parser) loadMaterials(lib string) error {
+	name := path.Join(path.Dir(p.name), filepath.ToSlash(lib))
+	f, err := p.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	defs, err := DecodeMTL(f, name)
+	if err != nil {
+		return err
+	}
+	for k, v := range defs {
+		if v.MapKd != "" {
+			texName := path.Join(path.Dir(name), filepath.ToSlash(v.MapKd))
+			if v.diffuseMap, err = tracer.LoadImageTexture(p.fsys, texName, tracer.WrapRepeat); err != nil {
+				return fmt.Errorf("%s: material %s: %w", name, k, err)
+			}
+		}
+		p.materials[k] = v
+	}
+	return nil
+}
+
+// parseFace triangulates a polygon as a fan around its first vertex
+func (p *parser) parseFace(corners []string) error {
+	if len(corners) < 3 {
+		return errors.New("face needs at least 3 vertices")
+	}
+	v := make([]int32, len(corners))
+	t := make([]int32, len(corners))
+	n := make([]int32, len(corners))
+	hasUV, hasNormal := true, true
+	for i, c := range corners {
+		var err error
+		if v[i], t[i], n[i], err = p.parseCorner(c); err != nil {
+			return err
+		}
+		hasUV = hasUV && t[i] != tracer.NoIndex
+		hasNormal = hasNormal && n[i] != tracer.NoIndex
+	}
+	key := groupKey{p.group, p.material}
+	gi, ok := p.groups[key]
+	if !ok {
+		gi = len(p.faces)
+		p.groups[key] = gi
+		p.faces = append(p.faces, groupFaces{key: key})
+	}
+	for i := 1; i+1 < len(corners); i++ {
+		f := tracer.MeshFace{
+			V: [3]int32{v[0], v[i], v[i+1]},
+			T: [3]int32{tracer.NoIndex, tracer.NoIndex, tracer.NoIndex},
+			N: [3]int32{tracer.NoIndex, tracer.NoIndex, tracer.NoIndex},
+		}
+		if hasUV {
+			f.T = [3]int32{t[0], t[i], t[i+1]}
+		}
+		if hasNormal {
+			f.N = [3]int32{n[0], n[i], n[i+1]}
+		}
+		p.faces[gi].faces = append(p.faces[gi].faces, f)
+	}
+	return nil
+}
+
+// parseCorner parses a face corner of the form v, v/vt, v//vn or v/vt/vn
+func (p *parser) parseCorner(s string) (v, t, n int32, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 3 {
+		return 0, 0, 0, fmt.Errorf("invalid face vertex %q", s)
+	}
+	t, n = tracer.NoIndex, tracer.NoIndex
+	if v, err = resolveIndex(parts[0], len(p.vertices)); err != nil {
+		return
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if t, err = resolveIndex(parts[1], len(p.uvs)); err != nil {
+			return
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if n, err = resolveIndex(parts[2], len(p.normals)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// resolveIndex converts a one based, possibly negative (relative)
+// OBJ index into a zero based index into an array of length count
+func resolveIndex(s string, count int) (int32, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid index %q", s)
+	}
+	switch {
+	case i > 0 && i <= count:
+		return int32(i - 1), nil
+	case i < 0 && -i <= count:
+		return int32(count + i), nil
+	}
+	return 0, fmt.Errorf("index %d out of range", i)
+}
+
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+func parseScalar(fields []string) (float32, error) {
+	if len(fields) != 1 {
+		return 0, errors.New("expected one value")
+	}
+	f, err := strconv.ParseFloat(fields[0], 32)
+	return float32(f), err
+}
+
+func parseVec3(fields []string) (geo.Vec3, error) {
+	if len(fields) != 3 {
+		return geo.Vec3{}, errors.New("expected three values")
+	}
+	var c [3]float32
+	for i := range c {
+		f, err := strconv.ParseFloat(fields[i], 32)
+		if err != nil {
+			return geo.Vec3{}, err
+		}
+		c[i] = float32(f)
+	}
+	return geo.NewVec3(c[0], c[1], c[2]), nil
+}
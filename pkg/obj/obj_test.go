@@ -0,0 +1,201 @@
+package obj
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+const square = `v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+`
+
+var none = [3]int32{tracer.NoIndex, tracer.NoIndex, tracer.NoIndex}
+
+func TestParseFaces(t *testing.T) {
+	tests := []struct {
+		name  string
+		faces string
+		want  []tracer.MeshFace
+	}{
+		{"triangle", "f 1 2 3", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: none, T: none},
+		}},
+		{"negative indices", "f -4 -3 -2", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: none, T: none},
+		}},
+		{"fan", "f 1 2 3 4", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: none, T: none},
+			{V: [3]int32{0, 2, 3}, N: none, T: none},
+		}},
+		{"uvs and normals", "f 1/1/1 2/2/1 3/3/1", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: [3]int32{0, 0, 0}, T: [3]int32{0, 1, 2}},
+		}},
+		{"normals only", "f 1//1 2//1 3//1 4//1", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: [3]int32{0, 0, 0}, T: none},
+			{V: [3]int32{0, 2, 3}, N: [3]int32{0, 0, 0}, T: none},
+		}},
+		{"negative uvs and normals", "f -4/-4/-1 -3/-3/-1 -2/-2/-1", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: [3]int32{0, 0, 0}, T: [3]int32{0, 1, 2}},
+		}},
+		// Attributes missing at some corners are dropped for the whole face
+		{"mixed corners", "f 1/1/1 2/2 3//1", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: none, T: none},
+		}},
+		{"mixed corners in a fan", "f 1/1 2/2 3/3 4", []tracer.MeshFace{
+			{V: [3]int32{0, 1, 2}, N: none, T: none},
+			{V: [3]int32{0, 2, 3}, N: none, T: none},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser{name: "test.obj", groups: make(map[groupKey]int)}
+			if _, err := p.parse(strings.NewReader(square + tt.faces)); err != nil {
+				t.Fatal(err)
+			}
+			if len(p.faces) != 1 {
+				t.Fatalf("got %d groups, want 1", len(p.faces))
+			}
+			if got := p.faces[0].faces; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got faces %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"short vertex", "v 1 2", "test.obj:1: vertex needs 3 coordinates"},
+		{"bad normal", "v 0 0 0\nvn 0 x 1", "test.obj:2: "},
+		{"short face", square + "f 1 2", "test.obj:10: face needs at least 3 vertices"},
+		{"zero index", square + "f 0 1 2", "test.obj:10: index 0 out of range"},
+		{"index out of range", square + "\nf 1 2 5", "test.obj:11: index 5 out of range"},
+		{"negative index out of range", square + "f -5 1 2", "test.obj:10: index -5 out of range"},
+		{"normal out of range", square + "f 1//2 2//2 3//2", "test.obj:10: index 2 out of range"},
+		{"invalid index", square + "f 1 a 3", `test.obj:10: invalid index "a"`},
+		{"invalid corner", square + "f 1/1/1/1 2 3", `test.obj:10: invalid face vertex "1/1/1/1"`},
+		{"unknown material", "usemtl steel", `test.obj:1: unknown material "steel"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser{name: "test.obj", groups: make(map[groupKey]int)}
+			_, err := p.parse(strings.NewReader(tt.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	fsys := fstest.MapFS{
+		"models/box.obj": {Data: []byte("mtllib box.mtl\n" + square + `g front
+usemtl red
+f 1 2 3
+g back
+f 1 3 4
+usemtl blue
+f 1 2 4
+g front
+usemtl red
+f 2 3 4
+`)},
+		"models/box.mtl": {Data: []byte("newmtl red\nKd 1 0 0\nnewmtl blue\nKd 0 0 1\n")},
+	}
+	m, err := Parse(fsys, "models/box.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type group struct {
+		name, material string
+		faces          int
+	}
+	want := []group{{"front", "red", 2}, {"back", "red", 1}, {"back", "blue", 1}}
+	var got []group
+	for _, g := range m.Groups {
+		got = append(got, group{g.Name, g.Material.Name, g.Mesh.Len()})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+}
+
+func TestDecodeMTLErrors(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"before newmtl", "Kd 1 1 1", "lib.mtl:1: Kd before newmtl"},
+		{"bad color", "newmtl a\n\nKd 1 1", "lib.mtl:3: expected three values"},
+		{"bad scalar", "newmtl a\nNs 1 2", "lib.mtl:2: expected one value"},
+		{"bad illum", "newmtl a\nillum x", "lib.mtl:2: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeMTL(strings.NewReader(tt.input), "lib.mtl")
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaterial(t *testing.T) {
+	tests := []struct {
+		name, mtl string
+		want      tracer.Material
+	}{
+		{"default", "", tracer.NewLambertian(0.8, 0.8, 0.8)},
+		{"diffuse", "Kd 0.2 0.4 0.6", tracer.NewLambertian(0.2, 0.4, 0.6)},
+		{"specular highlight", "Kd 0.2 0.4 0.6\nKs 1 1 1\nillum 2", tracer.NewLambertian(0.2, 0.4, 0.6)},
+		{"emissive", "Ke 4 3 2", tracer.NewDiffuseLight(4, 3, 2)},
+		{"emissive and transparent", "Ke 1 1 1\nd 0.5", tracer.NewDiffuseLight(1, 1, 1)},
+		{"transparent", "d 0.5\nNi 1.33", tracer.NewDielectric(1.33)},
+		{"transparent without index", "Tr 0.5", tracer.NewDielectric(1.5)},
+		{"refractive illum", "illum 7\nNi 2.4", tracer.NewDielectric(2.4)},
+		{"mirror", "Ks 0.9 0.8 0.7\nNs 1000\nillum 3", tracer.NewMetal(0.9, 0.8, 0.7, 0)},
+		// Ns 500 gives a fuzz of 0.5², Ns 0 the largest fuzz
+		{"mirror without Ks", "Kd 0.5 0.1 0.1\nNs 500\nillum 3", tracer.NewMetal(0.5, 0.1, 0.1, 0.25)},
+		{"mirror without colors", "Kd 0 0 0\nillum 3", tracer.NewMetal(1, 1, 1, 1)},
+		{"specular without diffuse", "Kd 0 0 0\nKs 1 1 1\nNs 1000", tracer.NewMetal(1, 1, 1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defs, err := DecodeMTL(strings.NewReader("newmtl m\n"+tt.mtl), "lib.mtl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := defs["m"].Material(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got material %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultMaterialPerParse(t *testing.T) {
+	fsys := fstest.MapFS{"tri.obj": {Data: []byte(square + "f 1 2 3\n")}}
+	first, err := Parse(fsys, "tri.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Groups[0].Material.Kd = geo.NewVec3(1, 0, 0)
+	second, err := Parse(fsys, "tri.obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.Groups[0].Material.Kd; got != geo.NewVec3(0.8, 0.8, 0.8) {
+		t.Errorf("changing the default material of one model changed the next to %v", got)
+	}
+}
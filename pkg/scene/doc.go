@@ -0,0 +1,185 @@
+/*
+Package scene loads scene descriptions from JSON files.
+
+A scene file is a single JSON object. Vectors and colors are arrays of
+three numbers. All sections except "shapes" are optional.
+
+	{
+	  "render": {
+	    "width": 600,          // X resolution, default 600
+	    "height": 400,         // Y resolution, default 400
+	    "samples": 10,         // samples per pixel, default 10
+	    "maxDepth": 50,        // maximum number of bounces, default 50
+	    "seed": 0,             // seed of the sampling, default 0
+	    "spectral": false      // render wavelengths to show dispersion, default false
+	  },
+	  "display": {               // only applies to 8 bit output
+	    "exposure": 0,           // in stops
+	    "toneMap": "aces",       // linear (default), reinhard, reinhard-extended, aces or hable
+	    "whitePoint": 4          // for reinhard-extended and hable
+	  },
+	  "camera": {
+	    "type": "perspective",   // perspective (default), orthographic, fisheye, equirectangular or cylindrical
+	    "lookFrom": [13, 2, 3],  // required
+	    "lookAt": [0, 0, 0],     // required
+	    "up": [0, 1, 0],         // default [0, 1, 0]
+	    "fov": 20,               // vertical field of view in degrees, default 40
+	    "aperture": 0.1,         // lens diameter, perspective only, default 0 (pinhole)
+	    "focusDist": 10,         // perspective only, default distance from lookFrom to lookAt
+	    "shutter": [0, 1]        // open and close time for motion blur, default [0, 0]
+	  },
+	  "background": [0, 0, 0],   // color or environment, default is a blue/white sky gradient
+	  "atmosphere": {            // homogeneous fog filling the scene
+	    "density": 0.01,         // scattering events per unit length
+	    "albedo": [1, 1, 1],     // default [1, 1, 1]
+	    "g": 0.3,                // Henyey-Greenstein asymmetry, default 0 (isotropic)
+	    "extent": 0              // distance escaping rays travel through it, default 0
+	  },
+	  "textures": {
+	    "checker": {"type": "checker", "odd": [0.2, 0.3, 0.1], "even": [0.9, 0.9, 0.9], "scale": 2},
+	    "earth": {"type": "image", "file": "earth.jpg", "wrap": "repeat"},
+	    "marble": {"type": "noise", "kind": "marble", "scale": 4, "seed": 1}
+	  },
+	  "materials": {
+	    "ground": {"type": "lambertian", "albedo": "checker"},
+	    "globe": {"type": "lambertian", "albedo": "earth"},
+	    "steel": {"type": "metal", "albedo": [0.7, 0.6, 0.5], "fuzz": 0.1},
+	    "glass": {"type": "dielectric", "ior": 1.5},
+	    "gold": {"type": "conductor", "metal": "gold", "roughness": 0.3},
+	    "paint": {"type": "principled", "baseColor": [0.6, 0.1, 0.1], "clearcoat": 1},
+	    "lamp": {"type": "diffuse_light", "emit": [4, 4, 4]}
+	  },
+	  "objects": {
+	    "teapot": {"type": "mesh", "file": "teapot.obj", "material": "steel"}
+	  },
+	  "shapes": [
+	    {"type": "sphere", "center": [0, -1000, 0], "radius": 1000, "material": "ground"},
+	    {"type": "sphere", "center": [0, 1, 0], "radius": 1, "material": {"type": "dielectric", "ior": 1.5}},
+	    {"type": "moving_sphere", "center0": [2, 0.5, 0], "center1": [2, 0.8, 0], "time0": 0, "time1": 1, "radius": 0.5, "material": "steel"},
+	    {"type": "triangle", "vertices": [[0, 0, 0], [1, 0, 0], [0, 1, 0]], "material": "steel"},
+	    {"type": "xz_rect", "x": [-5, 5], "z": [-5, 5], "k": 0, "material": "ground"},
+	    {"type": "quad", "q": [0, 0, 0], "u": [1, 0, 0], "v": [0, 1, 0], "material": "steel"},
+	    {"type": "disk", "center": [0, 2, 0], "normal": [0, 1, 0], "radius": 1, "material": "steel"},
+	    {"type": "box", "min": [0, 0, 0], "max": [1, 2, 1], "material": "steel"},
+	    {"type": "mesh", "file": "teapot.obj"},
+	    {"type": "constant_medium", "boundary": {"type": "box", "min": [0, 0, 0], "max": [1, 1, 1], "material": "steel"},
+	     "density": 2, "albedo": [0.8, 0.8, 0.8], "g": 0},
+	    {"type": "instance", "object": "teapot", "transform": [
+	      {"scale": [2, 2, 2]},
+	      {"rotate": {"axis": [0, 1, 0], "angle": 45}},
+	      {"translate": [3, 0, 0]}
+	    ]}
+	  ],
+	  "lights": [
+	    {"type": "sphere", "center": [0, 10, 0], "radius": 2, "emit": [4, 4, 4]},
+	    {"type": "point", "position": [0, 5, 0], "intensity": [50, 50, 50], "radius": 0.1},
+	    {"type": "spot", "position": [0, 5, 5], "target": [0, 0, 0], "intensity": [200, 200, 200], "angle": 30, "innerAngle": 20},
+	    {"type": "directional", "direction": [-1, -1, 0], "irradiance": [2, 2, 2]}
+	  ]
+	}
+
+Cameras other than "perspective" have no lens. An "orthographic" camera
+sees "height" world units from the bottom to the top of the image, by
+default what a perspective camera with the same "fov" sees at lookAt.
+The "fov" of a "fisheye" camera spans the diagonal of the image, up to
+360 degrees (default 180), with an "equidistant" (default) or
+"equisolid" "projection". An "equirectangular" camera renders all
+directions around lookFrom into an image twice as wide as high, centered
+on lookAt. A "cylindrical" panorama spans a horizontal "fov" of up to 360
+degrees (default 360). Both panoramas keep their horizon perpendicular
+to "up".
+
+Materials are defined once in "materials" and referenced by name, or
+given inline wherever a material is expected. The same holds for textures,
+which are accepted wherever a color can vary over a surface ("albedo" and
+"emit"); a plain color is a solid texture. Texture types are "solid"
+(color), "checker" (odd, even, scale), "image" (file, wrap: "repeat",
+"clamp" or "mirror") and "noise" (kind: "perlin", "turbulence" or
+"marble", scale, seed). Rectangles "xy_rect", "xz_rect"
+and "yz_rect" span the ranges given for their two axes at offset "k"
+along the third axis, their normal points towards the positive third
+axis. A "quad" is the parallelogram with corner "q" and edges "u" and
+"v", its normal is u x v. A "disk" faces along its "normal". Meshes are
+loaded from Wavefront OBJ files relative to the scene file and use the
+materials of their MTL library unless a "material" is given. Lights take the same
+shape types as "shapes" (except meshes) with an "emit" radiance instead
+of a material. Spheres, triangles, rectangles, quads and disks among the
+lights are sampled explicitly, which makes small lights converge much
+faster than emitting shapes listed under "shapes".
+
+Lights which are not shapes cannot be seen, only their light. A "point"
+light shines with "intensity" (power per solid angle) in all directions,
+a "radius" makes it a sphere casting soft shadows. A "spot" light is a
+point light at "position" shining towards "target" into a cone of half
+"angle" degrees, fading out from "innerAngle" (default angle). A
+"directional" light travels along "direction" from infinitely far away
+and gives "irradiance" to surfaces facing it.
+
+Point and spot lights can be shaped by the IES LM-63 photometric file
+"ies", read relative to the scene file. Their "intensity" then scales the
+candela values of the file, which are large, so it is usually small. The
+nadir of the file points straight down for point lights unless "nadir"
+gives another direction, and towards the target for spot lights.
+Horizontal angle 0 of a downward nadir lies along the x axis.
+
+The "background" is either a color or an environment: {"type":
+"constant", "color": [...]}, {"type": "gradient", "bottom": [...],
+"top": [...]} or an equirectangular image {"type": "image", "file":
+"sky.hdr", "rotation": 90, "intensity": 1} read from a Radiance .hdr or
+OpenEXR .exr file relative to the scene file. The center of the image
+looks down the negative z axis before it is rotated counter clockwise
+about the y axis by "rotation" degrees. Images are sampled like lights,
+preferring their bright parts.
+
+A daylight {"type": "sky"} with the sun follows the Preetham model for a
+"turbidity" between 1.7 and 10 (default 3). The sun is at "elevation"
+degrees above the horizon (default 45) and "azimuth" degrees clockwise
+from north, which is the negative z axis with east along positive x.
+Alternatively it is placed by "latitude" and "longitude" in degrees and
+an RFC 3339 "time" such as "2024-06-21T15:00:00+02:00". Its radiance is
+scaled by "intensity" like that of images.
+
+Objects are shapes which are not rendered themselves but placed any
+number of times by instances, sharing their geometry. An "instance"
+refers to an object by name (or defines one inline) and applies the
+"transform" steps "translate", "scale", "rotate" (axis and angle in
+degrees) or "matrix" (16 values, row major) in the order listed.
+
+A "constant_medium" fills the closed shape "boundary" (an object name
+or inline shape, whose material is ignored) with a scattering volume of
+the given "density", "albedo" and Henyey-Greenstein asymmetry "g".
+
+Besides "lambertian" (albedo), "metal" (albedo, fuzz), "dielectric" (ior)
+and "diffuse_light" (emit) there are two physically based materials with
+a "roughness" between 0 and 1. A "conductor" is a GGX microfacet metal
+whose complex index of refraction is either a named "metal" ("gold",
+"copper" or "aluminium") or given as "eta" and "k" per color channel. A
+"rough_dielectric" is frosted glass with refractive index "ior". Both
+dielectrics take an optional "absorption" with a "color" which is left
+of white light after travelling "distance" inside, for tinted glass
+and liquids.
+
+Instead of "ior" a "dielectric" may take a "dispersion", either the name
+of a glass ("bk7", "fused_silica" or "diamond") or a formula with the
+wavelength in micrometers: {"type": "cauchy", "a": 1.5, "b": 0.004} or
+{"type": "sellmeier", "b": [...], "c": [...]}. Dispersion splits light
+into its colors only in spectral rendering, otherwise the index at
+589.3 nm is used.
+
+The "principled" material combines all of these following the Disney
+principled BSDF. Its parameters "metallic", "roughness" (0.5),
+"specular" (0.5), "specularTint", "sheen", "sheenTint" (0.5),
+"clearcoat", "clearcoatGloss" (1), "transmission" and "anisotropic"
+range from 0 to 1 and default to 0 unless noted otherwise, "baseColor"
+is a texture and "ior" (1.5) the refractive index of the transmitting
+part. Anisotropic highlights stretch along the direction in which the
+first texture coordinate increases, around the vertical axis of spheres.
+Materials exported from glTF use type "gltf" with the
+pbrMetallicRoughness names "baseColorFactor" (RGBA), "baseColorTexture",
+"metallicFactor" and "roughnessFactor", as well as "transmissionFactor"
+and "ior" of the corresponding extensions, defaulting as in glTF.
+
+Errors are reported with the JSON path of the offending value, e.g.
+"shapes[3].radius: must be positive".
+*/
+package scene
@@ -0,0 +1,146 @@
+package scene
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/robquant/tracer/pkg/imageio"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+type constantSpec struct {
+	Type  string `json:"type"`
+	Color vec    `json:"color"`
+}
+
+type gradientSpec struct {
+	Type   string `json:"type"`
+	Bottom vec    `json:"bottom"`
+	Top    vec    `json:"top"`
+}
+
+type environmentMapSpec struct {
+	Type      string   `json:"type"`
+	File      string   `json:"file"`
+	Rotation  float32  `json:"rotation"`
+	Intensity *float32 `json:"intensity"`
+}
+
+// skySpec places the sun either by elevation and azimuth
+// or by latitude, longitude and time
+type skySpec struct {
+	Type      string   `json:"type"`
+	Turbidity *float32 `json:"turbidity"`
+	Elevation *float32 `json:"elevation"`
+	Azimuth   float32  `json:"azimuth"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Time      string   `json:"time"`
+	Intensity *float32 `json:"intensity"`
+}
+
+func (s *skySpec) build(path string) (*tracer.SunSky, error) {
+	turbidity := float32(3)
+	if s.Turbidity != nil {
+		if turbidity = *s.Turbidity; turbidity < 1.7 || turbidity > 10 {
+			return nil, errorf(join(path, "turbidity"), "must be between 1.7 and 10")
+		}
+	}
+	elevation, azimuth := float32(45), s.Azimuth
+	if s.Time != "" {
+		if s.Elevation != nil {
+			return nil, errorf(join(path, "elevation"), "cannot be combined with time")
+		}
+		t, err := time.Parse(time.RFC3339, s.Time)
+		if err != nil {
+			return nil, &Error{Path: join(path, "time"), Err: err}
+		}
+		if s.Latitude < -90 || s.Latitude > 90 {
+			return nil, errorf(join(path, "latitude"), "must be between -90 and 90")
+		}
+		elevation, azimuth = tracer.SunPosition(s.Latitude, s.Longitude, t)
+	} else if s.Elevation != nil {
+		if elevation = *s.Elevation; elevation < -90 || elevation > 90 {
+			return nil, errorf(join(path, "elevation"), "must be between -90 and 90")
+		}
+	}
+	sky := tracer.NewSunSky(tracer.SunDirection(elevation, azimuth), turbidity)
+	if s.Intensity != nil {
+		if *s.Intensity < 0 {
+			return nil, errorf(join(path, "intensity"), "must not be negative")
+		}
+		sky.SetIntensity(*s.Intensity)
+	}
+	return sky, nil
+}
+
+// environment decodes a background which is either
+// a color or an inline environment definition
+func (l *loader) environment(raw json.RawMessage, path string) (tracer.Environment, error) {
+	var c vec
+	if err := json.Unmarshal(raw, &c); err == nil {
+		col, err := color(c, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewConstantEnvironment(tracer.Color{Vec3: col}), nil
+	}
+	t, err := typeOf(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "constant":
+		var s constantSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		col, err := color(s.Color, join(path, "color"))
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewConstantEnvironment(tracer.Color{Vec3: col}), nil
+	case "gradient":
+		var s gradientSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		bottom, err := color(s.Bottom, join(path, "bottom"))
+		if err != nil {
+			return nil, err
+		}
+		top, err := color(s.Top, join(path, "top"))
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewGradientEnvironment(tracer.Color{Vec3: bottom}, tracer.Color{Vec3: top}), nil
+	case "image":
+		var s environmentMapSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		if s.File == "" {
+			return nil, errorf(join(path, "file"), "missing")
+		}
+		film, err := imageio.Load(l.fsys, l.resolve(s.File))
+		if err != nil {
+			return nil, &Error{Path: join(path, "file"), Err: err}
+		}
+		env := tracer.NewEnvironmentMap(film)
+		env.SetRotation(s.Rotation)
+		if s.Intensity != nil {
+			if *s.Intensity < 0 {
+				return nil, errorf(join(path, "intensity"), "must not be negative")
+			}
+			env.SetIntensity(*s.Intensity)
+		}
+		return env, nil
+	case "sky":
+		var s skySpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		return s.build(path)
+	}
+	return nil, errorf(join(path, "type"), "unknown background %q", t)
+}
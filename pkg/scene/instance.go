@@ -0,0 +1,124 @@
+package scene
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+type instanceSpec struct {
+	Type      string          `json:"type"`
+	Object    json.RawMessage `json:"object"`
+	Transform []transformSpec `json:"transform"`
+}
+
+type rotateSpec struct {
+	Axis  vec     `json:"axis"`
+	Angle float32 `json:"angle"`
+}
+
+// transformSpec is a single step of a transform, exactly one field must be set
+type transformSpec struct {
+	Translate vec         `json:"translate"`
+	Scale     vec         `json:"scale"`
+	Rotate    *rotateSpec `json:"rotate"`
+	Matrix    []float32   `json:"matrix"`
+}
+
+func (t *transformSpec) matrix(path string) (geo.Mat4, error) {
+	set := 0
+	for _, isSet := range []bool{t.Translate != nil, t.Scale != nil, t.Rotate != nil, t.Matrix != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return geo.Mat4{}, errorf(path, "expected exactly one of translate, scale, rotate or matrix")
+	}
+	switch {
+	case t.Translate != nil:
+		v, err := t.Translate.toVec3(join(path, "translate"))
+		return geo.Translation(v), err
+	case t.Scale != nil:
+		v, err := t.Scale.toVec3(join(path, "scale"))
+		if err == nil && (v.X() == 0 || v.Y() == 0 || v.Z() == 0) {
+			err = errorf(join(path, "scale"), "must not be zero")
+		}
+		return geo.Scaling(v), err
+	case t.Rotate != nil:
+		axis, err := t.Rotate.Axis.required(join(path, "rotate.axis"))
+		if err != nil {
+			return geo.Mat4{}, err
+		}
+		if axis.LenSq() == 0 {
+			return geo.Mat4{}, errorf(join(path, "rotate.axis"), "must not be zero")
+		}
+		return geo.Rotation(axis, t.Rotate.Angle), nil
+	}
+	if len(t.Matrix) != 16 {
+		return geo.Mat4{}, errorf(join(path, "matrix"), "expected 16 values in row major order")
+	}
+	var rows [4][4]float32
+	for i, v := range t.Matrix {
+		rows[i/4][i%4] = v
+	}
+	return geo.NewMat4(rows), nil
+}
+
+// object resolves an object reference which is either the name of an
+// entry in "objects" or an inline shape definition
+func (l *loader) object(raw json.RawMessage, path string) (tracer.Hitable, error) {
+	if len(raw) == 0 {
+		return nil, errorf(path, "missing")
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		o, ok := l.objects[name]
+		if !ok {
+			return nil, errorf(path, "unknown object %q", name)
+		}
+		return o, nil
+	}
+	return l.decodeObject(raw, path)
+}
+
+// decodeObject builds a shape into its own bounding volume hierarchy,
+// so that it can be shared by many instances
+func (l *loader) decodeObject(raw json.RawMessage, path string) (tracer.Hitable, error) {
+	hitables, err := l.decodeShape(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(hitables) == 1 {
+		return hitables[0], nil
+	}
+	bvh := tracer.NewBvhNodeFromList(hitables)
+	return &bvh, nil
+}
+
+func (l *loader) decodeInstance(raw json.RawMessage, path string) (tracer.Hitable, error) {
+	var s instanceSpec
+	if err := decodeStrict(raw, path, &s); err != nil {
+		return nil, err
+	}
+	object, err := l.object(s.Object, join(path, "object"))
+	if err != nil {
+		return nil, err
+	}
+	m := geo.Identity()
+	for i, t := range s.Transform {
+		step, err := t.matrix(fmt.Sprintf("%s.transform[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		// Steps are applied in the order they are listed
+		m = step.Mul(m)
+	}
+	in, err := tracer.NewInstance(object, m)
+	if err != nil {
+		return nil, &Error{Path: join(path, "transform"), Err: err}
+	}
+	return in, nil
+}
@@ -0,0 +1,150 @@
+package scene
+
+import (
+	"encoding/json"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/ies"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+type pointLightSpec struct {
+	Type      string  `json:"type"`
+	Position  vec     `json:"position"`
+	Intensity vec     `json:"intensity"`
+	Radius    float32 `json:"radius"`
+	IES       string  `json:"ies"`
+	Nadir     vec     `json:"nadir"`
+}
+
+type spotLightSpec struct {
+	Type       string   `json:"type"`
+	Position   vec      `json:"position"`
+	Target     vec      `json:"target"`
+	Intensity  vec      `json:"intensity"`
+	Angle      float32  `json:"angle"`
+	InnerAngle *float32 `json:"innerAngle"`
+	IES        string   `json:"ies"`
+}
+
+type directionalLightSpec struct {
+	Type       string `json:"type"`
+	Direction  vec    `json:"direction"`
+	Irradiance vec    `json:"irradiance"`
+}
+
+// punctualLights decode lights which are not shapes
+var punctualLights = map[string]func(l *loader, raw json.RawMessage, path string) (tracer.Light, error){
+	"point":       (*loader).decodePointLight,
+	"spot":        (*loader).decodeSpotLight,
+	"directional": (*loader).decodeDirectionalLight,
+}
+
+// profile loads the photometric file name, or returns nil if it is empty
+func (l *loader) profile(name, path string) (*ies.Profile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	p, err := ies.Load(l.fsys, l.resolve(name))
+	if err != nil {
+		return nil, &Error{Path: path, Err: err}
+	}
+	return p, nil
+}
+
+func (l *loader) decodePointLight(raw json.RawMessage, path string) (tracer.Light, error) {
+	var s pointLightSpec
+	if err := decodeStrict(raw, path, &s); err != nil {
+		return nil, err
+	}
+	position, err := s.Position.required(join(path, "position"))
+	if err != nil {
+		return nil, err
+	}
+	intensity, err := color(s.Intensity, join(path, "intensity"))
+	if err != nil {
+		return nil, err
+	}
+	if s.Radius < 0 {
+		return nil, errorf(join(path, "radius"), "must not be negative")
+	}
+	light := tracer.NewPointLight(position, tracer.Color{Vec3: intensity}, s.Radius)
+	profile, err := l.profile(s.IES, join(path, "ies"))
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		nadir := geo.NewVec3(0, -1, 0)
+		if s.Nadir != nil {
+			if nadir, err = s.Nadir.toVec3(join(path, "nadir")); err != nil {
+				return nil, err
+			}
+			if nadir.LenSq() == 0 {
+				return nil, errorf(join(path, "nadir"), "must not be zero")
+			}
+		}
+		light.SetProfile(profile, nadir)
+	} else if s.Nadir != nil {
+		return nil, errorf(join(path, "nadir"), "requires ies")
+	}
+	return light, nil
+}
+
+func (l *loader) decodeSpotLight(raw json.RawMessage, path string) (tracer.Light, error) {
+	var s spotLightSpec
+	if err := decodeStrict(raw, path, &s); err != nil {
+		return nil, err
+	}
+	position, err := s.Position.required(join(path, "position"))
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.Target.required(join(path, "target"))
+	if err != nil {
+		return nil, err
+	}
+	if target == position {
+		return nil, errorf(join(path, "target"), "must differ from position")
+	}
+	intensity, err := color(s.Intensity, join(path, "intensity"))
+	if err != nil {
+		return nil, err
+	}
+	if s.Angle <= 0 || s.Angle >= 180 {
+		return nil, errorf(join(path, "angle"), "must be between 0 and 180")
+	}
+	inner := s.Angle
+	if s.InnerAngle != nil {
+		if inner = *s.InnerAngle; inner < 0 || inner > s.Angle {
+			return nil, errorf(join(path, "innerAngle"), "must be between 0 and angle")
+		}
+	}
+	light := tracer.NewSpotLight(position, target, tracer.Color{Vec3: intensity}, inner, s.Angle)
+	profile, err := l.profile(s.IES, join(path, "ies"))
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		light.SetProfile(profile, target.Sub(position))
+	}
+	return light, nil
+}
+
+func (l *loader) decodeDirectionalLight(raw json.RawMessage, path string) (tracer.Light, error) {
+	var s directionalLightSpec
+	if err := decodeStrict(raw, path, &s); err != nil {
+		return nil, err
+	}
+	direction, err := s.Direction.required(join(path, "direction"))
+	if err != nil {
+		return nil, err
+	}
+	if direction.LenSq() == 0 {
+		return nil, errorf(join(path, "direction"), "must not be zero")
+	}
+	irradiance, err := color(s.Irradiance, join(path, "irradiance"))
+	if err != nil {
+		return nil, err
+	}
+	return tracer.NewDirectionalLight(direction, tracer.Color{Vec3: irradiance}), nil
+}
@@ -0,0 +1,128 @@
+package scene
+
+import (
+	"encoding/json"
+
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+type principledSpec struct {
+	Type           string          `json:"type"`
+	BaseColor      json.RawMessage `json:"baseColor"`
+	Metallic       float32         `json:"metallic"`
+	Roughness      float32         `json:"roughness"`
+	Specular       float32         `json:"specular"`
+	SpecularTint   float32         `json:"specularTint"`
+	Sheen          float32         `json:"sheen"`
+	SheenTint      float32         `json:"sheenTint"`
+	Clearcoat      float32         `json:"clearcoat"`
+	ClearcoatGloss float32         `json:"clearcoatGloss"`
+	Transmission   float32         `json:"transmission"`
+	Anisotropic    float32         `json:"anisotropic"`
+	Ior            float32         `json:"ior"`
+}
+
+func (l *loader) decodePrincipled(raw json.RawMessage, path string) (tracer.Material, error) {
+	p := tracer.DefaultPrincipledParams()
+	s := principledSpec{
+		Roughness:      p.Roughness,
+		Specular:       p.Specular,
+		SheenTint:      p.SheenTint,
+		ClearcoatGloss: p.ClearcoatGloss,
+		Ior:            p.IOR,
+	}
+	if err := decodeStrict(raw, path, &s); err != nil {
+		return nil, err
+	}
+	if s.BaseColor != nil {
+		var err error
+		if p.BaseColor, err = l.texture(s.BaseColor, join(path, "baseColor")); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range []struct {
+		name  string
+		value float32
+		dst   *float32
+	}{
+		{"metallic", s.Metallic, &p.Metallic},
+		{"roughness", s.Roughness, &p.Roughness},
+		{"specular", s.Specular, &p.Specular},
+		{"specularTint", s.SpecularTint, &p.SpecularTint},
+		{"sheen", s.Sheen, &p.Sheen},
+		{"sheenTint", s.SheenTint, &p.SheenTint},
+		{"clearcoat", s.Clearcoat, &p.Clearcoat},
+		{"clearcoatGloss", s.ClearcoatGloss, &p.ClearcoatGloss},
+		{"transmission", s.Transmission, &p.Transmission},
+		{"anisotropic", s.Anisotropic, &p.Anisotropic},
+	} {
+		if f.value < 0 || f.value > 1 {
+			return nil, errorf(join(path, f.name), "must be between 0 and 1")
+		}
+		*f.dst = f.value
+	}
+	if s.Ior <= 0 {
+		return nil, errorf(join(path, "ior"), "must be positive")
+	}
+	p.IOR = s.Ior
+	return tracer.NewPrincipled(p), nil
+}
+
+// gltfSpec uses the names of the glTF 2.0 pbrMetallicRoughness material
+// and of the KHR_materials_transmission and KHR_materials_ior extensions
+type gltfSpec struct {
+	Type               string          `json:"type"`
+	BaseColorFactor    []float32       `json:"baseColorFactor"`
+	BaseColorTexture   json.RawMessage `json:"baseColorTexture"`
+	MetallicFactor     float32         `json:"metallicFactor"`
+	RoughnessFactor    float32         `json:"roughnessFactor"`
+	TransmissionFactor float32         `json:"transmissionFactor"`
+	Ior                float32         `json:"ior"`
+}
+
+func (l *loader) decodeGLTF(raw json.RawMessage, path string) (tracer.Material, error) {
+	g := tracer.DefaultGLTFMetallicRoughness()
+	s := gltfSpec{
+		BaseColorFactor: g.BaseColorFactor[:],
+		MetallicFactor:  g.MetallicFactor,
+		RoughnessFactor: g.RoughnessFactor,
+		Ior:             g.IOR,
+	}
+	if err := decodeStrict(raw, path, &s); err != nil {
+		return nil, err
+	}
+	if len(s.BaseColorFactor) != 4 {
+		return nil, errorf(join(path, "baseColorFactor"), "expected 4 values, got %d", len(s.BaseColorFactor))
+	}
+	for _, c := range s.BaseColorFactor {
+		if c < 0 || c > 1 {
+			return nil, errorf(join(path, "baseColorFactor"), "must be between 0 and 1")
+		}
+	}
+	copy(g.BaseColorFactor[:], s.BaseColorFactor)
+	if s.BaseColorTexture != nil {
+		var err error
+		if g.BaseColorTexture, err = l.texture(s.BaseColorTexture, join(path, "baseColorTexture")); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range []struct {
+		name  string
+		value float32
+		dst   *float32
+	}{
+		{"metallicFactor", s.MetallicFactor, &g.MetallicFactor},
+		{"roughnessFactor", s.RoughnessFactor, &g.RoughnessFactor},
+		{"transmissionFactor", s.TransmissionFactor, &g.TransmissionFactor},
+	} {
+		if f.value < 0 || f.value > 1 {
+			return nil, errorf(join(path, f.name), "must be between 0 and 1")
+		}
+		*f.dst = f.value
+	}
+	if s.Ior < 1 {
+		return nil, errorf(join(path, "ior"), "must be at least 1")
+	}
+	g.IOR = s.Ior
+	return tracer.NewPrincipled(g.Principled()), nil
+}
@@ -0,0 +1,1185 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/obj"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+// Scene is a fully loaded scene file
+type Scene struct {
+	*tracer.Scene
+	Options tracer.RenderOptions
+	Display tracer.DisplayTransform
+	camera  *cameraSpec
+}
+
+// Camera returns the camera of s for images with the given aspect
+// ratio, which may differ from the resolution in the scene file
+func (s *Scene) Camera(aspectRatio float32) (tracer.Camera, error) {
+	if !(aspectRatio > 0) {
+		return nil, fmt.Errorf("invalid aspect ratio %g", aspectRatio)
+	}
+	return s.camera.build(aspectRatio)
+}
+
+// OverrideCamera changes the camera of s to one of the camera types of
+// scene files, with the fisheye projection and the field of view fov in
+// degrees. Empty arguments keep the values of the scene file. Settings
+// which do not apply to a new type are dropped.
+func (s *Scene) OverrideCamera(typ, projection string, fov float32) error {
+	c := *s.camera
+	if typ != "" && typ != c.typ() {
+		c.Type = typ
+		c.Fov, c.Height, c.Projection, c.Aperture, c.FocusDist = nil, nil, "", 0, nil
+	}
+	if projection != "" {
+		c.Projection = projection
+	}
+	if fov != 0 {
+		c.Fov, c.Height = &fov, nil
+	}
+	if _, err := c.build(1); err != nil {
+		return err
+	}
+	s.camera = &c
+	return nil
+}
+
+// Error is a validation error of a scene file
+type Error struct {
+	// Path is the JSON path of the offending value, e.g. shapes[2].radius
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func errorf(path, format string, args ...any) error {
+	return &Error{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+// Load reads the scene file filename, meshes are
+// loaded relative to the directory of filename
+func Load(filename string) (*Scene, error) {
+	return Parse(os.DirFS(filepath.Dir(filename)), filepath.Base(filename))
+}
+
+// Parse reads the scene file name from fsys
+func Parse(fsys fs.FS, name string) (*Scene, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	l := loader{
+		fsys:      fsys,
+		dir:       path.Dir(name),
+		textures:  make(map[string]tracer.Texture),
+		materials: make(map[string]tracer.Material),
+		objects:   make(map[string]tracer.Hitable),
+	}
+	s, err := l.load(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return s, nil
+}
+
+type file struct {
+	Render     *renderSpec                `json:"render"`
+	Display    *displaySpec               `json:"display"`
+	Camera     *cameraSpec                `json:"camera"`
+	Background json.RawMessage            `json:"background"`
+	Atmosphere *atmosphereSpec            `json:"atmosphere"`
+	Textures   map[string]json.RawMessage `json:"textures"`
+	Materials  map[string]json.RawMessage `json:"materials"`
+	Objects    map[string]json.RawMessage `json:"objects"`
+	Shapes     []json.RawMessage          `json:"shapes"`
+	Lights     []json.RawMessage          `json:"lights"`
+}
+
+type renderSpec struct {
+	Width    *int   `json:"width"`
+	Height   *int   `json:"height"`
+	Samples  *int   `json:"samples"`
+	MaxDepth *int   `json:"maxDepth"`
+	Seed     *int64 `json:"seed"`
+	Spectral bool   `json:"spectral"`
+}
+
+type displaySpec struct {
+	Exposure   float32 `json:"exposure"`
+	ToneMap    string  `json:"toneMap"`
+	WhitePoint float32 `json:"whitePoint"`
+}
+
+type atmosphereSpec struct {
+	Density float32 `json:"density"`
+	Albedo  vec     `json:"albedo"`
+	G       float32 `json:"g"`
+	Extent  float32 `json:"extent"`
+}
+
+func (a *atmosphereSpec) build() (*tracer.Atmosphere, error) {
+	if a.Density <= 0 {
+		return nil, errorf("atmosphere.density", "must be positive")
+	}
+	albedo := geo.NewVec3(1, 1, 1)
+	if a.Albedo != nil {
+		var err error
+		if albedo, err = color(a.Albedo, "atmosphere.albedo"); err != nil {
+			return nil, err
+		}
+	}
+	if a.G <= -1 || a.G >= 1 {
+		return nil, errorf("atmosphere.g", "must be between -1 and 1")
+	}
+	if a.Extent < 0 {
+		return nil, errorf("atmosphere.extent", "must not be negative")
+	}
+	return &tracer.Atmosphere{Density: a.Density, Albedo: tracer.Color{Vec3: albedo}, G: a.G, Extent: a.Extent}, nil
+}
+
+type cameraSpec struct {
+	Type       string    `json:"type"`
+	LookFrom   vec       `json:"lookFrom"`
+	LookAt     vec       `json:"lookAt"`
+	Up         vec       `json:"up"`
+	Fov        *float32  `json:"fov"`
+	Height     *float32  `json:"height"`
+	Projection string    `json:"projection"`
+	Aperture   float32   `json:"aperture"`
+	FocusDist  *float32  `json:"focusDist"`
+	Shutter    []float32 `json:"shutter"`
+}
+
+type vec []float32
+
+func (v vec) toVec3(path string) (geo.Vec3, error) {
+	if len(v) != 3 {
+		return geo.Vec3{}, errorf(path, "expected 3 components, got %d", len(v))
+	}
+	return geo.NewVec3(v[0], v[1], v[2]), nil
+}
+
+type loader struct {
+	fsys      fs.FS
+	dir       string
+	textures  map[string]tracer.Texture
+	materials map[string]tracer.Material
+	objects   map[string]tracer.Hitable
+}
+
+// decodeStrict decodes data into v rejecting unknown fields
+func decodeStrict(data []byte, path string, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return errorf(join(path, typeErr.Field), "cannot use %s as %v", typeErr.Value, typeErr.Type)
+		}
+		return &Error{Path: path, Err: err}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errorf(path, "unexpected data after object")
+	}
+	return nil
+}
+
+func join(base, field string) string {
+	if base == "" {
+		return field
+	}
+	return base + "." + field
+}
+
+func (l *loader) load(data []byte) (*Scene, error) {
+	var f file
+	if err := decodeStrict(data, "", &f); err != nil {
+		return nil, err
+	}
+	opts := tracer.DefaultRenderOptions()
+	if err := f.Render.apply(&opts); err != nil {
+		return nil, err
+	}
+	display, err := f.Display.build()
+	if err != nil {
+		return nil, err
+	}
+	if f.Camera == nil {
+		return nil, errorf("camera", "missing")
+	}
+	if _, err := f.Camera.build(float32(opts.Width) / float32(opts.Height)); err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedKeys(f.Textures) {
+		t, err := l.decodeTexture(f.Textures[name], "textures."+name)
+		if err != nil {
+			return nil, err
+		}
+		l.textures[name] = t
+	}
+	for _, name := range sortedKeys(f.Materials) {
+		m, err := l.decodeMaterial(f.Materials[name], "materials."+name)
+		if err != nil {
+			return nil, err
+		}
+		l.materials[name] = m
+	}
+	for _, name := range sortedKeys(f.Objects) {
+		o, err := l.decodeObject(f.Objects[name], "objects."+name)
+		if err != nil {
+			return nil, err
+		}
+		l.objects[name] = o
+	}
+
+	if len(f.Shapes) == 0 {
+		return nil, errorf("shapes", "scene contains no shapes")
+	}
+	world := tracer.NewHitableList()
+	for i, raw := range f.Shapes {
+		hitables, err := l.decodeShape(raw, fmt.Sprintf("shapes[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		world = append(world, hitables...)
+	}
+	var lights []tracer.Light
+	for i, raw := range f.Lights {
+		light, shape, err := l.decodeLight(raw, fmt.Sprintf("lights[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		if shape != nil {
+			world = append(world, shape)
+		}
+		if light != nil {
+			lights = append(lights, light)
+		}
+	}
+
+	s := &Scene{Scene: tracer.NewScene(world), Options: opts, Display: display, camera: f.Camera}
+	s.Lights = lights
+	if f.Background != nil {
+		if s.Background, err = l.environment(f.Background, "background"); err != nil {
+			return nil, err
+		}
+	}
+	if f.Atmosphere != nil {
+		if s.Atmosphere, err = f.Atmosphere.build(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// sortedKeys returns the keys of m in a stable order,
+// so that the first error in a file is always reported
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *renderSpec) apply(opts *tracer.RenderOptions) error {
+	if r == nil {
+		return nil
+	}
+	if r.Seed != nil {
+		opts.Seed = *r.Seed
+	}
+	opts.Spectral = r.Spectral
+	for _, field := range []struct {
+		name  string
+		value *int
+		dst   *int
+	}{
+		{"width", r.Width, &opts.Width},
+		{"height", r.Height, &opts.Height},
+		{"samples", r.Samples, &opts.Samples},
+		{"maxDepth", r.MaxDepth, &opts.MaxDepth},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if *field.value <= 0 {
+			return errorf("render."+field.name, "must be positive")
+		}
+		*field.dst = *field.value
+	}
+	return nil
+}
+
+func (d *displaySpec) build() (tracer.DisplayTransform, error) {
+	var dt tracer.DisplayTransform
+	if d == nil {
+		return dt, nil
+	}
+	dt.Exposure = d.Exposure
+	if d.ToneMap != "" {
+		op, err := tracer.ParseToneOperator(d.ToneMap)
+		if err != nil {
+			return dt, &Error{Path: "display.toneMap", Err: err}
+		}
+		dt.Operator = op
+	}
+	if d.WhitePoint < 0 {
+		return dt, errorf("display.whitePoint", "must not be negative")
+	}
+	dt.WhitePoint = d.WhitePoint
+	return dt, nil
+}
+
+// typ returns the type of the camera, which defaults to perspective
+func (c *cameraSpec) typ() string {
+	if c.Type == "" {
+		return "perspective"
+	}
+	return c.Type
+}
+
+// fov returns the field of view of the camera, or def if it has none,
+// which must lie between 0 and limit degrees, including a full turn
+func (c *cameraSpec) fov(def, limit float32) (float32, error) {
+	if c.Fov == nil {
+		return def, nil
+	}
+	if f := *c.Fov; f <= 0 || f > limit || f == limit && limit < 360 {
+		return 0, errorf("camera.fov", "must be between 0 and %g degrees", limit)
+	}
+	return *c.Fov, nil
+}
+
+func (c *cameraSpec) build(aspectRatio float32) (tracer.Camera, error) {
+	if c.LookFrom == nil {
+		return nil, errorf("camera.lookFrom", "missing")
+	}
+	lookFrom, err := c.LookFrom.toVec3("camera.lookFrom")
+	if err != nil {
+		return nil, err
+	}
+	if c.LookAt == nil {
+		return nil, errorf("camera.lookAt", "missing")
+	}
+	lookAt, err := c.LookAt.toVec3("camera.lookAt")
+	if err != nil {
+		return nil, err
+	}
+	if lookFrom == lookAt {
+		return nil, errorf("camera.lookAt", "must differ from lookFrom")
+	}
+	up := geo.UnitY
+	if c.Up != nil {
+		if up, err = c.Up.toVec3("camera.up"); err != nil {
+			return nil, err
+		}
+		if up.Cross(lookFrom.Sub(lookAt)).LenSq() == 0 {
+			return nil, errorf("camera.up", "must not be parallel to the viewing direction")
+		}
+	}
+	t := c.typ()
+	if t != "perspective" {
+		if c.Aperture != 0 {
+			return nil, errorf("camera.aperture", "only applies to perspective cameras")
+		}
+		if c.FocusDist != nil {
+			return nil, errorf("camera.focusDist", "only applies to perspective cameras")
+		}
+	}
+	if c.Height != nil && t != "orthographic" {
+		return nil, errorf("camera.height", "only applies to orthographic cameras")
+	}
+	if c.Projection != "" && t != "fisheye" {
+		return nil, errorf("camera.projection", "only applies to fisheye cameras")
+	}
+
+	var camera interface {
+		tracer.Camera
+		SetShutter(open, close float32)
+	}
+	switch t {
+	case "perspective":
+		fov, err := c.fov(40, 180)
+		if err != nil {
+			return nil, err
+		}
+		if c.Aperture < 0 {
+			return nil, errorf("camera.aperture", "must not be negative")
+		}
+		focusDist := lookFrom.Sub(lookAt).Len()
+		if c.FocusDist != nil {
+			focusDist = *c.FocusDist
+			if focusDist <= 0 {
+				return nil, errorf("camera.focusDist", "must be positive")
+			}
+		}
+		camera = tracer.NewCamera(lookFrom, lookAt, up, fov, aspectRatio, c.Aperture, focusDist)
+	case "orthographic":
+		// Without a height frame lookAt like a perspective camera would
+		fov, err := c.fov(40, 180)
+		if err != nil {
+			return nil, err
+		}
+		height := 2 * lookFrom.Sub(lookAt).Len() * math32.Tan(fov*math32.Pi/360)
+		if c.Height != nil {
+			if c.Fov != nil {
+				return nil, errorf("camera.height", "cannot be combined with fov")
+			}
+			if height = *c.Height; height <= 0 {
+				return nil, errorf("camera.height", "must be positive")
+			}
+		}
+		camera = tracer.NewOrthographicCamera(lookFrom, lookAt, up, height, aspectRatio)
+	case "fisheye":
+		fov, err := c.fov(180, 360)
+		if err != nil {
+			return nil, err
+		}
+		projection := tracer.FisheyeEquidistant
+		if c.Projection != "" {
+			if projection, err = tracer.ParseFisheyeProjection(c.Projection); err != nil {
+				return nil, &Error{Path: "camera.projection", Err: err}
+			}
+		}
+		camera = tracer.NewFisheyeCamera(lookFrom, lookAt, up, fov, aspectRatio, projection)
+	case "equirectangular":
+		if c.Fov != nil {
+			return nil, errorf("camera.fov", "does not apply to equirectangular cameras")
+		}
+		camera = tracer.NewEquirectangularCamera(lookFrom, lookAt, up)
+	case "cylindrical":
+		fov, err := c.fov(360, 360)
+		if err != nil {
+			return nil, err
+		}
+		camera = tracer.NewCylindricalCamera(lookFrom, lookAt, up, fov, aspectRatio)
+	default:
+		return nil, errorf("camera.type", "unknown camera %q", t)
+	}
+	if c.Shutter != nil {
+		if len(c.Shutter) != 2 {
+			return nil, errorf("camera.shutter", "expected open and close time")
+		}
+		if c.Shutter[1] < c.Shutter[0] {
+			return nil, errorf("camera.shutter", "closes before it opens")
+		}
+		camera.SetShutter(c.Shutter[0], c.Shutter[1])
+	}
+	return camera, nil
+}
+
+type typed struct {
+	Type string `json:"type"`
+}
+
+func typeOf(raw json.RawMessage, path string) (string, error) {
+	var t typed
+	if err := json.Unmarshal(raw, &t); err != nil {
+		return "", &Error{Path: path, Err: err}
+	}
+	if t.Type == "" {
+		return "", errorf(join(path, "type"), "missing")
+	}
+	return t.Type, nil
+}
+
+type lambertianSpec struct {
+	Type   string          `json:"type"`
+	Albedo json.RawMessage `json:"albedo"`
+}
+
+type metalSpec struct {
+	Type   string          `json:"type"`
+	Albedo json.RawMessage `json:"albedo"`
+	Fuzz   float32         `json:"fuzz"`
+}
+
+type dielectricSpec struct {
+	Type       string          `json:"type"`
+	Ior        float32         `json:"ior"`
+	Dispersion json.RawMessage `json:"dispersion"`
+	Absorption *absorptionSpec `json:"absorption"`
+}
+
+var glasses = map[string]tracer.IORCurve{
+	"bk7":          tracer.SellmeierBK7,
+	"fused_silica": tracer.SellmeierFusedSilica,
+	"diamond":      tracer.SellmeierDiamond,
+}
+
+type cauchySpec struct {
+	Type string  `json:"type"`
+	A    float32 `json:"a"`
+	B    float32 `json:"b"`
+}
+
+type sellmeierSpec struct {
+	Type string     `json:"type"`
+	B    [3]float32 `json:"b"`
+	C    [3]float32 `json:"c"`
+}
+
+// dispersion decodes either the name of a glass or
+// the coefficients of a Cauchy or Sellmeier formula
+func dispersion(raw json.RawMessage, path string) (tracer.IORCurve, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		curve, ok := glasses[name]
+		if !ok {
+			return nil, errorf(path, "unknown glass %q", name)
+		}
+		return curve, nil
+	}
+	typ, err := typeOf(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	var curve tracer.IORCurve
+	switch typ {
+	case "cauchy":
+		var s cauchySpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		curve = tracer.Cauchy{A: s.A, B: s.B}
+	case "sellmeier":
+		var s sellmeierSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		curve = tracer.Sellmeier{B: s.B, C: s.C}
+	default:
+		return nil, errorf(join(path, "type"), "unknown dispersion %q", typ)
+	}
+	for _, lambda := range []float32{380, 780} {
+		if n := curve.IOR(lambda); !(n > 0) {
+			return nil, errorf(path, "index of refraction at %v nm is not positive", lambda)
+		}
+	}
+	return curve, nil
+}
+
+// absorptionSpec tints a dielectric so that light keeps
+// the fraction color after travelling distance inside
+type absorptionSpec struct {
+	Color    vec     `json:"color"`
+	Distance float32 `json:"distance"`
+}
+
+// apply sets the absorption of a dielectric if a is given
+func (a *absorptionSpec) apply(d interface {
+	SetAbsorption(tracer.Color, float32)
+}, path string) error {
+	if a == nil {
+		return nil
+	}
+	c, err := color(a.Color, join(path, "color"))
+	if err != nil {
+		return err
+	}
+	if c.X() > 1 || c.Y() > 1 || c.Z() > 1 {
+		return errorf(join(path, "color"), "must not exceed 1")
+	}
+	if a.Distance <= 0 {
+		return errorf(join(path, "distance"), "must be positive")
+	}
+	d.SetAbsorption(tracer.Color{Vec3: c}, a.Distance)
+	return nil
+}
+
+// conductorSpec gives the index of refraction either
+// by the name of a metal or as eta and k
+type conductorSpec struct {
+	Type      string  `json:"type"`
+	Metal     string  `json:"metal"`
+	Eta       vec     `json:"eta"`
+	K         vec     `json:"k"`
+	Roughness float32 `json:"roughness"`
+}
+
+var metals = map[string]tracer.ComplexIOR{
+	"gold":      tracer.IORGold,
+	"copper":    tracer.IORCopper,
+	"aluminium": tracer.IORAluminium,
+	"aluminum":  tracer.IORAluminium,
+}
+
+func (s *conductorSpec) ior(path string) (tracer.ComplexIOR, error) {
+	if s.Metal != "" {
+		if s.Eta != nil || s.K != nil {
+			return tracer.ComplexIOR{}, errorf(join(path, "metal"), "cannot be combined with eta and k")
+		}
+		ior, ok := metals[s.Metal]
+		if !ok {
+			return ior, errorf(join(path, "metal"), "unknown metal %q", s.Metal)
+		}
+		return ior, nil
+	}
+	eta, err := color(s.Eta, join(path, "eta"))
+	if err != nil {
+		return tracer.ComplexIOR{}, err
+	}
+	k, err := color(s.K, join(path, "k"))
+	if err != nil {
+		return tracer.ComplexIOR{}, err
+	}
+	return tracer.ComplexIOR{Eta: tracer.Color{Vec3: eta}, K: tracer.Color{Vec3: k}}, nil
+}
+
+type roughDielectricSpec struct {
+	Type       string          `json:"type"`
+	Ior        float32         `json:"ior"`
+	Roughness  float32         `json:"roughness"`
+	Absorption *absorptionSpec `json:"absorption"`
+}
+
+// roughness validates a roughness between 0 and 1
+func roughness(r float32, path string) error {
+	if r < 0 || r > 1 {
+		return errorf(path, "must be between 0 and 1")
+	}
+	return nil
+}
+
+type diffuseLightSpec struct {
+	Type string          `json:"type"`
+	Emit json.RawMessage `json:"emit"`
+}
+
+func (l *loader) decodeMaterial(raw json.RawMessage, path string) (tracer.Material, error) {
+	t, err := typeOf(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "lambertian":
+		var s lambertianSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		albedo, err := l.texture(s.Albedo, join(path, "albedo"))
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewLambertianTexture(albedo), nil
+	case "metal":
+		var s metalSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		albedo, err := l.texture(s.Albedo, join(path, "albedo"))
+		if err != nil {
+			return nil, err
+		}
+		if s.Fuzz < 0 || s.Fuzz > 1 {
+			return nil, errorf(join(path, "fuzz"), "must be between 0 and 1")
+		}
+		return tracer.NewMetalTexture(albedo, s.Fuzz), nil
+	case "dielectric":
+		var s dielectricSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		var curve tracer.IORCurve
+		if s.Dispersion != nil {
+			var err error
+			if curve, err = dispersion(s.Dispersion, join(path, "dispersion")); err != nil {
+				return nil, err
+			}
+			if s.Ior != 0 {
+				return nil, errorf(join(path, "ior"), "cannot be combined with dispersion")
+			}
+		} else if s.Ior <= 0 {
+			return nil, errorf(join(path, "ior"), "must be positive")
+		}
+		d := tracer.NewDielectric(s.Ior)
+		if curve != nil {
+			d = tracer.NewDispersiveDielectric(curve)
+		}
+		if err := s.Absorption.apply(d, join(path, "absorption")); err != nil {
+			return nil, err
+		}
+		return d, nil
+	case "conductor":
+		var s conductorSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		ior, err := s.ior(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := roughness(s.Roughness, join(path, "roughness")); err != nil {
+			return nil, err
+		}
+		return tracer.NewConductor(ior, s.Roughness), nil
+	case "rough_dielectric":
+		var s roughDielectricSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		if s.Ior <= 0 {
+			return nil, errorf(join(path, "ior"), "must be positive")
+		}
+		if err := roughness(s.Roughness, join(path, "roughness")); err != nil {
+			return nil, err
+		}
+		d := tracer.NewRoughDielectric(s.Ior, s.Roughness)
+		if err := s.Absorption.apply(d, join(path, "absorption")); err != nil {
+			return nil, err
+		}
+		return d, nil
+	case "principled":
+		return l.decodePrincipled(raw, path)
+	case "gltf":
+		return l.decodeGLTF(raw, path)
+	case "diffuse_light":
+		var s diffuseLightSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		emit, err := l.texture(s.Emit, join(path, "emit"))
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewDiffuseLightTexture(emit), nil
+	}
+	return nil, errorf(join(path, "type"), "unknown material type %q", t)
+}
+
+// color validates a required, non-negative color
+func color(v vec, path string) (geo.Vec3, error) {
+	if v == nil {
+		return geo.Vec3{}, errorf(path, "missing")
+	}
+	c, err := v.toVec3(path)
+	if err != nil {
+		return c, err
+	}
+	if c.X() < 0 || c.Y() < 0 || c.Z() < 0 {
+		return c, errorf(path, "must not be negative")
+	}
+	return c, nil
+}
+
+// material resolves a material reference which is either
+// the name of a material or an inline material definition
+func (l *loader) material(raw json.RawMessage, path string) (tracer.Material, error) {
+	if len(raw) == 0 {
+		return nil, errorf(path, "missing")
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		m, ok := l.materials[name]
+		if !ok {
+			return nil, errorf(path, "unknown material %q", name)
+		}
+		return m, nil
+	}
+	return l.decodeMaterial(raw, path)
+}
+
+type sphereSpec struct {
+	Type     string          `json:"type"`
+	Center   vec             `json:"center"`
+	Radius   float32         `json:"radius"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+type diskSpec struct {
+	Type     string          `json:"type"`
+	Center   vec             `json:"center"`
+	Normal   vec             `json:"normal"`
+	Radius   float32         `json:"radius"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+type movingSphereSpec struct {
+	Type     string          `json:"type"`
+	Center0  vec             `json:"center0"`
+	Center1  vec             `json:"center1"`
+	Time0    float32         `json:"time0"`
+	Time1    *float32        `json:"time1"`
+	Radius   float32         `json:"radius"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+type triangleSpec struct {
+	Type     string          `json:"type"`
+	Vertices []vec           `json:"vertices"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+type rectSpec struct {
+	Type     string          `json:"type"`
+	X        []float32       `json:"x"`
+	Y        []float32       `json:"y"`
+	Z        []float32       `json:"z"`
+	K        float32         `json:"k"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+// span validates the extent of a rectangle along one axis
+func (r *rectSpec) span(axis string, path string) (float32, float32, error) {
+	v := map[string][]float32{"x": r.X, "y": r.Y, "z": r.Z}[axis]
+	field := join(path, axis)
+	if v == nil {
+		return 0, 0, errorf(field, "missing")
+	}
+	if len(v) != 2 {
+		return 0, 0, errorf(field, "expected a range of 2 values")
+	}
+	if v[1] <= v[0] {
+		return 0, 0, errorf(field, "range is empty")
+	}
+	return v[0], v[1], nil
+}
+
+type quadSpec struct {
+	Type     string          `json:"type"`
+	Q        vec             `json:"q"`
+	U        vec             `json:"u"`
+	V        vec             `json:"v"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+type boxSpec struct {
+	Type     string          `json:"type"`
+	Min      vec             `json:"min"`
+	Max      vec             `json:"max"`
+	Material json.RawMessage `json:"material"`
+	Emit     json.RawMessage `json:"emit"`
+}
+
+// required validates a vector field which must be present
+func (v vec) required(path string) (geo.Vec3, error) {
+	if v == nil {
+		return geo.Vec3{}, errorf(path, "missing")
+	}
+	return v.toVec3(path)
+}
+
+type mediumSpec struct {
+	Type     string          `json:"type"`
+	Boundary json.RawMessage `json:"boundary"`
+	Density  float32         `json:"density"`
+	Albedo   json.RawMessage `json:"albedo"`
+	G        float32         `json:"g"`
+}
+
+type meshSpec struct {
+	Type     string          `json:"type"`
+	File     string          `json:"file"`
+	Material json.RawMessage `json:"material"`
+}
+
+// decodeShape decodes a shape, its material is either given by the
+// material field or, for lights, by the emit field
+func (l *loader) decodeShape(raw json.RawMessage, path string) (tracer.HitableList, error) {
+	t, err := typeOf(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "sphere":
+		var s sphereSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		if s.Center == nil {
+			return nil, errorf(join(path, "center"), "missing")
+		}
+		center, err := s.Center.toVec3(join(path, "center"))
+		if err != nil {
+			return nil, err
+		}
+		if s.Radius <= 0 {
+			return nil, errorf(join(path, "radius"), "must be positive")
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{tracer.NewSphere(center, s.Radius, m)}, nil
+	case "disk":
+		var s diskSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		center, err := s.Center.required(join(path, "center"))
+		if err != nil {
+			return nil, err
+		}
+		normal, err := s.Normal.required(join(path, "normal"))
+		if err != nil {
+			return nil, err
+		}
+		if normal.LenSq() == 0 {
+			return nil, errorf(join(path, "normal"), "must not be zero")
+		}
+		if s.Radius <= 0 {
+			return nil, errorf(join(path, "radius"), "must be positive")
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{tracer.NewDisk(center, normal, s.Radius, m)}, nil
+	case "moving_sphere":
+		var s movingSphereSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		var centers [2]geo.Vec3
+		for i, c := range []vec{s.Center0, s.Center1} {
+			field := join(path, fmt.Sprintf("center%d", i))
+			if c == nil {
+				return nil, errorf(field, "missing")
+			}
+			if centers[i], err = c.toVec3(field); err != nil {
+				return nil, err
+			}
+		}
+		time1 := float32(1)
+		if s.Time1 != nil {
+			time1 = *s.Time1
+		}
+		if time1 <= s.Time0 {
+			return nil, errorf(join(path, "time1"), "must be later than time0")
+		}
+		if s.Radius <= 0 {
+			return nil, errorf(join(path, "radius"), "must be positive")
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{tracer.NewMovingSphere(centers[0], centers[1], s.Time0, time1, s.Radius, m)}, nil
+	case "triangle":
+		var s triangleSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		if len(s.Vertices) != 3 {
+			return nil, errorf(join(path, "vertices"), "expected 3 vertices, got %d", len(s.Vertices))
+		}
+		var v [3]geo.Vec3
+		for i := range v {
+			if v[i], err = s.Vertices[i].toVec3(fmt.Sprintf("%s.vertices[%d]", path, i)); err != nil {
+				return nil, err
+			}
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{tracer.NewTriangle(v[0], v[1], v[2], m)}, nil
+	case "xy_rect", "xz_rect", "yz_rect":
+		var s rectSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		axes := map[string][3]string{"xy_rect": {"x", "y", "z"}, "xz_rect": {"x", "z", "y"}, "yz_rect": {"y", "z", "x"}}[t]
+		a0, a1, err := s.span(axes[0], path)
+		if err != nil {
+			return nil, err
+		}
+		b0, b1, err := s.span(axes[1], path)
+		if err != nil {
+			return nil, err
+		}
+		if map[string][]float32{"x": s.X, "y": s.Y, "z": s.Z}[axes[2]] != nil {
+			return nil, errorf(join(path, axes[2]), "not allowed for %s, use k", t)
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case "xy_rect":
+			return tracer.HitableList{tracer.NewXYRect(a0, a1, b0, b1, s.K, m)}, nil
+		case "xz_rect":
+			return tracer.HitableList{tracer.NewXZRect(a0, a1, b0, b1, s.K, m)}, nil
+		}
+		return tracer.HitableList{tracer.NewYZRect(a0, a1, b0, b1, s.K, m)}, nil
+	case "quad":
+		var s quadSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		var v [3]geo.Vec3
+		for i, field := range []string{"q", "u", "v"} {
+			if v[i], err = []vec{s.Q, s.U, s.V}[i].required(join(path, field)); err != nil {
+				return nil, err
+			}
+		}
+		if v[1].Cross(v[2]).LenSq() == 0 {
+			return nil, errorf(join(path, "v"), "must not be parallel to u")
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{tracer.NewQuad(v[0], v[1], v[2], m)}, nil
+	case "box":
+		var s boxSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		small, err := s.Min.required(join(path, "min"))
+		if err != nil {
+			return nil, err
+		}
+		big, err := s.Max.required(join(path, "max"))
+		if err != nil {
+			return nil, err
+		}
+		m, err := l.shapeMaterial(s.Material, s.Emit, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{tracer.NewBox(small, big, m)}, nil
+	case "constant_medium":
+		var s mediumSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		boundary, err := l.object(s.Boundary, join(path, "boundary"))
+		if err != nil {
+			return nil, err
+		}
+		if s.Density <= 0 {
+			return nil, errorf(join(path, "density"), "must be positive")
+		}
+		if s.G <= -1 || s.G >= 1 {
+			return nil, errorf(join(path, "g"), "must be between -1 and 1")
+		}
+		albedo, err := l.texture(s.Albedo, join(path, "albedo"))
+		if err != nil {
+			return nil, err
+		}
+		var phase tracer.Material = tracer.NewIsotropicTexture(albedo)
+		if s.G != 0 {
+			phase = tracer.NewHenyeyGreenstein(albedo, s.G)
+		}
+		return tracer.HitableList{tracer.NewConstantMedium(boundary, s.Density, phase)}, nil
+	case "instance":
+		in, err := l.decodeInstance(raw, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.HitableList{in}, nil
+	case "mesh":
+		var s meshSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		if s.File == "" {
+			return nil, errorf(join(path, "file"), "missing")
+		}
+		model, err := obj.Parse(l.fsys, l.resolve(s.File))
+		if err != nil {
+			return nil, &Error{Path: join(path, "file"), Err: err}
+		}
+		if s.Material == nil {
+			return model.Hitables(), nil
+		}
+		m, err := l.material(s.Material, join(path, "material"))
+		if err != nil {
+			return nil, err
+		}
+		hitables := tracer.NewHitableList()
+		for _, g := range model.Groups {
+			hitables = append(hitables, g.Mesh.WithMaterial(m).Triangles()...)
+		}
+		return hitables, nil
+	}
+	return nil, errorf(join(path, "type"), "unknown shape type %q", t)
+}
+
+func (l *loader) shapeMaterial(material, emit json.RawMessage, path string) (tracer.Material, error) {
+	if emit != nil {
+		if material != nil {
+			return nil, errorf(join(path, "emit"), "cannot be combined with material")
+		}
+		t, err := l.texture(emit, join(path, "emit"))
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewDiffuseLightTexture(t), nil
+	}
+	return l.material(material, join(path, "material"))
+}
+
+// decodeLight decodes a punctual light or a shape with an emit field.
+// Shapes are returned to become part of the world, their light is nil
+// if they cannot be sampled.
+func (l *loader) decodeLight(raw json.RawMessage, path string) (tracer.Light, tracer.Hitable, error) {
+	t, err := typeOf(raw, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if decode, ok := punctualLights[t]; ok {
+		light, err := decode(l, raw, path)
+		return light, nil, err
+	}
+	if t == "mesh" {
+		return nil, nil, errorf(join(path, "type"), "meshes cannot be lights")
+	}
+	var e struct {
+		Emit json.RawMessage `json:"emit"`
+	}
+	if err := json.Unmarshal(raw, &e); err != nil {
+		return nil, nil, &Error{Path: path, Err: err}
+	}
+	if e.Emit == nil {
+		return nil, nil, errorf(join(path, "emit"), "missing")
+	}
+	hitables, err := l.decodeShape(raw, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	shape := hitables[0]
+	if s, ok := shape.(tracer.Sampleable); ok {
+		return tracer.NewAreaLight(s), shape, nil
+	}
+	return nil, shape, nil
+}
+
+// resolve returns the path of a file referenced by the scene file
+func (l *loader) resolve(name string) string {
+	return path.Join(l.dir, filepath.ToSlash(name))
+}
@@ -0,0 +1,84 @@
+package scene
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func parseCamera(t *testing.T, camera string) (*Scene, error) {
+	t.Helper()
+	fsys := fstest.MapFS{"scene.json": {Data: []byte(`{"camera": ` + camera + `, "shapes": [
+		{"type": "sphere", "center": [0, 0, 0], "radius": 1, "material": {"type": "lambertian", "albedo": [0.5, 0.5, 0.5]}},
+		{"type": "sphere", "center": [0, -101, 0], "radius": 100, "material": {"type": "lambertian", "albedo": [0.5, 0.5, 0.5]}}
+	]}`)}}
+	return Parse(fsys, "scene.json")
+}
+
+func TestCamera(t *testing.T) {
+	tests := []struct {
+		name, camera, err string
+	}{
+		{"perspective", `{"lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "fov": 30}`, ""},
+		{"orthographic", `{"type": "orthographic", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "height": 3}`, ""},
+		{"fisheye", `{"type": "fisheye", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "projection": "equisolid"}`, ""},
+		{"equirectangular", `{"type": "equirectangular", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0]}`, ""},
+		{"cylindrical", `{"type": "cylindrical", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "fov": 180}`, ""},
+		{"unknown type", `{"type": "pinhole", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0]}`, `camera.type: unknown camera "pinhole"`},
+		{"same points", `{"lookFrom": [0, 0, 0], "lookAt": [0, 0, 0]}`, "camera.lookAt: must differ from lookFrom"},
+		{"fov too large", `{"lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "fov": 180}`, "camera.fov: must be between 0 and 180 degrees"},
+		{"lens on panorama", `{"type": "equirectangular", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "aperture": 0.1}`, "camera.aperture: only applies to perspective cameras"},
+		{"height and fov", `{"type": "orthographic", "lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "height": 3, "fov": 30}`, "camera.height: cannot be combined with fov"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCamera(t, tt.camera)
+			if tt.err != "" {
+				if err == nil || err.Error() != "scene.json: "+tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			camera, err := s.Camera(2)
+			if err != nil || camera == nil {
+				t.Fatalf("Camera returned %v, %v", camera, err)
+			}
+		})
+	}
+}
+
+func TestCameraAspectRatio(t *testing.T) {
+	s, err := parseCamera(t, `{"lookFrom": [0, 0, 5], "lookAt": [0, 0, 0]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Camera(0); err == nil {
+		t.Error("Camera accepted an aspect ratio of 0")
+	}
+}
+
+func TestOverrideCamera(t *testing.T) {
+	s, err := parseCamera(t, `{"lookFrom": [0, 0, 5], "lookAt": [0, 0, 0], "fov": 30, "aperture": 0.2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The aperture of the perspective camera is dropped
+	if err := s.OverrideCamera("fisheye", "equidistant", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Camera(1.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.OverrideCamera("equirectangular", "", 90); err == nil {
+		t.Error("OverrideCamera accepted a field of view for an equirectangular camera")
+	}
+	if err := s.OverrideCamera("", "fisheye", 0); err == nil {
+		t.Error("OverrideCamera accepted an unknown projection")
+	}
+	// Failed overrides leave the camera unchanged
+	if s.camera.typ() != "fisheye" || s.camera.Projection != "equidistant" {
+		t.Errorf("camera changed to %s %q", s.camera.typ(), s.camera.Projection)
+	}
+}
@@ -0,0 +1,144 @@
+package scene
+
+import (
+	"encoding/json"
+
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+type solidSpec struct {
+	Type  string `json:"type"`
+	Color vec    `json:"color"`
+}
+
+type checkerSpec struct {
+	Type  string          `json:"type"`
+	Odd   json.RawMessage `json:"odd"`
+	Even  json.RawMessage `json:"even"`
+	Scale *float32        `json:"scale"`
+}
+
+type imageSpec struct {
+	Type string `json:"type"`
+	File string `json:"file"`
+	Wrap string `json:"wrap"`
+}
+
+type noiseSpec struct {
+	Type  string   `json:"type"`
+	Kind  string   `json:"kind"`
+	Scale *float32 `json:"scale"`
+	Seed  int64    `json:"seed"`
+}
+
+var wrapModes = map[string]tracer.WrapMode{
+	"":       tracer.WrapRepeat,
+	"repeat": tracer.WrapRepeat,
+	"clamp":  tracer.WrapClamp,
+	"mirror": tracer.WrapMirror,
+}
+
+var noiseKinds = map[string]tracer.NoiseKind{
+	"":           tracer.NoisePerlin,
+	"perlin":     tracer.NoisePerlin,
+	"turbulence": tracer.NoiseTurbulence,
+	"marble":     tracer.NoiseMarble,
+}
+
+// texture resolves a texture reference which is either a color,
+// the name of a texture or an inline texture definition
+func (l *loader) texture(raw json.RawMessage, path string) (tracer.Texture, error) {
+	if len(raw) == 0 {
+		return nil, errorf(path, "missing")
+	}
+	var c vec
+	if err := json.Unmarshal(raw, &c); err == nil {
+		col, err := color(c, path)
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewSolidColor(col.X(), col.Y(), col.Z()), nil
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		t, ok := l.textures[name]
+		if !ok {
+			return nil, errorf(path, "unknown texture %q", name)
+		}
+		return t, nil
+	}
+	return l.decodeTexture(raw, path)
+}
+
+func (l *loader) decodeTexture(raw json.RawMessage, path string) (tracer.Texture, error) {
+	t, err := typeOf(raw, path)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "solid":
+		var s solidSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		col, err := color(s.Color, join(path, "color"))
+		if err != nil {
+			return nil, err
+		}
+		return tracer.NewSolidColor(col.X(), col.Y(), col.Z()), nil
+	case "checker":
+		var s checkerSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		odd, err := l.texture(s.Odd, join(path, "odd"))
+		if err != nil {
+			return nil, err
+		}
+		even, err := l.texture(s.Even, join(path, "even"))
+		if err != nil {
+			return nil, err
+		}
+		scale := float32(1)
+		if s.Scale != nil {
+			if scale = *s.Scale; scale <= 0 {
+				return nil, errorf(join(path, "scale"), "must be positive")
+			}
+		}
+		return tracer.NewCheckerTexture(odd, even, scale), nil
+	case "image":
+		var s imageSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		if s.File == "" {
+			return nil, errorf(join(path, "file"), "missing")
+		}
+		wrap, ok := wrapModes[s.Wrap]
+		if !ok {
+			return nil, errorf(join(path, "wrap"), "unknown wrap mode %q", s.Wrap)
+		}
+		tex, err := tracer.LoadImageTexture(l.fsys, l.resolve(s.File), wrap)
+		if err != nil {
+			return nil, &Error{Path: join(path, "file"), Err: err}
+		}
+		return tex, nil
+	case "noise":
+		var s noiseSpec
+		if err := decodeStrict(raw, path, &s); err != nil {
+			return nil, err
+		}
+		kind, ok := noiseKinds[s.Kind]
+		if !ok {
+			return nil, errorf(join(path, "kind"), "unknown noise kind %q", s.Kind)
+		}
+		scale := float32(1)
+		if s.Scale != nil {
+			if scale = *s.Scale; scale <= 0 {
+				return nil, errorf(join(path, "scale"), "must be positive")
+			}
+		}
+		return tracer.NewNoiseTexture(kind, scale, s.Seed), nil
+	}
+	return nil, errorf(join(path, "type"), "unknown texture type %q", t)
+}
@@ -12,12 +12,16 @@ type NodeKind uint8
 const (
 	NodeSphere NodeKind = iota
 	NodeBvh
+	NodeTriangle
+	NodeHitable
 )
 
 type HitableNode struct {
-	kind    NodeKind
-	sphere  *Sphere
-	bvhNode *BvhNode
+	kind     NodeKind
+	sphere   *Sphere
+	bvhNode  *BvhNode
+	triangle *Triangle
+	hitable  Hitable
 }
 
 func (h *HitableNode) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
@@ -26,6 +30,10 @@ func (h *HitableNode) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
 		return h.sphere.Hit(r, tMin, tMax, rec)
 	case NodeBvh:
 		return h.bvhNode.Hit(r, tMin, tMax, rec)
+	case NodeTriangle:
+		return h.triangle.Hit(r, tMin, tMax, rec)
+	case NodeHitable:
+		return h.hitable.Hit(r, tMin, tMax, rec)
 	}
 	return false
 }
@@ -36,6 +44,10 @@ func (h *HitableNode) BoundingBox() (bool, geo.Aabb) {
 		return h.sphere.BoundingBox()
 	case NodeBvh:
 		return h.bvhNode.BoundingBox()
+	case NodeTriangle:
+		return h.triangle.BoundingBox()
+	case NodeHitable:
+		return h.hitable.BoundingBox()
 	}
 	return false, geo.EmptyBox
 }
@@ -55,8 +67,10 @@ func hitableToNode(h Hitable) HitableNode {
 		return HitableNode{kind: NodeSphere, sphere: v}
 	case *BvhNode:
 		return HitableNode{kind: NodeBvh, bvhNode: v}
+	case *Triangle:
+		return HitableNode{kind: NodeTriangle, triangle: v}
 	}
-	panic("unsupported Hitable type")
+	return HitableNode{kind: NodeHitable, hitable: h}
 }
 
 func NewBvhNodeFromList(l HitableList) BvhNode {
@@ -138,13 +152,9 @@ func (b *BvhNode) BoundingBox() (bool, geo.Aabb) {
 
 func (b *BvhNode) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
 	if b.box.Hit(r, tMin, tMax) {
-		var leftRec, rightRec HitRecord
-		if b.left.Hit(r, tMin, tMax, &leftRec) {
-			if b.right.Hit(r, tMin, leftRec.t, &rightRec) {
-				*rec = rightRec
-				return true
-			}
-			*rec = leftRec
+		if b.left.Hit(r, tMin, tMax, rec) {
+			// A closer hit on the right replaces the one on the left
+			b.right.Hit(r, tMin, rec.t, rec)
 			return true
 		}
 		return b.right.Hit(r, tMin, tMax, rec)
@@ -1,39 +1,87 @@
 package tracer
 
 import (
+	"fmt"
 	"math/rand"
 
 	"github.com/chewxy/math32"
 	"github.com/robquant/tracer/pkg/geo"
 )
 
-type Camera struct {
-	origin          geo.Vec3
+// Camera generates the rays which render the image
+type Camera interface {
+	// GetRay returns the ray through the image position s from left to
+	// right and t from bottom to top, both between 0 and 1
+	GetRay(s, t float32, randGen *rand.Rand) geo.Ray
+}
+
+// view is the placement of a camera at origin looking along -w,
+// with u to the right and v up, and the interval its shutter is open
+type view struct {
+	origin  geo.Vec3
+	u, v, w geo.Vec3
+	// shutter open and close time
+	time0, time1 float32
+}
+
+func newView(lookFrom, lookAt, vUp geo.Vec3) view {
+	w := lookFrom.Sub(lookAt).Normed()
+	u := vUp.Cross(w).Normed()
+	return view{origin: lookFrom, u: u, v: w.Cross(u), w: w}
+}
+
+// newLevelView returns a view whose v axis is vUp and whose
+// viewing direction is turned about it to be perpendicular to it
+func newLevelView(lookFrom, lookAt, vUp geo.Vec3) view {
+	v := vUp.Normed()
+	u := v.Cross(lookFrom.Sub(lookAt)).Normed()
+	return view{origin: lookFrom, u: u, v: v, w: u.Cross(v)}
+}
+
+// SetShutter sets the interval during which the shutter is open,
+// rays are spread uniformly over it to render motion blur
+func (c *view) SetShutter(open, close float32) {
+	c.time0, c.time1 = open, close
+}
+
+// time returns a random time while the shutter is open
+func (c *view) time(randGen *rand.Rand) float32 {
+	time := c.time0
+	if c.time1 != c.time0 {
+		time += randGen.Float32() * (c.time1 - c.time0)
+	}
+	return time
+}
+
+// local transforms a direction from camera to world coordinates
+func (c *view) local(x, y, z float32) geo.Vec3 {
+	return c.u.Mul(x).Add(c.v.Mul(y)).Add(c.w.Mul(z))
+}
+
+// PerspectiveCamera is a thin lens camera, which keeps only
+// the focus plane sharp when it has an aperture
+type PerspectiveCamera struct {
+	view
 	lowerLeftCorner geo.Vec3
 	horizontal      geo.Vec3
 	vertical        geo.Vec3
-	u, v, w         geo.Vec3
 	lensRadius      float32
 }
 
-// NewCamera constructs a new Camera from the vertical
+// NewCamera constructs a new PerspectiveCamera from the vertical
 // field of view in degrees, and the aspect ratio
-func NewCamera(lookFrom, lookAt, vUp geo.Vec3, vertFov, aspectRatio, aperture, focusDist float32) *Camera {
-	lensRadius := aperture / 2
+func NewCamera(lookFrom, lookAt, vUp geo.Vec3, vertFov, aspectRatio, aperture, focusDist float32) *PerspectiveCamera {
+	c := &PerspectiveCamera{view: newView(lookFrom, lookAt, vUp), lensRadius: aperture / 2}
 	theta := math32.Pi / 180 * vertFov
 	halfHeight := math32.Tan(theta / 2)
 	halfWidth := aspectRatio * halfHeight
-	w := lookFrom.Sub(lookAt).Normed()
-	u := vUp.Cross(w).Normed()
-	v := w.Cross(u)
 	lowerLeftCorner := lookFrom
-	lowerLeftCorner = lowerLeftCorner.Sub(u.Mul(halfWidth * focusDist))
-	lowerLeftCorner = lowerLeftCorner.Sub(v.Mul(halfHeight * focusDist))
-	lowerLeftCorner = lowerLeftCorner.Sub(w.Mul(focusDist))
-	horizontal := u.Mul(2 * halfWidth * focusDist)
-	vertical := v.Mul(2 * halfHeight * focusDist)
-
-	return &Camera{lookFrom, lowerLeftCorner, horizontal, vertical, u, v, w, lensRadius}
+	lowerLeftCorner = lowerLeftCorner.Sub(c.u.Mul(halfWidth * focusDist))
+	lowerLeftCorner = lowerLeftCorner.Sub(c.v.Mul(halfHeight * focusDist))
+	c.lowerLeftCorner = lowerLeftCorner.Sub(c.w.Mul(focusDist))
+	c.horizontal = c.u.Mul(2 * halfWidth * focusDist)
+	c.vertical = c.v.Mul(2 * halfHeight * focusDist)
+	return c
 }
 
 func randomInUnitDisk(randGen *rand.Rand) geo.Vec3 {
@@ -45,9 +93,147 @@ func randomInUnitDisk(randGen *rand.Rand) geo.Vec3 {
 	return vec
 }
 
-func (c *Camera) GetRay(s, t float32, randGen *rand.Rand) geo.Ray {
+// GetRay implements the Camera interface for PerspectiveCamera
+func (c *PerspectiveCamera) GetRay(s, t float32, randGen *rand.Rand) geo.Ray {
 	rd := randomInUnitDisk(randGen).Mul(c.lensRadius)
 	offset := c.u.Mul(rd.X()).Add(c.v.Mul(rd.Y()))
 	dir := c.lowerLeftCorner.Add(c.horizontal.Mul(s)).Add(c.vertical.Mul(t)).Sub(c.origin).Sub(offset)
-	return geo.NewRay(c.origin.Add(offset), dir)
+	time := c.time(randGen)
+	return geo.NewRay(c.origin.Add(offset), dir, time)
+}
+
+// OrthographicCamera sends parallel rays from a rectangle around its
+// position, which keeps parallel lines parallel as in technical drawings
+type OrthographicCamera struct {
+	view
+	halfWidth, halfHeight float32
+}
+
+// NewOrthographicCamera creates an OrthographicCamera seeing
+// height world units from bottom to top of the image
+func NewOrthographicCamera(lookFrom, lookAt, vUp geo.Vec3, height, aspectRatio float32) *OrthographicCamera {
+	return &OrthographicCamera{view: newView(lookFrom, lookAt, vUp), halfWidth: height * aspectRatio / 2, halfHeight: height / 2}
+}
+
+// GetRay implements the Camera interface for OrthographicCamera
+func (c *OrthographicCamera) GetRay(s, t float32, randGen *rand.Rand) geo.Ray {
+	origin := c.origin.Add(c.local((2*s-1)*c.halfWidth, (2*t-1)*c.halfHeight, 0))
+	return geo.NewRay(origin, c.w.Neg(), c.time(randGen))
+}
+
+// FisheyeProjection maps the angle of a direction from the
+// viewing direction to the distance from the image center
+type FisheyeProjection int
+
+const (
+	// FisheyeEquidistant keeps distances proportional to angles
+	FisheyeEquidistant FisheyeProjection = iota
+	// FisheyeEquisolid keeps areas proportional to solid angles
+	FisheyeEquisolid
+)
+
+var fisheyeProjectionNames = []string{"equidistant", "equisolid"}
+
+func (p FisheyeProjection) String() string {
+	if int(p) < len(fisheyeProjectionNames) {
+		return fisheyeProjectionNames[p]
+	}
+	return fmt.Sprintf("FisheyeProjection(%d)", p)
+}
+
+// ParseFisheyeProjection returns the FisheyeProjection with
+// the given name, which is either equidistant or equisolid
+func ParseFisheyeProjection(name string) (FisheyeProjection, error) {
+	for i, n := range fisheyeProjectionNames {
+		if n == name {
+			return FisheyeProjection(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown fisheye projection %q", name)
+}
+
+// FisheyeCamera is a full frame fisheye lens
+type FisheyeCamera struct {
+	view
+	projection FisheyeProjection
+	// halfWidth and halfHeight are the size of the image relative to
+	// its half diagonal, which is at half the field of view
+	halfWidth, halfHeight float32
+	halfFov               float32
+}
+
+// NewFisheyeCamera creates a FisheyeCamera whose field of view in degrees,
+// up to 360, spans the diagonal of the image
+func NewFisheyeCamera(lookFrom, lookAt, vUp geo.Vec3, fov, aspectRatio float32, projection FisheyeProjection) *FisheyeCamera {
+	diagonal := math32.Sqrt(aspectRatio*aspectRatio + 1)
+	return &FisheyeCamera{
+		view:       newView(lookFrom, lookAt, vUp),
+		projection: projection,
+		halfWidth:  aspectRatio / diagonal,
+		halfHeight: 1 / diagonal,
+		halfFov:    fov * math32.Pi / 360,
+	}
+}
+
+// GetRay implements the Camera interface for FisheyeCamera
+func (c *FisheyeCamera) GetRay(s, t float32, randGen *rand.Rand) geo.Ray {
+	x, y := (2*s-1)*c.halfWidth, (2*t-1)*c.halfHeight
+	r := math32.Sqrt(x*x + y*y)
+	var theta float32
+	switch c.projection {
+	case FisheyeEquidistant:
+		theta = r * c.halfFov
+	case FisheyeEquisolid:
+		// r is proportional to the sine of half the angle
+		theta = 2 * math32.Asin(min(r*math32.Sin(c.halfFov/2), 1))
+	}
+	sinTheta, cosTheta := math32.Sincos(theta)
+	dir := c.w.Neg()
+	if r > 0 {
+		dir = c.local(sinTheta*x/r, sinTheta*y/r, -cosTheta)
+	}
+	return geo.NewRay(c.origin, dir, c.time(randGen))
+}
+
+// EquirectangularCamera renders a full panorama in all directions with
+// longitude from left to right and latitude from bottom to top, for an
+// image twice as wide as high. The center of the image looks at lookAt.
+type EquirectangularCamera struct {
+	view
+}
+
+// NewEquirectangularCamera creates an EquirectangularCamera at lookFrom
+// whose horizon is perpendicular to vUp, centered on the direction of lookAt
+func NewEquirectangularCamera(lookFrom, lookAt, vUp geo.Vec3) *EquirectangularCamera {
+	return &EquirectangularCamera{view: newLevelView(lookFrom, lookAt, vUp)}
+}
+
+// GetRay implements the Camera interface for EquirectangularCamera
+func (c *EquirectangularCamera) GetRay(s, t float32, randGen *rand.Rand) geo.Ray {
+	sinPhi, cosPhi := math32.Sincos((2*s - 1) * math32.Pi)
+	sinLat, cosLat := math32.Sincos((t - 0.5) * math32.Pi)
+	dir := c.local(cosLat*sinPhi, sinLat, -cosLat*cosPhi)
+	return geo.NewRay(c.origin, dir, c.time(randGen))
+}
+
+// CylindricalCamera projects onto a cylinder around the up axis, which
+// keeps vertical lines straight in panoramas
+type CylindricalCamera struct {
+	view
+	halfFov, halfHeight float32
+}
+
+// NewCylindricalCamera creates a CylindricalCamera whose horizontal field of
+// view in degrees, up to 360, spans the width of the image. The height of
+// the image is scaled like its width at the center.
+func NewCylindricalCamera(lookFrom, lookAt, vUp geo.Vec3, fov, aspectRatio float32) *CylindricalCamera {
+	halfFov := fov * math32.Pi / 360
+	return &CylindricalCamera{view: newLevelView(lookFrom, lookAt, vUp), halfFov: halfFov, halfHeight: halfFov / aspectRatio}
+}
+
+// GetRay implements the Camera interface for CylindricalCamera
+func (c *CylindricalCamera) GetRay(s, t float32, randGen *rand.Rand) geo.Ray {
+	sinPhi, cosPhi := math32.Sincos((2*s - 1) * c.halfFov)
+	dir := c.local(sinPhi, (2*t-1)*c.halfHeight, -cosPhi)
+	return geo.NewRay(c.origin, dir, c.time(randGen))
 }
@@ -0,0 +1,178 @@
+package tracer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// The cameras of the tests stand at eye looking along -z towards target,
+// with x to the right and y up
+var (
+	eye    = geo.NewVec3(1, 2, 3)
+	target = geo.NewVec3(1, 2, -1)
+)
+
+// angle returns the angle between a and b in degrees
+func angle(a, b geo.Vec3) float32 {
+	cos := a.Dot(b) / (a.Len() * b.Len())
+	return math32.Acos(min(max(cos, -1), 1)) * 180 / math32.Pi
+}
+
+func degrees(rad float32) float32 {
+	return rad * 180 / math32.Pi
+}
+
+type cameraRay struct {
+	s, t float32
+	// want is the direction of the ray
+	want geo.Vec3
+}
+
+func checkRays(t *testing.T, c Camera, rays []cameraRay) {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	for _, r := range rays {
+		ray := c.GetRay(r.s, r.t, rng)
+		if a := angle(ray.Dir(), r.want); a > 0.01 {
+			t.Errorf("ray at %g, %g points along %v, %g degrees off %v", r.s, r.t, ray.Dir(), a, r.want)
+		}
+		if ray.Orig() != eye {
+			t.Errorf("ray at %g, %g starts at %v, want %v", r.s, r.t, ray.Orig(), eye)
+		}
+	}
+}
+
+func TestPerspectiveCamera(t *testing.T) {
+	tan30 := math32.Tan(math32.Pi / 6)
+	c := NewCamera(eye, target, geo.UnitY, 60, 2, 0, 1)
+	checkRays(t, c, []cameraRay{
+		{0.5, 0.5, geo.NewVec3(0, 0, -1)},
+		// The vertical field of view spans the height
+		{0.5, 1, geo.NewVec3(0, tan30, -1)},
+		{0.5, 0, geo.NewVec3(0, -tan30, -1)},
+		{1, 0.5, geo.NewVec3(2*tan30, 0, -1)},
+		{0, 1, geo.NewVec3(-2*tan30, tan30, -1)},
+	})
+	// With an aperture all rays through a point of
+	// the image meet in the focus plane
+	lens := NewCamera(eye, target, geo.UnitY, 60, 2, 0.5, 3)
+	rng := rand.New(rand.NewSource(1))
+	want := eye.Add(geo.NewVec3(tan30, -0.25*tan30, -1).Mul(3))
+	for i := 0; i < 10; i++ {
+		r := lens.GetRay(0.75, 0.375, rng)
+		if p := r.At(1); p.Sub(want).Len() > 1e-4 {
+			t.Errorf("ray from %v reaches the focus plane at %v, want %v", r.Orig(), p, want)
+		}
+	}
+}
+
+func TestOrthographicCamera(t *testing.T) {
+	c := NewOrthographicCamera(eye, target, geo.UnitY, 4, 2)
+	c.SetShutter(1, 2)
+	rng := rand.New(rand.NewSource(1))
+	tests := []struct {
+		s, t   float32
+		offset geo.Vec3
+	}{
+		{0.5, 0.5, geo.Vec3{}},
+		{0, 0, geo.NewVec3(-4, -2, 0)},
+		{1, 1, geo.NewVec3(4, 2, 0)},
+		{0.75, 0.25, geo.NewVec3(2, -1, 0)},
+	}
+	for _, tt := range tests {
+		r := c.GetRay(tt.s, tt.t, rng)
+		if d := r.Dir(); d != geo.NewVec3(0, 0, -1) {
+			t.Errorf("ray at %g, %g points along %v, want all rays parallel to the view", tt.s, tt.t, d)
+		}
+		if o := r.Orig().Sub(eye); o.Sub(tt.offset).Len() > 1e-5 {
+			t.Errorf("ray at %g, %g starts %v from the camera, want %v", tt.s, tt.t, o, tt.offset)
+		}
+		if time := r.Time(); time < 1 || time > 2 {
+			t.Errorf("ray at %g, %g is sent at %g, while the shutter is open from 1 to 2", tt.s, tt.t, time)
+		}
+	}
+}
+
+// TestFisheyeCamera checks that the corners of the image are at half the
+// field of view, and the angles in between for both projections
+func TestFisheyeCamera(t *testing.T) {
+	const aspect = 1.5
+	forward := geo.NewVec3(0, 0, -1)
+	// Halfway from the center to the top right corner
+	halfway := geo.NewVec3(aspect, 1, 0)
+	tests := []struct {
+		projection FisheyeProjection
+		fov        float32
+		// halfway is the angle halfway to the corner
+		halfway float32
+	}{
+		{FisheyeEquidistant, 180, 45},
+		{FisheyeEquisolid, 180, degrees(2 * math32.Asin(0.5*math32.Sin(math32.Pi/4)))},
+		{FisheyeEquidistant, 120, 30},
+		{FisheyeEquisolid, 120, degrees(2 * math32.Asin(0.5*math32.Sin(math32.Pi/6)))},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		c := NewFisheyeCamera(eye, target, geo.UnitY, tt.fov, aspect, tt.projection)
+		dirAt := func(s, t float32) geo.Vec3 {
+			r := c.GetRay(s, t, rng)
+			return r.Dir()
+		}
+		for _, corner := range [][2]float32{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+			if a := angle(dirAt(corner[0], corner[1]), forward); math32.Abs(a-tt.fov/2) > 0.01 {
+				t.Errorf("%v %g: corner %v is %g degrees off the view, want %g", tt.projection, tt.fov, corner, a, tt.fov/2)
+			}
+		}
+		dir := dirAt(0.75, 0.75)
+		if a := angle(dir, forward); math32.Abs(a-tt.halfway) > 0.01 {
+			t.Errorf("%v %g: halfway to the corner is %g degrees off the view, want %g", tt.projection, tt.fov, a, tt.halfway)
+		}
+		// Rays leave in the direction of their image position
+		if a := angle(geo.NewVec3(dir.X(), dir.Y(), 0), halfway); a > 0.01 {
+			t.Errorf("%v %g: halfway to the corner points along %v", tt.projection, tt.fov, dir)
+		}
+		if a := angle(dirAt(0.5, 0.5), forward); a > 0.01 {
+			t.Errorf("%v %g: center is %g degrees off the view", tt.projection, tt.fov, a)
+		}
+	}
+	// A 360 degree lens sees straight back in its corners
+	c := NewFisheyeCamera(eye, target, geo.UnitY, 360, aspect, FisheyeEquidistant)
+	checkRays(t, c, []cameraRay{{1, 1, geo.NewVec3(0, 0, 1)}})
+}
+
+func TestEquirectangularCamera(t *testing.T) {
+	c := NewEquirectangularCamera(eye, target, geo.UnitY)
+	checkRays(t, c, []cameraRay{
+		{0.5, 0.5, geo.NewVec3(0, 0, -1)},
+		{0.75, 0.5, geo.NewVec3(1, 0, 0)},
+		{0.25, 0.5, geo.NewVec3(-1, 0, 0)},
+		{0, 0.5, geo.NewVec3(0, 0, 1)},
+		{1, 0.5, geo.NewVec3(0, 0, 1)},
+		{0.5, 0.75, geo.NewVec3(0, 1, -1)},
+		{0.625, 0.25, geo.NewVec3(0.5, -math32.Sqrt(0.5), -0.5)},
+		{0.3, 1, geo.UnitY},
+		{0.8, 0, geo.UnitY.Neg()},
+	})
+	// The horizon stays level when looking up
+	up := NewEquirectangularCamera(eye, eye.Add(geo.NewVec3(0, 3, -1)), geo.UnitY)
+	checkRays(t, up, []cameraRay{{0.5, 0.5, geo.NewVec3(0, 0, -1)}})
+}
+
+func TestCylindricalCamera(t *testing.T) {
+	const fov, aspect = 120, 2
+	c := NewCylindricalCamera(eye, target, geo.UnitY, fov, aspect)
+	// The angle around the axis grows linearly from left to right, and
+	// height on the cylinder of unit radius from bottom to top
+	halfHeight := float32(fov) / 2 * math32.Pi / 180 / aspect
+	var rays []cameraRay
+	for _, s := range []float32{0, 0.2, 0.5, 0.9, 1} {
+		for _, v := range []float32{0, 0.5, 0.8} {
+			sin, cos := math32.Sincos((2*s - 1) * fov / 2 * math32.Pi / 180)
+			rays = append(rays, cameraRay{s, v, geo.NewVec3(sin, (2*v-1)*halfHeight, -cos)})
+		}
+	}
+	checkRays(t, c, rays)
+}
@@ -43,3 +43,8 @@ func (c Color) Mul(t float32) Color {
 func (c Color) MulVec(v geo.Vec3) Color {
 	return Color{geo.NewVec3(c.R()*v.X(), c.G()*v.Y(), c.B()*v.Z())}
 }
+
+// luminance returns the Rec. 709 relative luminance of c
+func luminance(c Color) float32 {
+	return 0.2126*c.R() + 0.7152*c.G() + 0.0722*c.B()
+}
@@ -0,0 +1,74 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Disk is a flat circle facing along its normal
+type Disk struct {
+	center, normal geo.Vec3
+	radius         float32
+	onb            geo.ONB
+	material       Material
+}
+
+// NewDisk constructs a new Disk around center, normal need not be normalized
+func NewDisk(center, normal geo.Vec3, radius float32, m Material) *Disk {
+	n := normal.Normed()
+	return &Disk{center: center, normal: n, radius: radius, onb: geo.NewONB(n), material: m}
+}
+
+// Hit implements the Hitable interface for Disk
+func (d *Disk) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	denom := d.normal.Dot(r.Dir())
+	if math32.Abs(denom) < 1e-8 {
+		return false
+	}
+	t := d.normal.Dot(d.center.Sub(r.Orig())) / denom
+	if !(t > tMin && t < tMax) {
+		return false
+	}
+	p := r.At(t)
+	local := d.onb.ToLocal(p.Sub(d.center))
+	distSq := local.X()*local.X() + local.Y()*local.Y()
+	if distSq > d.radius*d.radius {
+		return false
+	}
+	rec.t = t
+	rec.p = p
+	rec.normal = d.normal
+	// u runs around the disk and v from the center to the rim
+	rec.u = (math32.Atan2(local.Y(), local.X()) + math32.Pi) / (2 * math32.Pi)
+	rec.v = math32.Sqrt(distSq) / d.radius
+	rec.tangent = d.onb.Local(geo.NewVec3(-local.Y(), local.X(), 0))
+	rec.material = d.material
+	return true
+}
+
+// BoundingBox implements the Hitable interface for Disk
+func (d *Disk) BoundingBox() (bool, geo.Aabb) {
+	// The extent along each axis shrinks as the normal turns towards it
+	n := d.normal
+	e := geo.NewVec3(
+		d.radius*math32.Sqrt(max(0, 1-n.X()*n.X())),
+		d.radius*math32.Sqrt(max(0, 1-n.Y()*n.Y())),
+		d.radius*math32.Sqrt(max(0, 1-n.Z()*n.Z())),
+	)
+	return true, geo.NewAabb(d.center.Sub(e), d.center.Add(e)).Padded(rectPadding)
+}
+
+// PdfValue implements the Sampleable interface for Disk
+func (d *Disk) PdfValue(origin, dir geo.Vec3) float32 {
+	return areaPdf(d, math32.Pi*d.radius*d.radius, d.normal, origin, dir)
+}
+
+// Random implements the Sampleable interface for Disk
+func (d *Disk) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	r := d.radius * math32.Sqrt(rng.Float32())
+	sin, cos := math32.Sincos(2 * math32.Pi * rng.Float32())
+	p := d.center.Add(d.onb.Local(geo.NewVec3(r*cos, r*sin, 0)))
+	return p.Sub(origin)
+}
@@ -0,0 +1,50 @@
+package tracer
+
+import "github.com/chewxy/math32"
+
+// IORCurve is an index of refraction varying with the wavelength,
+// which splits white light into its colors
+type IORCurve interface {
+	// IOR returns the index of refraction at wavelength lambda in nm
+	IOR(lambda float32) float32
+}
+
+// Cauchy is the empirical dispersion formula n = A + B / lambda^2
+// with lambda in micrometers
+type Cauchy struct {
+	A, B float32
+}
+
+// IOR implements the IORCurve interface for Cauchy
+func (c Cauchy) IOR(lambda float32) float32 {
+	um := lambda / 1000
+	return c.A + c.B/(um*um)
+}
+
+// Sellmeier is the dispersion formula n^2 = 1 + sum B_i lambda^2 / (lambda^2 - C_i)
+// with lambda in micrometers, which holds over a wider range than Cauchy
+type Sellmeier struct {
+	B, C [3]float32
+}
+
+// IOR implements the IORCurve interface for Sellmeier
+func (s Sellmeier) IOR(lambda float32) float32 {
+	um := lambda / 1000
+	l2 := um * um
+	n2 := float32(1)
+	for i := range s.B {
+		n2 += s.B[i] * l2 / (l2 - s.C[i])
+	}
+	return math32.Sqrt(n2)
+}
+
+// Dispersion of common optical materials
+var (
+	SellmeierBK7         = Sellmeier{B: [3]float32{1.03961212, 0.231792344, 1.01046945}, C: [3]float32{0.00600069867, 0.0200179144, 103.560653}}
+	SellmeierFusedSilica = Sellmeier{B: [3]float32{0.6961663, 0.4079426, 0.8974794}, C: [3]float32{0.00467914826, 0.0135120631, 97.9340025}}
+	SellmeierDiamond     = Sellmeier{B: [3]float32{4.3356, 0.3306, 0}, C: [3]float32{0.011236, 0.030625, 0}}
+)
+
+// sodiumD is the wavelength in nm at which refractive indices are usually
+// given, used for dispersive materials when rendering in RGB
+const sodiumD = 589.3
@@ -0,0 +1,90 @@
+package tracer
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// distribution1D samples the piecewise constant function f over [0, 1)
+// with density proportional to f
+type distribution1D struct {
+	f []float32
+	// cdf has one more entry than f, from 0 to 1
+	cdf      []float32
+	integral float32
+}
+
+func newDistribution1D(f []float32) distribution1D {
+	n := len(f)
+	d := distribution1D{f: f, cdf: make([]float32, n+1)}
+	var sum float64
+	for i, v := range f {
+		sum += float64(v)
+		d.cdf[i+1] = float32(sum)
+	}
+	d.integral = float32(sum / float64(n))
+	for i := 1; i <= n; i++ {
+		// A function which is zero everywhere is sampled uniformly
+		if sum == 0 {
+			d.cdf[i] = float32(i) / float32(n)
+		} else {
+			d.cdf[i] /= float32(sum)
+		}
+	}
+	return d
+}
+
+// sample maps u in [0, 1) to a position x in [0, 1) and returns it
+// with its density and the index of the segment it lies in
+func (d *distribution1D) sample(u float32) (x, pdf float32, i int) {
+	n := len(d.f)
+	i = sort.Search(n, func(i int) bool { return d.cdf[i+1] > u })
+	i = min(i, n-1)
+	du := u - d.cdf[i]
+	if width := d.cdf[i+1] - d.cdf[i]; width > 0 {
+		du /= width
+	}
+	return min((float32(i)+du)/float32(n), 1-1e-7), d.pdf(i), i
+}
+
+// pdf returns the density of positions in segment i
+func (d *distribution1D) pdf(i int) float32 {
+	if d.integral == 0 {
+		return 1
+	}
+	return d.f[i] / d.integral
+}
+
+// distribution2D samples a piecewise constant function over [0, 1)²,
+// given as rows of values, by choosing a row and then a position in it
+type distribution2D struct {
+	rows     []distribution1D
+	marginal distribution1D
+}
+
+func newDistribution2D(f []float32, width, height int) distribution2D {
+	d := distribution2D{rows: make([]distribution1D, height)}
+	integrals := make([]float32, height)
+	for y := range d.rows {
+		d.rows[y] = newDistribution1D(f[y*width : (y+1)*width])
+		integrals[y] = d.rows[y].integral
+	}
+	d.marginal = newDistribution1D(integrals)
+	return d
+}
+
+// sample returns a random position u, v and its density
+func (d *distribution2D) sample(rng *rand.Rand) (u, v, pdf float32) {
+	v, pdfV, y := d.marginal.sample(rng.Float32())
+	u, pdfU, _ := d.rows[y].sample(rng.Float32())
+	return u, v, pdfU * pdfV
+}
+
+// pdf returns the density with which sample chooses u, v
+func (d *distribution2D) pdf(u, v float32) float32 {
+	height := len(d.rows)
+	y := min(max(int(v*float32(height)), 0), height-1)
+	row := &d.rows[y]
+	x := min(max(int(u*float32(len(row.f))), 0), len(row.f)-1)
+	return row.pdf(x) * d.marginal.pdf(y)
+}
@@ -0,0 +1,174 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Environment is the light arriving from infinitely far away
+// along rays which leave the scene
+type Environment interface {
+	// Radiance returns the light arriving from direction dir
+	Radiance(dir geo.Vec3) Color
+}
+
+// SampleableEnvironment is an Environment which can choose directions
+// towards its bright parts, which lets the renderer sample it like a light
+type SampleableEnvironment interface {
+	Environment
+	// PdfValue returns the solid angle density with which
+	// Random chooses direction dir
+	PdfValue(dir geo.Vec3) float32
+	// Random returns a random unit direction
+	Random(rng *rand.Rand) geo.Vec3
+}
+
+// ConstantEnvironment has the same radiance in every direction
+type ConstantEnvironment struct {
+	color Color
+}
+
+// NewConstantEnvironment creates a ConstantEnvironment of color c
+func NewConstantEnvironment(c Color) *ConstantEnvironment {
+	return &ConstantEnvironment{color: c}
+}
+
+// Radiance implements the Environment interface for ConstantEnvironment
+func (e *ConstantEnvironment) Radiance(dir geo.Vec3) Color {
+	return e.color
+}
+
+// GradientEnvironment blends linearly from the color
+// straight down to the color straight up
+type GradientEnvironment struct {
+	bottom, top Color
+}
+
+// NewGradientEnvironment creates a GradientEnvironment from bottom to top
+func NewGradientEnvironment(bottom, top Color) *GradientEnvironment {
+	return &GradientEnvironment{bottom: bottom, top: top}
+}
+
+// DefaultSky returns the white to light blue gradient used
+// for scenes without an Environment
+func DefaultSky() *GradientEnvironment {
+	return NewGradientEnvironment(NewColor(1, 1, 1), NewColor(0.5, 0.7, 1))
+}
+
+// Radiance implements the Environment interface for GradientEnvironment
+func (e *GradientEnvironment) Radiance(dir geo.Vec3) Color {
+	t := 0.5 * (dir.Normed().Y() + 1)
+	return e.bottom.Mul(1 - t).Add(e.top.Mul(t))
+}
+
+// EnvironmentMap surrounds the scene with an equirectangular high dynamic
+// range image. The top row of the image is straight up and its center
+// looks down the negative z axis. Directions are sampled proportionally
+// to the luminance of the image.
+type EnvironmentMap struct {
+	width, height int
+	pix           []Color
+	intensity     float32
+	// toWorld rotates the map about the y axis, toLocal undoes it
+	toWorld, toLocal geo.Mat4
+	distribution     distribution2D
+}
+
+// NewEnvironmentMap creates an EnvironmentMap from the linear radiance in f
+func NewEnvironmentMap(f *Film) *EnvironmentMap {
+	e := &EnvironmentMap{
+		width:     f.Width(),
+		height:    f.Height(),
+		pix:       make([]Color, f.Width()*f.Height()),
+		intensity: 1,
+		toWorld:   geo.Identity(),
+		toLocal:   geo.Identity(),
+	}
+	lum := make([]float32, len(e.pix))
+	for y := 0; y < e.height; y++ {
+		for x := 0; x < e.width; x++ {
+			e.pix[y*e.width+x] = f.At(x, y)
+			lum[y*e.width+x] = max(luminance(f.At(x, y)), 0)
+		}
+	}
+	// Bilinear filtering spreads every pixel over its neighbors,
+	// which must therefore be sampled as well
+	weights := make([]float32, len(e.pix))
+	for y := 0; y < e.height; y++ {
+		sinTheta := math32.Sin(math32.Pi * (float32(y) + 0.5) / float32(e.height))
+		for x := 0; x < e.width; x++ {
+			var w float32
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					w = max(w, lum[e.index(x+dx, y+dy)])
+				}
+			}
+			weights[y*e.width+x] = w * sinTheta
+		}
+	}
+	e.distribution = newDistribution2D(weights, e.width, e.height)
+	return e
+}
+
+// SetIntensity scales the radiance of e by s
+func (e *EnvironmentMap) SetIntensity(s float32) {
+	e.intensity = s
+}
+
+// SetRotation turns e counter clockwise about the y axis by degrees
+func (e *EnvironmentMap) SetRotation(degrees float32) {
+	e.toWorld = geo.Rotation(geo.UnitY, degrees)
+	e.toLocal = e.toWorld.Transposed()
+}
+
+// index wraps x around the horizon and clamps y at the poles
+func (e *EnvironmentMap) index(x, y int) int {
+	x %= e.width
+	if x < 0 {
+		x += e.width
+	}
+	y = min(max(y, 0), e.height-1)
+	return y*e.width + x
+}
+
+// uv returns the image coordinates of the unit direction dir
+func (e *EnvironmentMap) uv(dir geo.Vec3) (u, v float32) {
+	d := e.toLocal.MulDir(dir)
+	u = 0.5 + math32.Atan2(d.X(), -d.Z())/(2*math32.Pi)
+	v = math32.Acos(min(max(d.Y(), -1), 1)) / math32.Pi
+	return u, v
+}
+
+// Radiance implements the Environment interface for EnvironmentMap
+func (e *EnvironmentMap) Radiance(dir geo.Vec3) Color {
+	u, v := e.uv(dir.Normed())
+	x := u*float32(e.width) - 0.5
+	y := v*float32(e.height) - 0.5
+	x0, y0 := math32.Floor(x), math32.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	top := e.pix[e.index(ix, iy)].Mul(1 - fx).Add(e.pix[e.index(ix+1, iy)].Mul(fx))
+	bottom := e.pix[e.index(ix, iy+1)].Mul(1 - fx).Add(e.pix[e.index(ix+1, iy+1)].Mul(fx))
+	return top.Mul(1 - fy).Add(bottom.Mul(fy)).Mul(e.intensity)
+}
+
+// PdfValue implements the SampleableEnvironment interface for EnvironmentMap
+func (e *EnvironmentMap) PdfValue(dir geo.Vec3) float32 {
+	u, v := e.uv(dir.Normed())
+	sinTheta := math32.Sin(v * math32.Pi)
+	if sinTheta <= 0 {
+		return 0
+	}
+	// The map covers 2π by π radians
+	return e.distribution.pdf(u, v) / (2 * math32.Pi * math32.Pi * sinTheta)
+}
+
+// Random implements the SampleableEnvironment interface for EnvironmentMap
+func (e *EnvironmentMap) Random(rng *rand.Rand) geo.Vec3 {
+	u, v, _ := e.distribution.sample(rng)
+	sinTheta, cosTheta := math32.Sincos(v * math32.Pi)
+	sinPhi, cosPhi := math32.Sincos((u - 0.5) * 2 * math32.Pi)
+	return e.toWorld.MulDir(geo.NewVec3(sinTheta*sinPhi, cosTheta, -sinTheta*cosPhi))
+}
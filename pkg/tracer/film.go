@@ -0,0 +1,68 @@
+package tracer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Film is a floating point RGB framebuffer holding
+// the linear radiance estimate of every pixel
+type Film struct {
+	width, height int
+	pix           []float32
+}
+
+// NewFilm constructs a new black Film with the given resolution
+func NewFilm(width, height int) *Film {
+	return &Film{width: width, height: height, pix: make([]float32, 3*width*height)}
+}
+
+// Width returns the horizontal resolution of f
+func (f *Film) Width() int {
+	return f.width
+}
+
+// Height returns the vertical resolution of f
+func (f *Film) Height() int {
+	return f.height
+}
+
+// At returns the color of pixel x, y where y = 0 is the top row
+func (f *Film) At(x, y int) Color {
+	i := 3 * (y*f.width + x)
+	return NewColor(f.pix[i], f.pix[i+1], f.pix[i+2])
+}
+
+// Set sets the color of pixel x, y where y = 0 is the top row
+func (f *Film) Set(x, y int, c Color) {
+	i := 3 * (y*f.width + x)
+	f.pix[i] = c.R()
+	f.pix[i+1] = c.G()
+	f.pix[i+2] = c.B()
+}
+
+// Image converts f to an 8 bit sRGB image using the display transform dt
+func (f *Film) Image(dt DisplayTransform) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.width, f.height))
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			col := dt.Apply(f.At(x, y))
+			ir := quantize(LinearToSRGB(col.R()))
+			ig := quantize(LinearToSRGB(col.G()))
+			ib := quantize(LinearToSRGB(col.B()))
+			img.SetRGBA(x, y, color.RGBA{ir, ig, ib, 255})
+		}
+	}
+	return img
+}
+
+func quantize(c float32) uint8 {
+	if c >= 1 {
+		return 255
+	}
+	if !(c > 0) {
+		return 0
+	}
+	return uint8(math.Round(float64(255 * c)))
+}
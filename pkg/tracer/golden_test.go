@@ -0,0 +1,457 @@
+package tracer_test
+
+import (
+	"context"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/ies"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata/golden")
+
+const (
+	goldenDir   = "testdata/golden"
+	failuresDir = "testdata/failures"
+	// Renders on other platforms may differ slightly because of
+	// floating point contraction, which diverts some noisy paths
+	maxRMSE = 0.02
+	minSSIM = 0.95
+)
+
+type goldenScene struct {
+	name   string
+	scene  func() *tracer.Scene
+	camera func(aspectRatio float32) tracer.Camera
+	// spectral renders the scene with wavelengths
+	spectral bool
+}
+
+var goldenScenes = []goldenScene{
+	{"materials", materialsScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 9), geo.NewVec3(0, 0.6, 0), geo.UnitY, 35, aspectRatio, 0, 9)
+	}, false},
+	{"depth_of_field", materialsScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(-6, 1.5, 7), geo.NewVec3(0, 0.6, 0), geo.UnitY, 35, aspectRatio, 0.6, 6.5)
+	}, false},
+	{"bvh", bvhScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(13, 2, 3), geo.NewVec3(0, 0, 0), geo.UnitY, 25, aspectRatio, 0, 10)
+	}, false},
+	{"cornell_box", cornellBoxScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(278, 278, -800), geo.NewVec3(278, 278, 0), geo.UnitY, 40, aspectRatio, 0, 800)
+	}, false},
+	{"instances", instancesScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 5, 9), geo.NewVec3(0, 0.5, 0), geo.UnitY, 40, aspectRatio, 0, 10)
+	}, false},
+	{"motion_blur", motionBlurScene, func(aspectRatio float32) tracer.Camera {
+		camera := tracer.NewCamera(geo.NewVec3(0, 1.5, 6), geo.NewVec3(0, 0.5, 0), geo.UnitY, 40, aspectRatio, 0, 6)
+		camera.SetShutter(0, 1)
+		return camera
+	}, false},
+	{"microfacet", microfacetScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 0.6, 0), geo.UnitY, 35, aspectRatio, 0, 8)
+	}, false},
+	{"principled", principledScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 0.6, 0), geo.UnitY, 35, aspectRatio, 0, 8)
+	}, false},
+	{"absorption", absorptionScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 0.6, 0), geo.UnitY, 30, aspectRatio, 0, 8)
+	}, false},
+	{"dispersion", dispersionScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 0.6, 0), geo.UnitY, 30, aspectRatio, 0, 8)
+	}, true},
+	{"environment", environmentScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 0.6, 0), geo.UnitY, 30, aspectRatio, 0, 8)
+	}, false},
+	{"sun_sky", sunSkyScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 1.2, 0), geo.UnitY, 50, aspectRatio, 0, 8)
+	}, false},
+	{"lights", lightsScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 3, 9), geo.NewVec3(0, 0.6, 0), geo.UnitY, 35, aspectRatio, 0, 9)
+	}, false},
+	{"ies", iesScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCamera(geo.NewVec3(0, 2, 8), geo.NewVec3(0, 1.2, 0), geo.UnitY, 40, aspectRatio, 0, 8)
+	}, false},
+	{"orthographic", instancesScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewOrthographicCamera(geo.NewVec3(6, 6, 6), geo.NewVec3(0, 0.5, 0), geo.UnitY, 6, aspectRatio)
+	}, false},
+	{"fisheye", cornellBoxScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewFisheyeCamera(geo.NewVec3(278, 278, -100), geo.NewVec3(278, 278, 0), geo.UnitY, 180, aspectRatio, tracer.FisheyeEquisolid)
+	}, false},
+	{"equirectangular", sunSkyScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewEquirectangularCamera(geo.NewVec3(0, 1, 4), geo.NewVec3(0, 1, 0), geo.UnitY)
+	}, false},
+	{"cylindrical", materialsScene, func(aspectRatio float32) tracer.Camera {
+		return tracer.NewCylindricalCamera(geo.NewVec3(0, 1, 5), geo.NewVec3(0, 0.6, 0), geo.UnitY, 150, aspectRatio)
+	}, false},
+}
+
+// materialsScene shows one sphere of every material on a checkered floor
+func materialsScene() *tracer.Scene {
+	checker := tracer.NewCheckerTexture(tracer.NewSolidColor(0.2, 0.3, 0.1), tracer.NewSolidColor(0.9, 0.9, 0.9), 2)
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertianTexture(checker)),
+		tracer.NewSphere(geo.NewVec3(-3.3, 0.7, 0), 0.7, tracer.NewLambertian(0.7, 0.2, 0.1)),
+		tracer.NewSphere(geo.NewVec3(-1.65, 0.7, 0), 0.7, tracer.NewMetal(0.8, 0.8, 0.8, 0)),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, tracer.NewMetal(0.8, 0.6, 0.2, 0.3)),
+		tracer.NewSphere(geo.NewVec3(1.65, 0.7, 0), 0.7, tracer.NewDielectric(1.5)),
+		tracer.NewSphere(geo.NewVec3(0, 0.4, -3), 0.4, tracer.NewLambertianTexture(tracer.NewNoiseTexture(tracer.NoiseMarble, 6, 1))),
+	}
+	light := tracer.NewSphere(geo.NewVec3(3.3, 0.7, 0), 0.7, tracer.NewDiffuseLight(4, 4, 3))
+	scene := tracer.NewScene(append(l, light))
+	scene.Lights = []tracer.Light{tracer.NewAreaLight(light)}
+	return scene
+}
+
+// bvhScene has a few hundred spheres and triangles to exercise the BVH
+func bvhScene() *tracer.Scene {
+	rng := tracer.NewRand(42)
+	l := tracer.HitableList{tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.5, 0.5, 0.5))}
+	for a := -11; a < 11; a++ {
+		for b := -11; b < 11; b++ {
+			center := geo.NewVec3(float32(a)+0.9*rng.Float32(), 0.2, float32(b)+0.9*rng.Float32())
+			albedo := geo.NewVec3(rng.Float32(), rng.Float32(), rng.Float32())
+			switch (a + b) % 3 {
+			case 0:
+				l = append(l, tracer.NewSphere(center, 0.2, tracer.NewLambertian(albedo.X(), albedo.Y(), albedo.Z())))
+			case 1:
+				l = append(l, tracer.NewSphere(center, 0.2, tracer.NewMetal(albedo.X(), albedo.Y(), albedo.Z(), 0.2)))
+			default:
+				top := center.Add(geo.NewVec3(0, 0.3, 0))
+				m := tracer.NewLambertian(albedo.X(), albedo.Y(), albedo.Z())
+				l = append(l, tracer.NewTriangle(center.Sub(geo.NewVec3(0.2, 0.2, 0)), center.Add(geo.NewVec3(0.2, -0.2, 0)), top, m))
+			}
+		}
+	}
+	l = append(l, tracer.NewSphere(geo.NewVec3(0, 1, 0), 1.0, tracer.NewDielectric(1.5)))
+	return tracer.NewScene(l)
+}
+
+// cornellBoxScene is lit by a single area light and made of rectangles and boxes
+func cornellBoxScene() *tracer.Scene {
+	red := tracer.NewLambertian(0.65, 0.05, 0.05)
+	white := tracer.NewLambertian(0.73, 0.73, 0.73)
+	green := tracer.NewLambertian(0.12, 0.45, 0.15)
+	l := tracer.HitableList{
+		tracer.NewYZRect(0, 555, 0, 555, 555, green),
+		tracer.NewYZRect(0, 555, 0, 555, 0, red),
+		tracer.NewXZRect(0, 555, 0, 555, 0, white),
+		tracer.NewXZRect(0, 555, 0, 555, 555, white),
+		tracer.NewXYRect(0, 555, 0, 555, 555, white),
+		tracer.NewBox(geo.NewVec3(130, 0, 65), geo.NewVec3(295, 165, 230), white),
+		tracer.NewBox(geo.NewVec3(265, 0, 295), geo.NewVec3(430, 330, 460), white),
+	}
+	light := tracer.NewXZRect(213, 343, 227, 332, 554, tracer.NewDiffuseLight(15, 15, 15))
+	scene := tracer.NewScene(append(l, light))
+	scene.Lights = []tracer.Light{tracer.NewAreaLight(light)}
+	scene.Background = tracer.NewConstantEnvironment(tracer.Black)
+	return scene
+}
+
+// instancesScene places one box and one sphere several times with different transforms
+func instancesScene() *tracer.Scene {
+	box := tracer.NewBox(geo.NewVec3(-0.5, 0, -0.5), geo.NewVec3(0.5, 1, 0.5), tracer.NewLambertian(0.7, 0.3, 0.2))
+	ball := tracer.NewSphere(geo.Origin, 1, tracer.NewMetal(0.8, 0.8, 0.9, 0.05))
+	l := tracer.HitableList{tracer.NewXZRect(-20, 20, -20, 20, 0, tracer.NewLambertian(0.5, 0.5, 0.5))}
+	for i := 0; i < 5; i++ {
+		angle := float32(i) * 20
+		m := geo.Translation(geo.NewVec3(float32(i)*1.5-3, 0, -1)).Mul(geo.Rotation(geo.UnitY, angle)).Mul(geo.Scaling(geo.NewVec3(1, 1+0.4*float32(i), 1)))
+		in, err := tracer.NewInstance(box, m)
+		if err != nil {
+			panic(err)
+		}
+		l = append(l, in)
+	}
+	in, err := tracer.NewInstance(ball, geo.Translation(geo.NewVec3(0, 0.4, 1.5)).Mul(geo.Scaling(geo.NewVec3(1.5, 0.4, 0.8))))
+	if err != nil {
+		panic(err)
+	}
+	return tracer.NewScene(append(l, in))
+}
+
+// motionBlurScene has spheres moving sideways and up during the shutter interval
+func motionBlurScene() *tracer.Scene {
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.5, 0.5, 0.5)),
+		tracer.NewMovingSphere(geo.NewVec3(-2, 0.5, 0), geo.NewVec3(-1, 0.5, 0), 0, 1, 0.5, tracer.NewLambertian(0.8, 0.2, 0.1)),
+		tracer.NewMovingSphere(geo.NewVec3(1.5, 0.5, 0), geo.NewVec3(1.5, 1.2, 0), 0, 1, 0.5, tracer.NewMetal(0.8, 0.8, 0.8, 0.1)),
+		tracer.NewSphere(geo.NewVec3(0, 0.5, -1), 0.5, tracer.NewLambertian(0.1, 0.2, 0.7)),
+	}
+	return tracer.NewScene(l)
+}
+
+// microfacetScene compares rough conductors and rough glass lit by a small sphere light
+func microfacetScene() *tracer.Scene {
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.5, 0.5, 0.5)),
+		tracer.NewSphere(geo.NewVec3(-3, 0.7, 0), 0.7, tracer.NewConductor(tracer.IORGold, 0.2)),
+		tracer.NewSphere(geo.NewVec3(-1.5, 0.7, 0), 0.7, tracer.NewConductor(tracer.IORCopper, 0.5)),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, tracer.NewConductor(tracer.IORAluminium, 0)),
+		tracer.NewSphere(geo.NewVec3(1.5, 0.7, 0), 0.7, tracer.NewRoughDielectric(1.5, 0.3)),
+		tracer.NewSphere(geo.NewVec3(3, 0.7, 0), 0.7, tracer.NewRoughDielectric(1.5, 0)),
+	}
+	light := tracer.NewSphere(geo.NewVec3(2, 5, 3), 0.5, tracer.NewDiffuseLight(40, 40, 40))
+	scene := tracer.NewScene(append(l, light))
+	scene.Lights = []tracer.Light{tracer.NewAreaLight(light)}
+	return scene
+}
+
+// principledScene shows the lobes of the principled material and a glTF material
+func principledScene() *tracer.Scene {
+	principled := func(base tracer.Color, set func(p *tracer.PrincipledParams)) *tracer.Principled {
+		p := tracer.DefaultPrincipledParams()
+		p.BaseColor = tracer.NewSolidColor(base.R(), base.G(), base.B())
+		set(&p)
+		return tracer.NewPrincipled(p)
+	}
+	gltf := tracer.DefaultGLTFMetallicRoughness()
+	gltf.BaseColorFactor = [4]float32{1, 0.8, 0.2, 1}
+	gltf.MetallicFactor, gltf.RoughnessFactor = 0, 0.4
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, principled(tracer.NewColor(0.5, 0.5, 0.5), func(p *tracer.PrincipledParams) { p.Roughness = 0.9 })),
+		tracer.NewSphere(geo.NewVec3(-3, 0.7, 0), 0.7, principled(tracer.NewColor(0.6, 0.1, 0.1), func(p *tracer.PrincipledParams) { p.Clearcoat = 1 })),
+		tracer.NewSphere(geo.NewVec3(-1.5, 0.7, 0), 0.7, principled(tracer.NewColor(0.9, 0.6, 0.3), func(p *tracer.PrincipledParams) {
+			p.Metallic, p.Roughness, p.Anisotropic = 1, 0.3, 0.8
+		})),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, principled(tracer.NewColor(0.2, 0.3, 0.8), func(p *tracer.PrincipledParams) { p.Sheen, p.Roughness = 1, 1 })),
+		tracer.NewSphere(geo.NewVec3(1.5, 0.7, 0), 0.7, principled(tracer.NewColor(0.8, 1, 0.8), func(p *tracer.PrincipledParams) { p.Transmission, p.Roughness = 1, 0.1 })),
+		tracer.NewSphere(geo.NewVec3(3, 0.7, 0), 0.7, tracer.NewPrincipled(gltf.Principled())),
+	}
+	light := tracer.NewSphere(geo.NewVec3(2, 5, 3), 0.5, tracer.NewDiffuseLight(40, 40, 40))
+	scene := tracer.NewScene(append(l, light))
+	scene.Lights = []tracer.Light{tracer.NewAreaLight(light)}
+	return scene
+}
+
+// absorptionScene has tinted glass and a clear glass sphere filled with a colored liquid
+func absorptionScene() *tracer.Scene {
+	green := tracer.NewDielectric(1.5)
+	green.SetAbsorption(tracer.NewColor(0.2, 0.8, 0.3), 1)
+	liquid := tracer.NewDielectric(1.33)
+	liquid.SetAbsorption(tracer.NewColor(0.2, 0.3, 0.9), 0.5)
+	amber := tracer.NewRoughDielectric(1.5, 0.2)
+	amber.SetAbsorption(tracer.NewColor(0.9, 0.5, 0.1), 1)
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.7, 0.7, 0.7)),
+		tracer.NewSphere(geo.NewVec3(-2.2, 0.7, 0), 0.7, green),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, tracer.NewDielectric(1.5)),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.5, liquid),
+		tracer.NewSphere(geo.NewVec3(2.2, 0.7, 0), 0.7, amber),
+	}
+	light := tracer.NewSphere(geo.NewVec3(2, 5, 3), 0.5, tracer.NewDiffuseLight(40, 40, 40))
+	scene := tracer.NewScene(append(l, light))
+	scene.Lights = []tracer.Light{tracer.NewAreaLight(light)}
+	return scene
+}
+
+// dispersionScene has a diamond and a flint glass sphere
+// casting colored caustics from a small light
+func dispersionScene() *tracer.Scene {
+	diamond := tracer.NewDispersiveDielectric(tracer.SellmeierDiamond)
+	flint := tracer.NewDispersiveDielectric(tracer.Cauchy{A: 1.5, B: 0.05})
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.7, 0.7, 0.7)),
+		tracer.NewSphere(geo.NewVec3(-1.6, 0.7, 0), 0.7, diamond),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, flint),
+		tracer.NewSphere(geo.NewVec3(1.6, 0.7, 0), 0.7, tracer.NewLambertian(0.8, 0.2, 0.2)),
+	}
+	light := tracer.NewSphere(geo.NewVec3(0, 6, -4), 0.2, tracer.NewDiffuseLight(800, 800, 800))
+	scene := tracer.NewScene(append(l, light))
+	scene.Background = tracer.NewConstantEnvironment(tracer.Black)
+	scene.Lights = []tracer.Light{tracer.NewAreaLight(light)}
+	return scene
+}
+
+// environmentScene is lit by an environment map with a blue sky,
+// a brown ground and a small, bright sun
+func environmentScene() *tracer.Scene {
+	sky := tracer.NewFilm(64, 32)
+	for y := 0; y < sky.Height(); y++ {
+		for x := 0; x < sky.Width(); x++ {
+			c := tracer.NewColor(0.3, 0.5, 0.9)
+			if y >= sky.Height()/2 {
+				c = tracer.NewColor(0.25, 0.2, 0.15)
+			}
+			if x == 40 && y == 8 {
+				c = tracer.NewColor(400, 360, 300)
+			}
+			sky.Set(x, y, c)
+		}
+	}
+	env := tracer.NewEnvironmentMap(sky)
+	env.SetRotation(20)
+	env.SetIntensity(0.5)
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.5, 0.5, 0.5)),
+		tracer.NewSphere(geo.NewVec3(-1.6, 0.7, 0), 0.7, tracer.NewConductor(tracer.IORGold, 0.2)),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, tracer.NewLambertian(0.8, 0.8, 0.8)),
+		tracer.NewSphere(geo.NewVec3(1.6, 0.7, 0), 0.7, tracer.NewDielectric(1.5)),
+	}
+	scene := tracer.NewScene(l)
+	scene.Background = env
+	return scene
+}
+
+// sunSkyScene is lit by the daylight sky with a low sun in the west
+func sunSkyScene() *tracer.Scene {
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.5, 0.5, 0.5)),
+		tracer.NewSphere(geo.NewVec3(-1.6, 0.7, 0), 0.7, tracer.NewConductor(tracer.IORCopper, 0.2)),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, tracer.NewLambertian(0.8, 0.8, 0.8)),
+		tracer.NewSphere(geo.NewVec3(1.6, 0.7, 0), 0.7, tracer.NewDielectric(1.5)),
+	}
+	scene := tracer.NewScene(l)
+	scene.Background = tracer.NewSunSky(tracer.SunDirection(25, 250), 3)
+	return scene
+}
+
+// lightsScene is lit by a point, a spot, a directional and a disk light
+func lightsScene() *tracer.Scene {
+	disk := tracer.NewDisk(geo.NewVec3(0, 3, 0), geo.NewVec3(0, -1, 0), 0.5, tracer.NewDiffuseLight(4, 4, 4))
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.6, 0.6, 0.6)),
+		tracer.NewSphere(geo.NewVec3(-2.2, 0.7, 0), 0.7, tracer.NewLambertian(0.8, 0.3, 0.3)),
+		tracer.NewSphere(geo.NewVec3(0, 0.7, 0), 0.7, tracer.NewConductor(tracer.IORAluminium, 0.3)),
+		tracer.NewSphere(geo.NewVec3(2.2, 0.7, 0), 0.7, tracer.NewLambertian(0.3, 0.3, 0.8)),
+		disk,
+	}
+	scene := tracer.NewScene(l)
+	scene.Background = tracer.NewConstantEnvironment(tracer.Black)
+	scene.Lights = []tracer.Light{
+		tracer.NewPointLight(geo.NewVec3(-2.2, 3, 1.5), tracer.NewColor(6, 5, 4), 0.2),
+		tracer.NewSpotLight(geo.NewVec3(2.2, 4, 2), geo.NewVec3(2.2, 0, 0), tracer.NewColor(30, 30, 30), 15, 25),
+		tracer.NewDirectionalLight(geo.NewVec3(0, -1, -1), tracer.NewColor(0.3, 0.3, 0.4)),
+		tracer.NewAreaLight(disk),
+	}
+	return scene
+}
+
+// iesScene lights a wall with photometric profiles, one hanging straight
+// down and two aimed at the wall, turned so their beams lean sideways
+func iesScene() *tracer.Scene {
+	profile, err := ies.Load(os.DirFS("testdata"), "ies/washer.ies")
+	if err != nil {
+		panic(err)
+	}
+	l := tracer.HitableList{
+		tracer.NewSphere(geo.NewVec3(0, -1000, 0), 1000, tracer.NewLambertian(0.6, 0.6, 0.6)),
+		tracer.NewXYRect(-10, 10, -1, 10, -1, tracer.NewLambertian(0.7, 0.7, 0.7)),
+		tracer.NewSphere(geo.NewVec3(0, 0.5, 1), 0.5, tracer.NewLambertian(0.8, 0.6, 0.3)),
+	}
+	scene := tracer.NewScene(l)
+	scene.Background = tracer.NewConstantEnvironment(tracer.Black)
+	down := tracer.NewPointLight(geo.NewVec3(0, 2.5, 1), tracer.NewColor(0.02, 0.02, 0.02), 0)
+	down.SetProfile(profile, geo.NewVec3(0, -1, 0))
+	left := tracer.NewPointLight(geo.NewVec3(-2, 3, 0), tracer.NewColor(0.02, 0.013, 0.007), 0)
+	left.SetProfile(profile, geo.NewVec3(0, -1, -1))
+	right := tracer.NewSpotLight(geo.NewVec3(2, 3, 0), geo.NewVec3(2, 2, -1), tracer.NewColor(0.007, 0.013, 0.02), 80, 89)
+	right.SetProfile(profile, geo.NewVec3(0, -1, -1))
+	scene.Lights = []tracer.Light{down, left, right}
+	return scene
+}
+
+func renderGolden(t *testing.T, g goldenScene) *image.RGBA {
+	opts := tracer.RenderOptions{Width: 96, Height: 64, Samples: 16, MaxDepth: 10, BlockSize: 16, Spectral: g.spectral, Seed: 1}
+	film, err := tracer.Render(context.Background(), g.scene(), g.camera(float32(opts.Width)/float32(opts.Height)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return film.Image(tracer.DisplayTransform{})
+}
+
+func writePNG(t *testing.T, filename string, img image.Image) {
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readPNG(t *testing.T, filename string) image.Image {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// TestGolden renders small canonical scenes and compares them with the
+// reference images in testdata/golden. On failure the rendered image and
+// a difference image are written to testdata/failures for review; run
+// the tests with -update to accept intentional changes.
+func TestGolden(t *testing.T) {
+	for _, g := range goldenScenes {
+		t.Run(g.name, func(t *testing.T) {
+			got := renderGolden(t, g)
+			golden := filepath.Join(goldenDir, g.name+".png")
+			if *update {
+				writePNG(t, golden, got)
+				return
+			}
+			want := readPNG(t, golden)
+			if want.Bounds() != got.Bounds() {
+				t.Fatalf("image size is %v, want %v", got.Bounds(), want.Bounds())
+			}
+			e, s := rmse(got, want), ssim(got, want)
+			if e <= maxRMSE && s >= minSSIM {
+				return
+			}
+			if err := os.MkdirAll(failuresDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			writePNG(t, filepath.Join(failuresDir, g.name+".png"), got)
+			writePNG(t, filepath.Join(failuresDir, g.name+".diff.png"), diffImage(got, want))
+			t.Errorf("image differs from %s: RMSE %.4f (max %.4f), SSIM %.4f (min %.4f), see %s",
+				golden, e, maxRMSE, s, minSSIM, failuresDir)
+		})
+	}
+}
+
+// TestDeterministic checks that the image only depends on the seed
+// and not on the number of workers or the order of the blocks
+func TestDeterministic(t *testing.T) {
+	scene := bvhScene()
+	camera := goldenScenes[2].camera(1.5)
+	var films []*tracer.Film
+	for _, workers := range []int{1, 4} {
+		opts := tracer.RenderOptions{Width: 48, Height: 32, Samples: 2, MaxDepth: 5, Workers: workers, BlockSize: 7 * workers, Seed: 3}
+		film, err := tracer.Render(context.Background(), scene, camera, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		films = append(films, film)
+	}
+	for y := 0; y < films[0].Height(); y++ {
+		for x := 0; x < films[0].Width(); x++ {
+			if a, b := films[0].At(x, y), films[1].At(x, y); a != b {
+				t.Fatalf("pixel %d,%d is %v with 1 worker but %v with 4", x, y, a, b)
+			}
+		}
+	}
+}
+
+func TestRenderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := tracer.RenderOptions{Width: 32, Height: 32, Samples: 1}
+	if _, err := tracer.Render(ctx, bvhScene(), goldenScenes[2].camera(1), opts); err != context.Canceled {
+		t.Errorf("Render returned %v, want %v", err, context.Canceled)
+	}
+}
@@ -1,17 +1,38 @@
 package tracer
 
-import "github.com/robquant/tracer/pkg/geo"
+import (
+	"math/rand"
+
+	"github.com/robquant/tracer/pkg/geo"
+)
 
 // HitRecord
 type HitRecord struct {
-	t        float32
-	p        geo.Vec3
-	normal   geo.Vec3
+	t      float32
+	p      geo.Vec3
+	normal geo.Vec3
+	u, v   float32
+	// tangent points along the surface in the direction of increasing
+	// u, it is not normalized and zero where a shape has none
+	tangent  geo.Vec3
 	material Material
+	// wavelength is the hero wavelength in nm of a
+	// spectral path, zero when rendering in RGB
+	wavelength float32
+	// rng is the random number generator of the path, which volumes
+	// use to choose where a ray scatters inside them
+	rng *rand.Rand
 }
 
 func NewHitRecord(t float32, p, normal geo.Vec3, material Material) HitRecord {
-	return HitRecord{t, p, normal, material}
+	return HitRecord{t: t, p: p, normal: normal, material: material}
+}
+
+// SetRand sets the random number generator with which volumes like
+// ConstantMedium choose where rays hitting them scatter. Records passed
+// to the Hit method of anything containing volumes need one.
+func (h *HitRecord) SetRand(rng *rand.Rand) {
+	h.rng = rng
 }
 
 func (h HitRecord) Normal() geo.Vec3 {
@@ -22,11 +43,32 @@ func (h HitRecord) P() geo.Vec3 {
 	return h.p
 }
 
+// U returns the first surface coordinate of the hit point
+func (h HitRecord) U() float32 {
+	return h.u
+}
+
+// V returns the second surface coordinate of the hit point
+func (h HitRecord) V() float32 {
+	return h.v
+}
+
 func (h HitRecord) Material() Material {
 	return h.material
 }
 
+// Wavelength returns the wavelength in nm of the light being scattered,
+// or zero if the renderer works with RGB colors
+func (h HitRecord) Wavelength() float32 {
+	return h.wavelength
+}
+
+// Hitable is anything rays can hit
 type Hitable interface {
+	// Hit reports whether r hits between tMin and tMax and then fills in
+	// rec with the closest hit, otherwise it leaves rec unchanged. The
+	// random number generator set with SetRand must stay in rec, objects
+	// containing others pass rec on to them.
 	Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool
 	BoundingBox() (bool, geo.Aabb)
 }
@@ -9,14 +9,12 @@ func NewHitableList() HitableList {
 }
 
 func (l HitableList) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
-	var tempRec HitRecord
 	hitAnything := false
 	closestSoFar := tMax
 	for _, hitable := range l {
-		if hitable.Hit(r, tMin, closestSoFar, &tempRec) {
+		if hitable.Hit(r, tMin, closestSoFar, rec) {
 			hitAnything = true
-			closestSoFar = tempRec.t
-			*rec = tempRec
+			closestSoFar = rec.t
 		}
 	}
 	return hitAnything
@@ -0,0 +1,101 @@
+package tracer_test
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// luminance returns the Rec. 709 luma of every pixel of img in [0, 1]
+func luminance(img image.Image) []float64 {
+	b := img.Bounds()
+	lum := make([]float64, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			lum = append(lum, (0.2126*float64(r)+0.7152*float64(g)+0.0722*float64(bl))/0xffff)
+		}
+	}
+	return lum
+}
+
+// rmse returns the root mean square error over all color channels in [0, 1]
+func rmse(a, b image.Image) float64 {
+	bounds := a.Bounds()
+	var sum float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, _ := a.At(x, y).RGBA()
+			br, bg, bb, _ := b.At(x, y).RGBA()
+			for _, d := range []float64{
+				float64(ar) - float64(br), float64(ag) - float64(bg), float64(ab) - float64(bb),
+			} {
+				d /= 0xffff
+				sum += d * d
+			}
+		}
+	}
+	return math.Sqrt(sum / float64(3*bounds.Dx()*bounds.Dy()))
+}
+
+// ssim returns the mean structural similarity index of the luminance
+// of a and b computed over 7x7 windows, 1 means identical images
+func ssim(a, b image.Image) float64 {
+	const window = 7
+	const c1, c2 = 0.01 * 0.01, 0.03 * 0.03
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	la, lb := luminance(a), luminance(b)
+	var total float64
+	var count int
+	for y0 := 0; y0+window <= h; y0++ {
+		for x0 := 0; x0+window <= w; x0++ {
+			var ma, mb float64
+			for y := y0; y < y0+window; y++ {
+				for x := x0; x < x0+window; x++ {
+					ma += la[y*w+x]
+					mb += lb[y*w+x]
+				}
+			}
+			n := float64(window * window)
+			ma /= n
+			mb /= n
+			var va, vb, cov float64
+			for y := y0; y < y0+window; y++ {
+				for x := x0; x < x0+window; x++ {
+					da, db := la[y*w+x]-ma, lb[y*w+x]-mb
+					va += da * da
+					vb += db * db
+					cov += da * db
+				}
+			}
+			va /= n - 1
+			vb /= n - 1
+			cov /= n - 1
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			count++
+		}
+	}
+	if count == 0 {
+		return 1
+	}
+	return total / float64(count)
+}
+
+// diffImage visualizes the per pixel difference of a and b, black means
+// equal and the error is amplified 4 times to make small deviations visible
+func diffImage(a, b image.Image) *image.RGBA {
+	bounds := a.Bounds()
+	diff := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ar, ag, ab, _ := a.At(x, y).RGBA()
+			br, bg, bb, _ := b.At(x, y).RGBA()
+			channel := func(p, q uint32) uint8 {
+				d := math.Abs(float64(p)-float64(q)) / 0xffff * 4
+				return uint8(math.Min(d, 1) * 255)
+			}
+			diff.SetRGBA(x, y, color.RGBA{channel(ar, br), channel(ag, bg), channel(ab, bb), 255})
+		}
+	}
+	return diff
+}
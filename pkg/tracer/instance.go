@@ -0,0 +1,56 @@
+package tracer
+
+import (
+	"errors"
+
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Instance places a shared Hitable in the world using an affine transform.
+// Many instances can refer to the same object without copying its geometry.
+type Instance struct {
+	object    Hitable
+	toWorld   geo.Mat4
+	toObject  geo.Mat4
+	normalMat geo.Mat4
+	hasBox    bool
+	box       geo.Aabb
+}
+
+// NewInstance constructs a new Instance of object transformed by
+// toWorld, which must be invertible
+func NewInstance(object Hitable, toWorld geo.Mat4) (*Instance, error) {
+	toObject, ok := toWorld.Inverse()
+	if !ok {
+		return nil, errors.New("tracer: instance transform is not invertible")
+	}
+	hasBox, box := object.BoundingBox()
+	return &Instance{
+		object:    object,
+		toWorld:   toWorld,
+		toObject:  toObject,
+		normalMat: toObject.Transposed(),
+		hasBox:    hasBox,
+		box:       toWorld.TransformBox(box),
+	}, nil
+}
+
+// Hit transforms r into object space and the hit back into world space.
+// The ray direction is not normalized, so t is the same in both spaces.
+func (in *Instance) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	objRay := geo.NewRay(in.toObject.MulPoint(r.Orig()), in.toObject.MulDir(r.Dir()), r.Time())
+	if !in.object.Hit(&objRay, tMin, tMax, rec) {
+		return false
+	}
+	rec.p = in.toWorld.MulPoint(rec.p)
+	rec.normal = in.normalMat.MulDir(rec.normal).Normed()
+	rec.tangent = in.toWorld.MulDir(rec.tangent)
+	return true
+}
+
+// BoundingBox returns the world space box enclosing the transformed corners
+// of the object box. Under rotations this is not tight around the
+// transformed object, which only makes the hierarchy test more rays.
+func (in *Instance) BoundingBox() (bool, geo.Aabb) {
+	return in.hasBox, in.box
+}
@@ -0,0 +1,79 @@
+package tracer_test
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+func nearVec(a, b geo.Vec3) bool {
+	d := a.Sub(b)
+	return max(math32.Abs(d.X()), math32.Abs(d.Y()), math32.Abs(d.Z())) < 1e-4
+}
+
+func TestInstanceHit(t *testing.T) {
+	// A unit sphere stretched along x, turned so the long axis is
+	// along y, and moved to z = -5
+	toWorld := geo.Translation(geo.NewVec3(0, 0, -5)).
+		Mul(geo.Rotation(geo.UnitZ, 90)).
+		Mul(geo.Scaling(geo.NewVec3(2, 1, 1)))
+	in, err := tracer.NewInstance(tracer.NewSphere(geo.Origin, 1, nil), toWorld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root3 := math32.Sqrt(3)
+	tests := []struct {
+		name      string
+		orig, dir geo.Vec3
+		p, normal geo.Vec3
+	}{
+		{"long axis", geo.NewVec3(0, 5, -5), geo.NewVec3(0, -1, 0), geo.NewVec3(0, 2, -5), geo.UnitY},
+		{"short axis", geo.NewVec3(5, 0, -5), geo.NewVec3(-1, 0, 0), geo.NewVec3(1, 0, -5), geo.UnitX},
+		// The ellipsoid normal at (0, 1, root3/2) is along (0, 1/4, root3/2)
+		{"oblique", geo.NewVec3(0, 1, 0), geo.NewVec3(0, 0, -1),
+			geo.NewVec3(0, 1, -5+root3/2), geo.NewVec3(0, 0.25, root3/2).Normed()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := geo.NewRay(tt.orig, tt.dir, 0)
+			var rec tracer.HitRecord
+			if !in.Hit(&r, 0.001, math32.MaxFloat32, &rec) {
+				t.Fatal("ray missed the instance")
+			}
+			if !nearVec(rec.P(), tt.p) {
+				t.Errorf("got point %v, want %v", rec.P(), tt.p)
+			}
+			if !nearVec(rec.Normal(), tt.normal) {
+				t.Errorf("got normal %v, want %v", rec.Normal(), tt.normal)
+			}
+		})
+	}
+	r := geo.NewRay(geo.NewVec3(1.5, 0, 0), geo.NewVec3(0, 0, -1), 0)
+	var rec tracer.HitRecord
+	if in.Hit(&r, 0.001, math32.MaxFloat32, &rec) {
+		t.Errorf("ray beside the short axis hit at %v", rec.P())
+	}
+}
+
+func TestInstanceBoundingBox(t *testing.T) {
+	toWorld := geo.Translation(geo.NewVec3(1, 2, 3)).Mul(geo.Scaling(geo.NewVec3(2, 1, 0.5)))
+	in, err := tracer.NewInstance(tracer.NewSphere(geo.Origin, 1, nil), toWorld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, box := in.BoundingBox()
+	if !ok {
+		t.Fatal("instance of a sphere has no box")
+	}
+	if !nearVec(box.Min(), geo.NewVec3(-1, 1, 2.5)) || !nearVec(box.Max(), geo.NewVec3(3, 3, 3.5)) {
+		t.Errorf("got box %v %v, want (-1 1 2.5) (3 3 3.5)", box.Min(), box.Max())
+	}
+}
+
+func TestInstanceSingular(t *testing.T) {
+	if _, err := tracer.NewInstance(tracer.NewSphere(geo.Origin, 1, nil), geo.Scaling(geo.NewVec3(1, 1, 0))); err == nil {
+		t.Error("NewInstance accepted a flattening transform")
+	}
+}
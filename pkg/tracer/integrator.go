@@ -0,0 +1,241 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// pathTracer estimates the light arriving along camera rays. At every
+// scattering event it samples one of the scene's lights directly and
+// combines this with the light found by following the scattered ray
+// using multiple importance sampling.
+type pathTracer struct {
+	scene    *Scene
+	maxDepth int
+	// spectral paths carry wavelengths instead of RGB colors
+	spectral bool
+	// phase scatters light in the atmosphere of the scene
+	phase Material
+	// background is the Environment of the scene, env the same if it
+	// can be sampled
+	background Environment
+	env        SampleableEnvironment
+	// lights are the lights of the scene followed by env, if any
+	lights []Light
+}
+
+func newPathTracer(scene *Scene, opts *RenderOptions) *pathTracer {
+	pt := &pathTracer{scene: scene, maxDepth: opts.MaxDepth, spectral: opts.Spectral, background: scene.Background}
+	if pt.background == nil {
+		pt.background = DefaultSky()
+	}
+	pt.env, _ = pt.background.(SampleableEnvironment)
+	pt.lights = scene.Lights
+	if pt.env != nil {
+		pt.lights = append(pt.lights[:len(pt.lights):len(pt.lights)], environmentLight{pt.env})
+	}
+	if atm := scene.Atmosphere; atm != nil {
+		albedo := atm.Albedo
+		pt.phase = NewHenyeyGreenstein(NewSolidColor(albedo.R(), albedo.G(), albedo.B()), atm.G)
+	}
+	return pt
+}
+
+// colorAt returns the linear RGB color seen along r
+func (pt *pathTracer) colorAt(r *geo.Ray, rng *rand.Rand) Color {
+	if !pt.spectral {
+		return pt.radiance(r, nil, rng)
+	}
+	lambdas := sampleWavelengths(rng)
+	return lambdas.rgb(pt.radiance(r, &lambdas, rng))
+}
+
+// radiance estimates the light arriving along r. When rendering spectrally
+// the channels of all colors hold the values at lambdas, which is nil for
+// RGB rendering.
+func (pt *pathTracer) radiance(r *geo.Ray, lambdas *wavelengths, rng *rand.Rand) Color {
+	scene := pt.scene
+	radiance := Black
+	attenuation := NewColor(1, 1, 1)
+	currentRay := *r
+	// scatterPdf is the density with which the direction of currentRay was
+	// chosen, zero for camera rays and specular reflections which lights
+	// cannot sample
+	var scatterPdf float32
+	// interior holds the absorbing media the path is inside, innermost last
+	var media [4]Absorber
+	interior := media[:0]
+	var rec HitRecord
+	var hero float32
+	if lambdas != nil {
+		hero = lambdas[0]
+	}
+	// dispersed paths only carry the hero wavelength
+	dispersed := false
+	for depth := 0; depth < pt.maxDepth; depth++ {
+		rec.SetRand(rng)
+		hit := scene.World.Hit(&currentRay, 0.001, math.MaxFloat32, &rec)
+		if atm := scene.Atmosphere; atm != nil {
+			tEnd := atm.Extent / math32.Sqrt(currentRay.LenSq())
+			if hit {
+				tEnd = rec.t
+			}
+			if t, scatter := atm.sample(&currentRay, tEnd, rng); scatter {
+				rec = HitRecord{t: t, p: currentRay.At(t), normal: geo.UnitX, material: pt.phase}
+				hit = true
+			}
+		}
+		if !hit {
+			env := lambdas.spectrum(pt.background.Radiance(currentRay.Dir()))
+			if pt.env != nil && scatterPdf > 0 {
+				env = env.Mul(powerHeuristic(scatterPdf, pt.lightPdf(currentRay.Orig(), currentRay.Dir())))
+			}
+			return radiance.Add(attenuation.MulVec(env.Vec3))
+		}
+		rec.wavelength = hero
+		if n := len(interior); n > 0 {
+			distance := rec.t * math32.Sqrt(currentRay.LenSq())
+			attenuation = attenuation.MulVec(lambdas.spectrum(transmittance(interior[n-1], distance)).Vec3)
+		}
+		if emitter, ok := rec.Material().(Emitter); ok {
+			emitted := lambdas.spectrum(emitter.Emitted(rec.u, rec.v, rec.p))
+			if scatterPdf > 0 {
+				emitted = emitted.Mul(powerHeuristic(scatterPdf, pt.lightPdf(currentRay.Orig(), currentRay.Dir())))
+			}
+			radiance = radiance.Add(attenuation.MulVec(emitted.Vec3))
+		}
+		material := rec.Material()
+		wo := currentRay.Dir().Normed().Neg()
+		if len(pt.lights) > 0 {
+			var medium Absorber
+			if n := len(interior); n > 0 {
+				medium = interior[n-1]
+			}
+			direct := pt.sampleLight(&currentRay, wo, &rec, medium, lambdas, rng)
+			radiance = radiance.Add(attenuation.MulVec(direct.Vec3))
+		}
+		sample, ok := material.Sample(wo, &rec, rng)
+		if !ok {
+			return radiance
+		}
+		scatterPdf = 0
+		if !sample.Specular {
+			scatterPdf = sample.Pdf
+		}
+		if absorber, ok := material.(Absorber); ok {
+			interior = crossInterface(interior, absorber, wo, sample.Dir, rec.normal)
+		}
+		attenuation = attenuation.MulVec(lambdas.spectrum(sample.Weight).Vec3)
+		if sample.Dispersive && lambdas != nil && !dispersed {
+			// The other wavelengths could not have taken this direction,
+			// the hero wavelength now accounts for all of them
+			dispersed = true
+			attenuation = NewColor(attenuation.R()*float32(len(lambdas)), 0, 0)
+		}
+		currentRay = geo.NewRay(rec.p, sample.Dir, currentRay.Time())
+	}
+	return radiance
+}
+
+// lightPdf returns the density with which sampleLight
+// chooses direction dir from origin
+func (pt *pathTracer) lightPdf(origin, dir geo.Vec3) float32 {
+	if len(pt.lights) == 0 {
+		return 0
+	}
+	var sum float32
+	for _, light := range pt.lights {
+		sum += light.PdfValue(origin, dir)
+	}
+	return sum / float32(len(pt.lights))
+}
+
+// sampleLight estimates the light arriving directly from a randomly chosen
+// light at the hit point of rec which is scattered towards wo. The light
+// is attenuated by the absorbing medium around the hit point, if any.
+func (pt *pathTracer) sampleLight(r *geo.Ray, wo geo.Vec3, rec *HitRecord, medium Absorber, lambdas *wavelengths, rng *rand.Rand) Color {
+	ls, ok := pt.lights[rng.Intn(len(pt.lights))].Sample(rec.p, rng)
+	if !ok {
+		return Black
+	}
+	wi := ls.Dir
+	f := rec.Material().Eval(wo, wi, rec)
+	if f == Black {
+		return Black
+	}
+	shadow := geo.NewRay(rec.p, wi, r.Time())
+	var lightRec HitRecord
+	lightRec.SetRand(rng)
+	var emitted Color
+	var weight, dist float32
+	if ls.Punctual {
+		// Stop short of the light, which may sit on a surface
+		if ls.Pdf <= 0 || pt.scene.World.Hit(&shadow, 0.001, ls.Dist*(1-1e-4), &lightRec) {
+			return Black
+		}
+		emitted = lambdas.spectrum(ls.Radiance)
+		dist = ls.Dist
+		weight = float32(len(pt.lights)) / ls.Pdf
+	} else {
+		lightPdf := pt.lightPdf(rec.p, wi)
+		if lightPdf <= 0 {
+			return Black
+		}
+		// Whatever the shadow ray hits first is the light seen in this direction
+		if pt.scene.World.Hit(&shadow, 0.001, math.MaxFloat32, &lightRec) {
+			emitter, ok := lightRec.Material().(Emitter)
+			if !ok {
+				return Black
+			}
+			emitted = lambdas.spectrum(emitter.Emitted(lightRec.u, lightRec.v, lightRec.p))
+			dist = lightRec.t
+		} else if pt.env != nil {
+			emitted = lambdas.spectrum(pt.env.Radiance(wi))
+			dist = math.MaxFloat32
+			medium = nil
+		} else {
+			return Black
+		}
+		weight = powerHeuristic(lightPdf, rec.Material().Pdf(wo, wi, rec)) / lightPdf
+	}
+	if medium != nil {
+		emitted = emitted.MulVec(lambdas.spectrum(transmittance(medium, dist)).Vec3)
+	}
+	if atm := pt.scene.Atmosphere; atm != nil && atm.Density > 0 {
+		// Light from infinitely far away only crosses the extent of the atmosphere
+		if dist == math.MaxFloat32 {
+			dist = atm.Extent
+		}
+		weight *= math32.Exp(-atm.Density * dist)
+	}
+	return emitted.MulVec(lambdas.spectrum(f).Vec3).Mul(weight)
+}
+
+// transmittance is the fraction of light left after travelling distance
+// through the medium of absorber
+func transmittance(absorber Absorber, distance float32) Color {
+	sigma := absorber.Absorption()
+	return NewColor(math32.Exp(-sigma.R()*distance), math32.Exp(-sigma.G()*distance), math32.Exp(-sigma.B()*distance))
+}
+
+// crossInterface updates the absorbing media a path is inside when it
+// scatters from wo to wi at a surface of absorber with outward normal n
+func crossInterface(interior []Absorber, absorber Absorber, wo, wi, n geo.Vec3) []Absorber {
+	switch {
+	case wo.Dot(n) > 0 && wi.Dot(n) < 0:
+		// Clear media can be skipped, leaving them below is a no-op
+		if absorber.Absorption() != Black {
+			return append(interior, absorber)
+		}
+	case wo.Dot(n) < 0 && wi.Dot(n) > 0:
+		for i := len(interior) - 1; i >= 0; i-- {
+			if interior[i] == absorber {
+				return append(interior[:i], interior[i+1:]...)
+			}
+		}
+	}
+	return interior
+}
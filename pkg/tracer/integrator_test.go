@@ -0,0 +1,100 @@
+package tracer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// TestDirectLighting compares the light reflected by a diffuse floor with
+// the closed form irradiance of a disk and a sphere light above it. The
+// estimate combines light sampling with the scattered rays which hit the
+// lights, so it is only right if their weights add up to one.
+func TestDirectLighting(t *testing.T) {
+	const albedo = 0.5
+	floor := NewQuad(geo.NewVec3(-50, 0, 50), geo.NewVec3(100, 0, 0), geo.NewVec3(0, 0, -100), NewLambertian(albedo, albedo, albedo))
+	// A disk of radius r at height h straight above a point gives it the
+	// irradiance pi*L*r²/(h²+r²)
+	disk := NewDisk(geo.NewVec3(0, 1, 0), geo.UnitY.Neg(), 0.5, NewDiffuseLight(4, 4, 4))
+	diskRadiance := float32(albedo) * 4 * 0.25 / (1 + 0.25)
+	// A sphere of radius r at distance d whose center is at angle theta
+	// from the normal gives pi*L*(r/d)²*cos(theta), here with d = 2.5
+	sphere := NewSphere(geo.NewVec3(0, 1.5, -2), 0.5, NewDiffuseLight(20, 20, 20))
+	sphereRadiance := float32(albedo) * 20 * 0.04 * 0.6
+	tests := []struct {
+		name   string
+		lights []Sampleable
+		want   float32
+	}{
+		{"disk", []Sampleable{disk}, diskRadiance},
+		{"sphere", []Sampleable{sphere}, sphereRadiance},
+		{"both", []Sampleable{disk, sphere}, diskRadiance + sphereRadiance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			world := HitableList{floor}
+			var lights []Light
+			for _, shape := range tt.lights {
+				world = append(world, shape)
+				lights = append(lights, NewAreaLight(shape))
+			}
+			scene := &Scene{World: world, Background: NewConstantEnvironment(Black), Lights: lights}
+			pt := newPathTracer(scene, &RenderOptions{MaxDepth: 50})
+			rng := rand.New(rand.NewSource(1))
+			const n = 40000
+			var sum float32
+			for i := 0; i < n; i++ {
+				r := geo.NewRay(geo.NewVec3(0, 0.5, 2), geo.NewVec3(0, -0.5, -2), 0)
+				sum += pt.colorAt(&r, rng).G()
+			}
+			if got := sum / n; math32.Abs(got-tt.want) > 0.02*tt.want {
+				t.Errorf("got radiance %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAbsorption traces rays through spheres of index 1, which neither
+// bend nor reflect them, in front of a white background. The light left
+// after a chord of length l through a medium which keeps the fraction T
+// over unit distance is T^l by the Beer-Lambert law.
+func TestAbsorption(t *testing.T) {
+	a, b := NewColor(0.5, 0.25, 0.8), NewColor(0.9, 0.6, 0.3)
+	glass := NewDielectric(1)
+	glass.SetAbsorption(a, 1)
+	rough := NewRoughDielectric(1, 0)
+	rough.SetAbsorption(a, 1)
+	outer, inner := NewDielectric(1), NewDielectric(1)
+	outer.SetAbsorption(a, 1)
+	inner.SetAbsorption(b, 1)
+	pow := func(c Color, l float32) Color {
+		return NewColor(math32.Pow(c.R(), l), math32.Pow(c.G(), l), math32.Pow(c.B(), l))
+	}
+	center := geo.NewVec3(0, 0, -3)
+	tests := []struct {
+		name   string
+		world  HitableList
+		offset float32
+		want   Color
+	}{
+		{"through the center", HitableList{NewSphere(center, 1, glass)}, 0, pow(a, 2)},
+		{"off center", HitableList{NewSphere(center, 1, rough)}, 0.6, pow(a, 1.6)},
+		// The inner medium replaces the outer one along its own chord
+		{"nested", HitableList{NewSphere(center, 1, outer), NewSphere(center, 0.5, inner)}, 0, a.MulVec(b.Vec3)},
+		{"clear shell", HitableList{NewSphere(center, 1, NewDielectric(1)), NewSphere(center, 0.5, inner)}, 0, b},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scene := &Scene{World: tt.world, Background: NewConstantEnvironment(NewColor(1, 1, 1))}
+			pt := newPathTracer(scene, &RenderOptions{MaxDepth: 10})
+			rng := rand.New(rand.NewSource(1))
+			r := geo.NewRay(geo.NewVec3(tt.offset, 0, 0), geo.NewVec3(0, 0, -1), 0)
+			got := pt.colorAt(&r, rng)
+			if !near(got.R(), tt.want.R(), 1e-3) || !near(got.G(), tt.want.G(), 1e-3) || !near(got.B(), tt.want.B(), 1e-3) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
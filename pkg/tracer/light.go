@@ -0,0 +1,295 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Sampleable is a Hitable which can choose directions towards itself,
+// which lets the renderer sample emitting shapes explicitly
+type Sampleable interface {
+	Hitable
+	// PdfValue returns the solid angle density with which Random
+	// chooses direction dir from origin
+	PdfValue(origin, dir geo.Vec3) float32
+	// Random returns a direction from origin towards a random
+	// point on the surface
+	Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3
+}
+
+// Light is a source of light which the renderer samples
+// with shadow rays at every diffuse scattering event
+type Light interface {
+	// Sample chooses a direction from p towards the light,
+	// it returns false if there is none
+	Sample(p geo.Vec3, rng *rand.Rand) (LightSample, bool)
+	// PdfValue returns the solid angle density with which Sample chooses
+	// direction dir from p, zero for Punctual lights
+	PdfValue(p, dir geo.Vec3) float32
+}
+
+// LightSample is a direction chosen by a Light
+type LightSample struct {
+	// Dir is the unit direction from the shaded point towards the light
+	Dir geo.Vec3
+	// Punctual lights are not part of the scene, scattered rays cannot hit
+	// them. Their Radiance arrives along Dir unless something is closer
+	// than Dist, and Pdf is the density of Dir. The light of other lights
+	// is found by tracing Dir.
+	Punctual bool
+	Dist     float32
+	Radiance Color
+	Pdf      float32
+}
+
+// AreaLight is a Light for an emitting shape of the scene
+type AreaLight struct {
+	shape Sampleable
+}
+
+// NewAreaLight creates an AreaLight sampling shape,
+// which must also be part of the scene to be seen
+func NewAreaLight(shape Sampleable) *AreaLight {
+	return &AreaLight{shape: shape}
+}
+
+// Sample implements the Light interface for AreaLight
+func (l *AreaLight) Sample(p geo.Vec3, rng *rand.Rand) (LightSample, bool) {
+	dir := l.shape.Random(p, rng)
+	if dir.LenSq() == 0 {
+		return LightSample{}, false
+	}
+	return LightSample{Dir: dir.Normed()}, true
+}
+
+// PdfValue implements the Light interface for AreaLight
+func (l *AreaLight) PdfValue(p, dir geo.Vec3) float32 {
+	return l.shape.PdfValue(p, dir)
+}
+
+// environmentLight samples a SampleableEnvironment like a Light
+type environmentLight struct {
+	env SampleableEnvironment
+}
+
+func (l environmentLight) Sample(p geo.Vec3, rng *rand.Rand) (LightSample, bool) {
+	return LightSample{Dir: l.env.Random(rng).Normed()}, true
+}
+
+func (l environmentLight) PdfValue(p, dir geo.Vec3) float32 {
+	return l.env.PdfValue(dir)
+}
+
+// IntensityProfile describes how the intensity of a light varies with
+// direction, like the photometric data of a luminaire
+type IntensityProfile interface {
+	// Intensity returns the factor of the intensity at the vertical angle
+	// from the nadir and the horizontal angle around it in degrees
+	Intensity(vertical, horizontal float32) float32
+}
+
+// profiled is embedded by punctual lights which can be
+// shaped by an IntensityProfile
+type profiled struct {
+	profile IntensityProfile
+	// frame has the nadir as w axis and horizontal angle 0 along u
+	frame geo.ONB
+}
+
+// SetProfile shapes the intensity of the light by p, whose nadir points
+// along nadir. Horizontal angles start along the x axis for a nadir
+// straight down and turn towards the z axis.
+func (s *profiled) SetProfile(p IntensityProfile, nadir geo.Vec3) {
+	s.profile = p
+	s.frame = geo.NewONB(nadir.Normed())
+}
+
+// shape returns the factor of the intensity shining in unit direction dir
+func (s *profiled) shape(dir geo.Vec3) float32 {
+	if s.profile == nil {
+		return 1
+	}
+	d := s.frame.ToLocal(dir)
+	vertical := math32.Acos(min(max(d.Z(), -1), 1)) * 180 / math32.Pi
+	horizontal := math32.Atan2(d.Y(), d.X()) * 180 / math32.Pi
+	if horizontal < 0 {
+		horizontal += 360
+	}
+	return s.profile.Intensity(vertical, horizontal)
+}
+
+// PointLight shines equally in all directions from a position, unless it
+// has a profile. With a radius it is a sphere which casts soft shadows
+// but is not seen itself.
+type PointLight struct {
+	profiled
+	position  geo.Vec3
+	intensity Color
+	radius    float32
+}
+
+// NewPointLight creates a PointLight of intensity, the power per solid
+// angle, at position. The radius may be zero for hard shadows.
+func NewPointLight(position geo.Vec3, intensity Color, radius float32) *PointLight {
+	return &PointLight{position: position, intensity: intensity, radius: radius}
+}
+
+// Sample implements the Light interface for PointLight
+func (l *PointLight) Sample(p geo.Vec3, rng *rand.Rand) (LightSample, bool) {
+	d := l.position.Sub(p)
+	distSq := d.LenSq()
+	if l.radius == 0 {
+		if distSq == 0 {
+			return LightSample{}, false
+		}
+		dist := math32.Sqrt(distSq)
+		dir := d.Mul(1 / dist)
+		shape := l.shape(dir.Neg())
+		if shape == 0 {
+			return LightSample{}, false
+		}
+		return LightSample{Dir: dir, Punctual: true, Dist: dist, Radiance: l.intensity.Mul(shape / distSq), Pdf: 1}, true
+	}
+	r2 := l.radius * l.radius
+	if distSq <= r2 {
+		return LightSample{}, false
+	}
+	dist := math32.Sqrt(distSq)
+	// The whole sphere shines as its center does towards p
+	shape := l.shape(d.Mul(-1 / dist))
+	if shape == 0 {
+		return LightSample{}, false
+	}
+	// Sample the cone the sphere covers uniformly, using half angles
+	// which stay accurate for small and distant spheres
+	sinSqMax := r2 / distSq
+	oneMinusCosMax := sinSqMax / (1 + math32.Sqrt(1-sinSqMax))
+	halfTheta := math32.Asin(math32.Sqrt(rng.Float32() * oneMinusCosMax / 2))
+	sinTheta, cosTheta := math32.Sincos(2 * halfTheta)
+	sinPhi, cosPhi := math32.Sincos(2 * math32.Pi * rng.Float32())
+	dir := geo.NewONB(d.Mul(1 / dist)).Local(geo.NewVec3(sinTheta*cosPhi, sinTheta*sinPhi, cosTheta))
+	// Distance to the near side of the sphere
+	b := dir.Dot(d)
+	t := b - math32.Sqrt(max(b*b-distSq+r2, 0))
+	return LightSample{
+		Dir:      dir,
+		Punctual: true,
+		Dist:     t,
+		// A sphere of this radiance has the intensity in every direction
+		Radiance: l.intensity.Mul(shape / (math32.Pi * r2)),
+		Pdf:      1 / (2 * math32.Pi * oneMinusCosMax),
+	}, true
+}
+
+// PdfValue implements the Light interface for PointLight
+func (l *PointLight) PdfValue(p, dir geo.Vec3) float32 {
+	return 0
+}
+
+// SpotLight is a point light which only shines into a cone
+type SpotLight struct {
+	profiled
+	position, direction geo.Vec3
+	intensity           Color
+	cosInner, cosOuter  float32
+}
+
+// NewSpotLight creates a SpotLight at position pointing at target. Its
+// intensity, the power per solid angle, falls off smoothly from the
+// inner to the outer half angle of the cone in degrees.
+func NewSpotLight(position, target geo.Vec3, intensity Color, inner, outer float32) *SpotLight {
+	return &SpotLight{
+		position:  position,
+		direction: target.Sub(position).Normed(),
+		intensity: intensity,
+		cosInner:  math32.Cos(min(inner, outer) * math32.Pi / 180),
+		cosOuter:  math32.Cos(outer * math32.Pi / 180),
+	}
+}
+
+// falloff returns the fraction of the intensity shining
+// at cosine cosTheta from the axis of the cone
+func (l *SpotLight) falloff(cosTheta float32) float32 {
+	if cosTheta <= l.cosOuter {
+		return 0
+	}
+	if cosTheta >= l.cosInner {
+		return 1
+	}
+	t := (cosTheta - l.cosOuter) / (l.cosInner - l.cosOuter)
+	return t * t * (3 - 2*t)
+}
+
+// Sample implements the Light interface for SpotLight
+func (l *SpotLight) Sample(p geo.Vec3, rng *rand.Rand) (LightSample, bool) {
+	d := l.position.Sub(p)
+	distSq := d.LenSq()
+	if distSq == 0 {
+		return LightSample{}, false
+	}
+	dist := math32.Sqrt(distSq)
+	dir := d.Mul(1 / dist)
+	falloff := l.falloff(-dir.Dot(l.direction)) * l.shape(dir.Neg())
+	if falloff == 0 {
+		return LightSample{}, false
+	}
+	return LightSample{Dir: dir, Punctual: true, Dist: dist, Radiance: l.intensity.Mul(falloff / distSq), Pdf: 1}, true
+}
+
+// PdfValue implements the Light interface for SpotLight
+func (l *SpotLight) PdfValue(p, dir geo.Vec3) float32 {
+	return 0
+}
+
+// DirectionalLight shines from infinitely far away in one direction,
+// like the sun
+type DirectionalLight struct {
+	direction  geo.Vec3
+	irradiance Color
+}
+
+// NewDirectionalLight creates a DirectionalLight travelling in direction
+// which gives irradiance to surfaces facing it
+func NewDirectionalLight(direction geo.Vec3, irradiance Color) *DirectionalLight {
+	return &DirectionalLight{direction: direction.Normed(), irradiance: irradiance}
+}
+
+// Sample implements the Light interface for DirectionalLight
+func (l *DirectionalLight) Sample(p geo.Vec3, rng *rand.Rand) (LightSample, bool) {
+	return LightSample{Dir: l.direction.Neg(), Punctual: true, Dist: math.MaxFloat32, Radiance: l.irradiance, Pdf: 1}, true
+}
+
+// PdfValue implements the Light interface for DirectionalLight
+func (l *DirectionalLight) PdfValue(p, dir geo.Vec3) float32 {
+	return 0
+}
+
+// areaPdf converts the uniform density 1/area of points on the flat
+// Hitable h with unit normal n to the solid angle density of directions
+// seen from origin
+func areaPdf(h Hitable, area float32, n, origin, dir geo.Vec3) float32 {
+	var rec HitRecord
+	r := geo.NewRay(origin, dir, 0)
+	if !h.Hit(&r, 0.001, math.MaxFloat32, &rec) {
+		return 0
+	}
+	lenSq := dir.LenSq()
+	cosine := math32.Abs(dir.Dot(n)) / math32.Sqrt(lenSq)
+	if cosine < 1e-6 {
+		return 0
+	}
+	return rec.t * rec.t * lenSq / (cosine * area)
+}
+
+// powerHeuristic weights a sample taken with density pdf against
+// another strategy which would have chosen it with density other
+func powerHeuristic(pdf, other float32) float32 {
+	a, b := pdf*pdf, other*other
+	if a+b == 0 {
+		return 0
+	}
+	return a / (a + b)
+}
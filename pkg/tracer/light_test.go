@@ -0,0 +1,127 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// floorRadiance returns the mean radiance of n paths seen
+// at the origin of a diffuse floor with albedo 0.5 lit by light
+func floorRadiance(light Light, n int) float32 {
+	floor := NewQuad(geo.NewVec3(-50, 0, 50), geo.NewVec3(100, 0, 0), geo.NewVec3(0, 0, -100), NewLambertian(0.5, 0.5, 0.5))
+	scene := &Scene{World: HitableList{floor}, Background: NewConstantEnvironment(Black), Lights: []Light{light}}
+	pt := newPathTracer(scene, &RenderOptions{MaxDepth: 5})
+	rng := rand.New(rand.NewSource(1))
+	var sum float32
+	for i := 0; i < n; i++ {
+		r := geo.NewRay(geo.NewVec3(0, 1, 1), geo.NewVec3(0, -1, -1), 0)
+		sum += pt.colorAt(&r, rng).G()
+	}
+	return sum / float32(n)
+}
+
+// TestPunctualLights compares the light reflected by a diffuse floor with
+// the closed form irradiance of punctual lights. A light of intensity I at
+// distance d and angle theta from the normal gives the irradiance
+// I cos(theta) / d², also when it is a uniformly shining sphere, and the
+// floor reflects albedo / pi of it.
+func TestPunctualLights(t *testing.T) {
+	const albedo = 0.5
+	tests := []struct {
+		name  string
+		light Light
+		n     int
+		want  float32
+	}{
+		{"point", NewPointLight(geo.NewVec3(0, 2, 0), NewColor(8, 8, 8), 0), 1, albedo / math32.Pi * 8 / 4},
+		{"point at an angle", NewPointLight(geo.NewVec3(1.5, 2, 0), NewColor(8, 8, 8), 0), 1, albedo / math32.Pi * 8 * 0.8 / 6.25},
+		{"sphere", NewPointLight(geo.NewVec3(1.5, 2, 0), NewColor(8, 8, 8), 0.5), 20000, albedo / math32.Pi * 8 * 0.8 / 6.25},
+		{"spot", NewSpotLight(geo.NewVec3(1.5, 2, 0), geo.Vec3{}, NewColor(8, 8, 8), 10, 20), 1, albedo / math32.Pi * 8 * 0.8 / 6.25},
+		{"directional", NewDirectionalLight(geo.NewVec3(-3, -4, 0), NewColor(2, 2, 2)), 1, albedo / math32.Pi * 2 * 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := floorRadiance(tt.light, tt.n); math32.Abs(got-tt.want) > 0.01*tt.want {
+				t.Errorf("got radiance %.5f, want %.5f", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSpotLightFalloff checks that a spot light shines fully inside its
+// inner cone, not at all outside its outer cone and smoothly in between
+func TestSpotLightFalloff(t *testing.T) {
+	const inner, outer = 20, 40
+	l := NewSpotLight(geo.NewVec3(0, 1, 0), geo.Vec3{}, NewColor(1, 1, 1), inner, outer)
+	rng := rand.New(rand.NewSource(1))
+	prev := float32(2)
+	for deg := float32(0); deg < 60; deg++ {
+		// A point at distance 1 from the light, deg degrees off its axis
+		sin, cos := math32.Sincos(deg * math32.Pi / 180)
+		s, ok := l.Sample(geo.NewVec3(sin, 1-cos, 0), rng)
+		var got float32
+		if ok {
+			got = s.Radiance.G()
+		}
+		switch {
+		case deg <= inner-0.5:
+			if !near(got, 1, 1e-4) {
+				t.Errorf("%g degrees off axis inside the inner cone: intensity %g, want 1", deg, got)
+			}
+		case deg >= outer+0.5:
+			if ok || got != 0 {
+				t.Errorf("%g degrees off axis outside the outer cone: intensity %g, want 0", deg, got)
+			}
+		default:
+			if got > prev || got < 0 || got > 1 {
+				t.Errorf("%g degrees off axis: intensity %g after %g", deg, got, prev)
+			}
+		}
+		prev = got
+	}
+}
+
+// TestAreaLightPdf integrates over the directions towards shapes sampled
+// by area lights weighted by 1/PdfValue, which only gives their solid
+// angles in closed form if PdfValue is the density of Sample
+func TestAreaLightPdf(t *testing.T) {
+	const a, b, d, r = 1.0, 2.0, 1.5, 0.5
+	light := NewDiffuseLight(1, 1, 1)
+	tests := []struct {
+		name  string
+		shape Sampleable
+		want  float64
+	}{
+		// A rectangle of sides a and b centered at distance d
+		{"quad", NewQuad(geo.NewVec3(-a/2, d, -b/2), geo.NewVec3(a, 0, 0), geo.NewVec3(0, 0, b), light),
+			4 * math.Atan(a*b/(2*d*math.Sqrt(4*d*d+a*a+b*b)))},
+		// A disk of radius r centered at distance d
+		{"disk", NewDisk(geo.NewVec3(0, d, 0), geo.UnitY.Neg(), r, light), 2 * math.Pi * (1 - d/math.Sqrt(d*d+r*r))},
+		// A sphere of radius r at distance d
+		{"sphere", NewSphere(geo.NewVec3(0, d, 0), r, light), 2 * math.Pi * (1 - math.Sqrt(1-r*r/(d*d)))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewAreaLight(tt.shape)
+			rng := rand.New(rand.NewSource(1))
+			const n = 100000
+			var sum float64
+			for i := 0; i < n; i++ {
+				s, ok := l.Sample(geo.Vec3{}, rng)
+				if !ok {
+					t.Fatal("no sample")
+				}
+				if pdf := l.PdfValue(geo.Vec3{}, s.Dir); pdf > 0 {
+					sum += 1 / float64(pdf)
+				}
+			}
+			if got := sum / n; math.Abs(got-tt.want) > 0.01*tt.want {
+				t.Errorf("solid angle %g sr, want %g", got, tt.want)
+			}
+		})
+	}
+}
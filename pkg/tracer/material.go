@@ -7,36 +7,100 @@ import (
 	"github.com/robquant/tracer/pkg/geo"
 )
 
-// Material is an interface to describe light scattering on different materials
+// Material is an interface to describe light scattering on different materials.
+// All directions are unit vectors pointing away from the hit point, wo towards
+// the viewer and wi towards the light.
 type Material interface {
-	// Scatter takes the incident ray and a HitRecord and
-	// returns true if the ray was not absorbed, the scattered fraction
-	// of light in each color channel and the scattered ray
-	Scatter(r *geo.Ray, h *HitRecord, rng *rand.Rand) (bool, geo.Vec3, geo.Ray)
+	// Sample chooses the direction wi for light scattered towards wo,
+	// it returns false if the light is absorbed
+	Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool)
+	// Eval returns the fraction of light arriving from wi which is scattered
+	// towards wo, multiplied by the cosine term. Specular lobes are not
+	// included because they only scatter into single directions.
+	Eval(wo, wi geo.Vec3, h *HitRecord) Color
+	// Pdf returns the solid angle density with which Sample chooses wi
+	Pdf(wo, wi geo.Vec3, h *HitRecord) float32
+}
+
+// ScatterSample is a direction chosen by Material.Sample
+type ScatterSample struct {
+	// Dir is the unit direction towards the light
+	Dir geo.Vec3
+	// Weight is the fraction of light scattered divided by the
+	// density of Dir, the factor a path's throughput changes by
+	Weight Color
+	// Pdf is the solid angle density of Dir, zero for specular samples
+	Pdf float32
+	// Specular samples come from a delta distribution
+	// which Eval and Pdf do not cover
+	Specular bool
+	// Dispersive samples have a direction which only holds for the
+	// wavelength of the HitRecord, other wavelengths would scatter
+	// into a different direction
+	Dispersive bool
 }
 
 // Lambertian holds albedo for a lambertian scattering surface
 type Lambertian struct {
-	albedo geo.Vec3
+	albedo Texture
 }
 
 // NewLambertian creates new Lambertian from r,g,b albedo values
 func NewLambertian(ar, ag, ab float32) *Lambertian {
-	return &Lambertian{albedo: geo.NewVec3(ar, ag, ab)}
+	return &Lambertian{albedo: NewSolidColor(ar, ag, ab)}
+}
+
+// NewLambertianTexture creates a new Lambertian with an albedo varying over the surface
+func NewLambertianTexture(albedo Texture) *Lambertian {
+	return &Lambertian{albedo: albedo}
+}
+
+// Sample implements the Material interface for Lambertian,
+// directions are cosine distributed around the normal
+func (l *Lambertian) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	onb := geo.NewONB(faceForward(h.Normal(), wo.Neg()))
+	dir := onb.Local(randomCosineDirection(rng))
+	return ScatterSample{Dir: dir, Weight: l.albedo.Value(h.u, h.v, h.p), Pdf: dir.Dot(onb.W()) / math32.Pi}, true
+}
+
+// Eval implements the Material interface for Lambertian
+func (l *Lambertian) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	cosine := faceForward(h.Normal(), wo.Neg()).Dot(wi)
+	if cosine <= 0 {
+		return Black
+	}
+	return l.albedo.Value(h.u, h.v, h.p).Mul(cosine / math32.Pi)
+}
+
+// Pdf implements the Material interface for Lambertian
+func (l *Lambertian) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	return max(0, faceForward(h.Normal(), wo.Neg()).Dot(wi)) / math32.Pi
 }
 
-// Scatter implements Material Scatter interface for Lambertian
-func (l *Lambertian) Scatter(r *geo.Ray, h *HitRecord, rng *rand.Rand) (bool, geo.Vec3, geo.Ray) {
-	target := h.P().Add(h.Normal()).Add(RandomInUnitSphere(rng))
-	return true, l.albedo, geo.NewRay(h.P(), target.Sub(h.P()))
+// randomCosineDirection returns a unit vector in the hemisphere around +Z
+// with a density proportional to the cosine of its angle to +Z
+func randomCosineDirection(rng *rand.Rand) geo.Vec3 {
+	r2 := rng.Float32()
+	phi := 2 * math32.Pi * rng.Float32()
+	r := math32.Sqrt(r2)
+	return geo.NewVec3(r*math32.Cos(phi), r*math32.Sin(phi), math32.Sqrt(1-r2))
 }
 
 // Metal hold albedo for a Metal surface
 type Metal struct {
-	albedo geo.Vec3
+	albedo Texture
 	fuzz   float32
 }
 
+// faceForward flips n if necessary so that it points against dir,
+// which is needed for surfaces like triangles that can be hit from behind
+func faceForward(n, dir geo.Vec3) geo.Vec3 {
+	if n.Dot(dir) > 0 {
+		return n.Neg()
+	}
+	return n
+}
+
 func reflect(v, n geo.Vec3) geo.Vec3 {
 	return v.Sub(n.Mul(2 * v.Dot(n)))
 }
@@ -63,51 +127,176 @@ func schlick(cosine, refIdx float32) float32 {
 
 // NewMetal constructs a new Metal from r,g,b albedo values
 func NewMetal(ar, ag, ab float32, fuzz float32) *Metal {
+	return NewMetalTexture(NewSolidColor(ar, ag, ab), fuzz)
+}
+
+// NewMetalTexture constructs a new Metal with an albedo varying over the surface
+func NewMetalTexture(albedo Texture, fuzz float32) *Metal {
 	if fuzz > 1 {
 		fuzz = 1
 	}
-	return &Metal{albedo: geo.NewVec3(ar, ag, ab), fuzz: fuzz}
+	return &Metal{albedo: albedo, fuzz: fuzz}
+}
+
+// Sample implements the Material interface for Metal. The reflection is
+// specular, fuzz only perturbs the mirrored direction.
+func (m *Metal) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	normal := faceForward(h.Normal(), wo.Neg())
+	dir := reflect(wo.Neg(), normal).Add(RandomInUnitSphere(rng).Mul(m.fuzz))
+	if dir.Dot(normal) <= 0 {
+		return ScatterSample{}, false
+	}
+	return ScatterSample{Dir: dir.Normed(), Weight: m.albedo.Value(h.u, h.v, h.p), Specular: true}, true
+}
+
+// Eval implements the Material interface for Metal, which has no diffuse part
+func (m *Metal) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	return Black
 }
 
-// Scatter implements the Material interface Scatter function for Metal
-func (m *Metal) Scatter(r *geo.Ray, h *HitRecord, rng *rand.Rand) (bool, geo.Vec3, geo.Ray) {
-	reflected := reflect(r.Dir().Normed(), h.Normal())
-	scattered := geo.NewRay(h.P(), reflected.Add(RandomInUnitSphere(rng).Mul(m.fuzz)))
-	return scattered.Dir().Dot(h.Normal()) > 0, m.albedo, scattered
+// Pdf implements the Material interface for Metal
+func (m *Metal) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	return 0
 }
 
+// Dielectric is a clear material like glass or water which
+// reflects and refracts light according to its refractive index
 type Dielectric struct {
-	refIdx float32
+	refIdx     float32
+	dispersion IORCurve
+	absorption
+}
+
+// Absorber is implemented by materials whose interior absorbs light
+// following the Beer-Lambert law
+type Absorber interface {
+	// Absorption returns the absorption coefficient per unit length
+	// of each color channel
+	Absorption() Color
+}
+
+// absorption is embedded by dielectrics to implement Absorber
+type absorption struct {
+	sigma Color
+}
+
+// SetAbsorption tints the interior so that light travelling distance
+// inside keeps the fraction transmittance of each color channel
+func (a *absorption) SetAbsorption(transmittance Color, distance float32) {
+	coefficient := func(t float32) float32 {
+		return -math32.Log(min(max(t, 1e-6), 1)) / distance
+	}
+	a.sigma = NewColor(coefficient(transmittance.R()), coefficient(transmittance.G()), coefficient(transmittance.B()))
 }
 
+// Absorption implements the Absorber interface
+func (a *absorption) Absorption() Color {
+	return a.sigma
+}
+
+// NewDielectric constructs a new Dielectric with refractive index refIdx
 func NewDielectric(refIdx float32) *Dielectric {
 	return &Dielectric{refIdx: refIdx}
 }
 
-func (d *Dielectric) Scatter(r *geo.Ray, h *HitRecord, rng *rand.Rand) (bool, geo.Vec3, geo.Ray) {
-	reflected := reflect(r.Dir(), h.Normal())
-	attenuation := geo.NewVec3(1.0, 1.0, 1.0)
+// NewDispersiveDielectric creates a Dielectric whose refractive index
+// follows curve
+func NewDispersiveDielectric(curve IORCurve) *Dielectric {
+	d := &Dielectric{}
+	d.SetDispersion(curve)
+	return d
+}
+
+// SetDispersion makes the refractive index depend on the wavelength,
+// which is evaluated at the sodium D line when rendering in RGB
+func (d *Dielectric) SetDispersion(curve IORCurve) {
+	d.dispersion = curve
+	d.refIdx = curve.IOR(sodiumD)
+}
+
+// Sample implements the Material interface for Dielectric, choosing
+// between the specular reflection and refraction by their Fresnel weights
+func (d *Dielectric) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	refIdx := d.refIdx
+	if d.dispersion != nil && h.wavelength > 0 {
+		refIdx = d.dispersion.IOR(h.wavelength)
+	}
+	dir := wo.Neg()
+	reflected := reflect(dir, h.Normal())
 	var outwardNormal geo.Vec3
 	var refRatio float32
 	var cosine float32
 	var reflectionProb float32 = 1.0
-	s := r.Dir().Dot(h.Normal())
+	s := dir.Dot(h.Normal())
 	if s > 0 {
 		outwardNormal = h.Normal().Mul(-1)
-		refRatio = d.refIdx
-		cosine = d.refIdx * s / r.Dir().Len()
+		refRatio = refIdx
+		cosine = refIdx * s
 	} else {
 		outwardNormal = h.Normal()
-		refRatio = 1.0 / d.refIdx
-		cosine = -s / r.Dir().Len()
+		refRatio = 1.0 / refIdx
+		cosine = -s
 	}
 	var refracted bool
 	var refractedDir geo.Vec3
-	if refracted, refractedDir = refract(r.Dir(), outwardNormal, refRatio); refracted {
-		reflectionProb = schlick(cosine, d.refIdx)
+	if refracted, refractedDir = refract(dir, outwardNormal, refRatio); refracted {
+		reflectionProb = schlick(cosine, refIdx)
 	}
+	sample := ScatterSample{Dir: refractedDir, Weight: NewColor(1, 1, 1), Specular: true, Dispersive: d.dispersion != nil}
 	if rng.Float32() < reflectionProb {
-		return true, attenuation, geo.NewRay(h.P(), reflected)
+		sample.Dir = reflected
+		sample.Dispersive = false
 	}
-	return true, attenuation, geo.NewRay(h.P(), refractedDir)
+	return sample, true
+}
+
+// Eval implements the Material interface for Dielectric, which has no diffuse part
+func (d *Dielectric) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	return Black
+}
+
+// Pdf implements the Material interface for Dielectric
+func (d *Dielectric) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	return 0
+}
+
+// Emitter is implemented by materials which emit light
+type Emitter interface {
+	// Emitted returns the light emitted at surface coordinates u, v and point p
+	Emitted(u, v float32, p geo.Vec3) Color
+}
+
+// DiffuseLight is a material emitting light equally in all directions
+type DiffuseLight struct {
+	emit Texture
+}
+
+// NewDiffuseLight creates a new DiffuseLight from r,g,b radiance values
+func NewDiffuseLight(r, g, b float32) *DiffuseLight {
+	return &DiffuseLight{emit: NewSolidColor(r, g, b)}
+}
+
+// NewDiffuseLightTexture creates a new DiffuseLight with a radiance varying over the surface
+func NewDiffuseLightTexture(emit Texture) *DiffuseLight {
+	return &DiffuseLight{emit: emit}
+}
+
+// Sample implements the Material interface for DiffuseLight, light sources absorb all light
+func (d *DiffuseLight) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	return ScatterSample{}, false
+}
+
+// Eval implements the Material interface for DiffuseLight
+func (d *DiffuseLight) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	return Black
+}
+
+// Pdf implements the Material interface for DiffuseLight
+func (d *DiffuseLight) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	return 0
+}
+
+// Emitted implements the Emitter interface for DiffuseLight
+func (d *DiffuseLight) Emitted(u, v float32, p geo.Vec3) Color {
+	return d.emit.Value(u, v, p)
 }
@@ -0,0 +1,87 @@
+package tracer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// TestMaterialSampling checks for every material with a non-specular
+// part that Pdf is the density of the directions Sample chooses, and that
+// the weight of a sample is Eval divided by Pdf
+func TestMaterialSampling(t *testing.T) {
+	principled := DefaultPrincipledParams()
+	principled.Metallic = 0.3
+	principled.Sheen = 0.5
+	principled.Clearcoat = 0.5
+	brushed := DefaultPrincipledParams()
+	brushed.Metallic, brushed.Roughness, brushed.Anisotropic = 1, 0.5, 0.8
+	glass := DefaultPrincipledParams()
+	glass.Roughness = 0.6
+	glass.Transmission = 0.8
+	outside := geo.NewVec3(0.4, 0.2, 0.9).Normed()
+	inside := geo.NewVec3(0.3, -0.1, -0.9).Normed()
+	tests := []struct {
+		name     string
+		material Material
+		wo       geo.Vec3
+	}{
+		{"lambertian", NewLambertian(0.5, 0.6, 0.7), outside},
+		{"lambertian from behind", NewLambertian(0.5, 0.6, 0.7), inside},
+		{"isotropic", NewIsotropic(0.5, 0.6, 0.7), outside},
+		{"henyey-greenstein", NewHenyeyGreenstein(NewSolidColor(0.9, 0.9, 0.9), 0.5), outside},
+		{"conductor", NewConductor(IORGold, 0.6), outside},
+		{"rough dielectric", NewRoughDielectric(1.5, 0.6), outside},
+		{"rough dielectric from inside", NewRoughDielectric(1.5, 0.6), inside},
+		{"principled", NewPrincipled(principled), outside},
+		{"principled anisotropic", NewPrincipled(brushed), outside},
+		{"principled transmission", NewPrincipled(glass), outside},
+		{"principled transmission from inside", NewPrincipled(glass), inside},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := HitRecord{normal: geo.UnitZ, tangent: geo.NewVec3(1, 2, 0.5), material: tt.material}
+			rng := rand.New(rand.NewSource(1))
+			const n = 100000
+			found := 0
+			for i := 0; i < n; i++ {
+				s, ok := tt.material.Sample(tt.wo, &rec, rng)
+				if !ok || s.Specular {
+					continue
+				}
+				found++
+				pdf := tt.material.Pdf(tt.wo, s.Dir, &rec)
+				if !near(s.Pdf, pdf, 1e-3) {
+					t.Fatalf("sample %v has pdf %g, Pdf is %g", s.Dir, s.Pdf, pdf)
+				}
+				want := tt.material.Eval(tt.wo, s.Dir, &rec).Mul(1 / pdf)
+				if !near(s.Weight.R(), want.R(), 1e-3) || !near(s.Weight.G(), want.G(), 1e-3) || !near(s.Weight.B(), want.B(), 1e-3) {
+					t.Fatalf("sample %v has weight %v, Eval/Pdf is %v", s.Dir, s.Weight, want)
+				}
+			}
+			// Integrating Pdf over the sphere gives the
+			// fraction of non-specular samples
+			const nz, nphi = 500, 1000
+			var integral float32
+			for i := 0; i < nz; i++ {
+				z := -1 + (float32(i)+0.5)*2/nz
+				r := math32.Sqrt(1 - z*z)
+				for j := 0; j < nphi; j++ {
+					sin, cos := math32.Sincos((float32(j) + 0.5) * 2 * math32.Pi / nphi)
+					integral += tt.material.Pdf(tt.wo, geo.NewVec3(r*cos, r*sin, z), &rec)
+				}
+			}
+			integral *= 4 * math32.Pi / (nz * nphi)
+			if want := float32(found) / n; math32.Abs(integral-want) > 0.02 {
+				t.Errorf("Pdf integrates to %.4f, want %.4f", integral, want)
+			}
+		})
+	}
+}
+
+// near reports whether a and b agree to a relative tolerance of tol
+func near(a, b, tol float32) bool {
+	return math32.Abs(a-b) <= tol*max(math32.Abs(a), math32.Abs(b), 1e-6)
+}
@@ -0,0 +1,183 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// ConstantMedium is a volume of constant density bounded by a closed Hitable,
+// for example smoke or fog inside a box. Rays entering the volume scatter
+// after an exponentially distributed distance using the phase function.
+type ConstantMedium struct {
+	boundary      Hitable
+	negInvDensity float32
+	phase         Material
+}
+
+// NewConstantMedium constructs a new ConstantMedium with density
+// scattering events per unit length and phase function material,
+// usually an Isotropic or HenyeyGreenstein
+func NewConstantMedium(boundary Hitable, density float32, phase Material) *ConstantMedium {
+	return &ConstantMedium{boundary: boundary, negInvDensity: -1 / density, phase: phase}
+}
+
+// Hit implements the Hitable interface for ConstantMedium. The scattering
+// distance is drawn from the random number generator of the path, which
+// must be set in rec with SetRand.
+func (c *ConstantMedium) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	if rec.rng == nil {
+		panic("tracer: ConstantMedium hit with a HitRecord without random number generator, see HitRecord.SetRand")
+	}
+	var rec1, rec2 HitRecord
+	if !c.boundary.Hit(r, -math.MaxFloat32, math.MaxFloat32, &rec1) {
+		return false
+	}
+	if !c.boundary.Hit(r, rec1.t+0.0001, math.MaxFloat32, &rec2) {
+		return false
+	}
+	t1, t2 := max(rec1.t, tMin), min(rec2.t, tMax)
+	if t1 >= t2 {
+		return false
+	}
+	t1 = max(t1, 0)
+	rayLength := math32.Sqrt(r.LenSq())
+	distanceInside := (t2 - t1) * rayLength
+	hitDistance := c.negInvDensity * math32.Log(1-rec.rng.Float32())
+	if hitDistance > distanceInside {
+		return false
+	}
+	rec.t = t1 + hitDistance/rayLength
+	rec.p = r.At(rec.t)
+	// Normal and surface coordinates are meaningless inside a volume
+	rec.normal = geo.UnitX
+	rec.u, rec.v = 0, 0
+	rec.tangent = geo.Vec3{}
+	rec.material = c.phase
+	return true
+}
+
+// BoundingBox implements the Hitable interface for ConstantMedium
+func (c *ConstantMedium) BoundingBox() (bool, geo.Aabb) {
+	return c.boundary.BoundingBox()
+}
+
+// Isotropic is a phase function scattering light equally in all directions
+type Isotropic struct {
+	albedo Texture
+}
+
+// NewIsotropic creates a new Isotropic from r,g,b albedo values
+func NewIsotropic(ar, ag, ab float32) *Isotropic {
+	return &Isotropic{albedo: NewSolidColor(ar, ag, ab)}
+}
+
+// NewIsotropicTexture creates a new Isotropic with an albedo varying in space
+func NewIsotropicTexture(albedo Texture) *Isotropic {
+	return &Isotropic{albedo: albedo}
+}
+
+// Sample implements the Material interface for Isotropic
+func (i *Isotropic) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	return ScatterSample{Dir: randomUnitVector(rng), Weight: i.albedo.Value(h.u, h.v, h.p), Pdf: 1 / (4 * math32.Pi)}, true
+}
+
+// Eval implements the Material interface for Isotropic
+func (i *Isotropic) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	return i.albedo.Value(h.u, h.v, h.p).Mul(1 / (4 * math32.Pi))
+}
+
+// Pdf implements the Material interface for Isotropic
+func (i *Isotropic) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	return 1 / (4 * math32.Pi)
+}
+
+// HenyeyGreenstein is a phase function preferring forward scattering for
+// positive asymmetry g, backward scattering for negative g and scattering
+// isotropically for g = 0
+type HenyeyGreenstein struct {
+	albedo Texture
+	g      float32
+}
+
+// NewHenyeyGreenstein creates a new HenyeyGreenstein phase function,
+// g is clamped to (-1, 1)
+func NewHenyeyGreenstein(albedo Texture, g float32) *HenyeyGreenstein {
+	return &HenyeyGreenstein{albedo: albedo, g: min(max(g, -0.999), 0.999)}
+}
+
+// Sample implements the Material interface for HenyeyGreenstein
+func (hg *HenyeyGreenstein) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	dir := sampleHenyeyGreenstein(wo.Neg(), hg.g, rng)
+	pdf := henyeyGreenstein(-wo.Dot(dir), hg.g)
+	return ScatterSample{Dir: dir, Weight: hg.albedo.Value(h.u, h.v, h.p), Pdf: pdf}, true
+}
+
+// Eval implements the Material interface for HenyeyGreenstein
+func (hg *HenyeyGreenstein) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	return hg.albedo.Value(h.u, h.v, h.p).Mul(hg.Pdf(wo, wi, h))
+}
+
+// Pdf implements the Material interface for HenyeyGreenstein
+func (hg *HenyeyGreenstein) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	return henyeyGreenstein(-wo.Dot(wi), hg.g)
+}
+
+// henyeyGreenstein is the phase function for the angle
+// between the incoming and the scattered direction
+func henyeyGreenstein(cosTheta, g float32) float32 {
+	denom := 1 + g*g - 2*g*cosTheta
+	return (1 - g*g) / (4 * math32.Pi * denom * math32.Sqrt(denom))
+}
+
+func randomUnitVector(rng *rand.Rand) geo.Vec3 {
+	z := 1 - 2*rng.Float32()
+	r := math32.Sqrt(max(0, 1-z*z))
+	phi := 2 * math32.Pi * rng.Float32()
+	return geo.NewVec3(r*math32.Cos(phi), r*math32.Sin(phi), z)
+}
+
+// sampleHenyeyGreenstein samples a new direction around the unit vector dir
+func sampleHenyeyGreenstein(dir geo.Vec3, g float32, rng *rand.Rand) geo.Vec3 {
+	var cosTheta float32
+	u := rng.Float32()
+	if math32.Abs(g) < 1e-3 {
+		cosTheta = 1 - 2*u
+	} else {
+		s := (1 - g*g) / (1 - g + 2*g*u)
+		cosTheta = (1 + g*g - s*s) / (2 * g)
+	}
+	sinTheta := math32.Sqrt(max(0, 1-cosTheta*cosTheta))
+	phi := 2 * math32.Pi * rng.Float32()
+	return geo.NewONB(dir).Local(geo.NewVec3(sinTheta*math32.Cos(phi), sinTheta*math32.Sin(phi), cosTheta))
+}
+
+// Atmosphere is a homogeneous medium filling the whole scene
+type Atmosphere struct {
+	// Density is the number of scattering events per unit length
+	Density float32
+	// Albedo is the fraction of light scattered instead of absorbed
+	Albedo Color
+	// G is the Henyey-Greenstein asymmetry of the phase function
+	G float32
+	// Extent is the distance rays leaving the scene travel through the
+	// atmosphere before reaching the background, zero means the atmosphere
+	// only fills the space between surfaces
+	Extent float32
+}
+
+// sample returns the distance along r to a scattering event if it happens
+// before t, which is measured in units of the ray direction
+func (a *Atmosphere) sample(r *geo.Ray, t float32, rng *rand.Rand) (float32, bool) {
+	if a.Density <= 0 {
+		return 0, false
+	}
+	rayLength := math32.Sqrt(r.LenSq())
+	distance := -math32.Log(1-rng.Float32()) / a.Density
+	if distance >= t*rayLength {
+		return 0, false
+	}
+	return distance / rayLength, true
+}
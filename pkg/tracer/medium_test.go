@@ -0,0 +1,117 @@
+package tracer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+func TestConstantMediumHit(t *testing.T) {
+	// The box is 2 units deep, the ray direction 2 units long
+	const density = 0.5
+	medium := NewConstantMedium(NewBox(geo.NewVec3(-1, -1, -1), geo.NewVec3(1, 1, 1), nil), density, NewIsotropic(1, 1, 1))
+	// The medium sees the random numbers through the hierarchy
+	world := NewBvhNodeFromList(HitableList{medium, NewSphere(geo.NewVec3(10, 0, 0), 1, nil)})
+	r := geo.NewRay(geo.NewVec3(0, 0, 5), geo.NewVec3(0, 0, -2), 0)
+	rng := rand.New(rand.NewSource(1))
+	const n = 20000
+	passed := 0
+	var depth float32
+	for i := 0; i < n; i++ {
+		var rec HitRecord
+		rec.SetRand(rng)
+		if !world.Hit(&r, 0.001, math32.MaxFloat32, &rec) {
+			passed++
+			continue
+		}
+		if z := rec.p.Z(); z < -1 || z > 1 {
+			t.Fatalf("scattered at %v outside the medium", rec.p)
+		}
+		depth += 1 - rec.p.Z()
+	}
+	want := math32.Exp(-density * 2)
+	if got := float32(passed) / n; math32.Abs(got-want) > 0.015 {
+		t.Errorf("%.3f of the rays passed, want %.3f", got, want)
+	}
+	// Mean of the exponential distribution cut off at the far side
+	wantDepth := 1/density - 2*want/(1-want)
+	if got := depth / float32(n-passed); math32.Abs(got-wantDepth) > 0.02 {
+		t.Errorf("mean scattering depth %.3f, want %.3f", got, wantDepth)
+	}
+	// Without a random number generator the medium cannot decide
+	// where to scatter, which must not go unnoticed
+	defer func() {
+		if recover() == nil {
+			t.Error("medium hit without a random number generator did not panic")
+		}
+	}()
+	var rec HitRecord
+	medium.Hit(&r, 0.001, math32.MaxFloat32, &rec)
+}
+
+func TestAtmosphereSample(t *testing.T) {
+	atm := &Atmosphere{Density: 0.25}
+	// t = 2 along a direction 2 units long is 4 units away
+	r := geo.NewRay(geo.Origin, geo.NewVec3(0, 2, 0), 0)
+	rng := rand.New(rand.NewSource(1))
+	const n = 20000
+	scattered := 0
+	for i := 0; i < n; i++ {
+		if ts, ok := atm.sample(&r, 2, rng); ok {
+			if ts < 0 || ts >= 2 {
+				t.Fatalf("scattered at t %g beyond the end", ts)
+			}
+			scattered++
+		}
+	}
+	want := 1 - math32.Exp(-1)
+	if got := float32(scattered) / n; math32.Abs(got-want) > 0.015 {
+		t.Errorf("%.3f of the rays scattered, want %.3f", got, want)
+	}
+	if _, ok := (&Atmosphere{}).sample(&r, 2, rng); ok {
+		t.Error("atmosphere without density scattered")
+	}
+}
+
+// TestMediumFurnace checks that media which scatter all light keep a
+// uniformly lit scene at the brightness of its surroundings
+func TestMediumFurnace(t *testing.T) {
+	const background = 0.5
+	film := NewFilm(8, 4)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			film.Set(x, y, NewColor(background, background, background))
+		}
+	}
+	fog := NewConstantMedium(NewSphere(geo.Origin, 1, nil), 2, NewIsotropic(1, 1, 1))
+	tests := []struct {
+		name  string
+		scene *Scene
+	}{
+		{"medium", &Scene{World: HitableList{fog}, Background: NewConstantEnvironment(NewColor(background, background, background))}},
+		// The sampleable environment is also reached through light sampling
+		{"medium lit by an environment map", &Scene{World: HitableList{fog}, Background: NewEnvironmentMap(film)}},
+		{"atmosphere", &Scene{
+			World:      HitableList{},
+			Background: NewEnvironmentMap(film),
+			Atmosphere: &Atmosphere{Density: 0.5, Albedo: NewColor(1, 1, 1), G: 0.3, Extent: 4},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pt := newPathTracer(tt.scene, &RenderOptions{MaxDepth: 200})
+			rng := rand.New(rand.NewSource(1))
+			const n = 20000
+			var sum float32
+			for i := 0; i < n; i++ {
+				r := geo.NewRay(geo.NewVec3(0, 0, 3), geo.NewVec3(0, 0, -1), 0)
+				sum += pt.colorAt(&r, rng).G()
+			}
+			if got := sum / n; math32.Abs(got-background) > 0.02*background {
+				t.Errorf("got radiance %.4f, want %.4f", got, background)
+			}
+		})
+	}
+}
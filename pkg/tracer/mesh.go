@@ -0,0 +1,197 @@
+package tracer
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// NoIndex marks a missing normal or texture coordinate index in a MeshFace
+const NoIndex = -1
+
+// MeshFace holds the vertex, normal and texture coordinate
+// indices of the three corners of a triangle in a Mesh
+type MeshFace struct {
+	V, N, T [3]int32
+}
+
+// Mesh is a triangle mesh sharing vertex positions, normals and
+// texture coordinates between its faces
+type Mesh struct {
+	vertices []geo.Vec3
+	normals  []geo.Vec3
+	uvs      [][2]float32
+	faces    []MeshFace
+	material Material
+}
+
+// NewMesh constructs a new Mesh without faces. The slices are not copied,
+// so several meshes with different materials can share the same vertex data.
+func NewMesh(vertices, normals []geo.Vec3, uvs [][2]float32, m Material) *Mesh {
+	return &Mesh{vertices: vertices, normals: normals, uvs: uvs, material: m}
+}
+
+// AddFace adds a triangle to m after checking its indices. Normals and
+// texture coordinates must be given for all corners or none.
+func (m *Mesh) AddFace(f MeshFace) error {
+	for i := 0; i < 3; i++ {
+		if f.V[i] < 0 || int(f.V[i]) >= len(m.vertices) {
+			return fmt.Errorf("vertex index %d out of range", f.V[i])
+		}
+	}
+	if err := checkIndices(f.N, len(m.normals), "normal"); err != nil {
+		return err
+	}
+	if err := checkIndices(f.T, len(m.uvs), "texture coordinate"); err != nil {
+		return err
+	}
+	m.faces = append(m.faces, f)
+	return nil
+}
+
+// checkIndices checks that the corner indices into n elements are
+// either all NoIndex or all in range
+func checkIndices(idx [3]int32, n int, what string) error {
+	if idx == [3]int32{NoIndex, NoIndex, NoIndex} {
+		return nil
+	}
+	for _, i := range idx {
+		if i == NoIndex {
+			return fmt.Errorf("%s indices must be given for all corners or none", what)
+		}
+		if i < 0 || int(i) >= n {
+			return fmt.Errorf("%s index %d out of range", what, i)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of triangles in m
+func (m *Mesh) Len() int {
+	return len(m.faces)
+}
+
+// Triangles returns a HitableList with one Triangle per face of m,
+// ready to be put into a bounding volume hierarchy
+func (m *Mesh) Triangles() HitableList {
+	l := make(HitableList, len(m.faces))
+	for i := range m.faces {
+		l[i] = &Triangle{mesh: m, face: int32(i)}
+	}
+	return l
+}
+
+// Triangle is a single face of a Mesh
+type Triangle struct {
+	mesh *Mesh
+	face int32
+}
+
+// NewTriangle constructs a stand-alone triangle from three vertices
+func NewTriangle(v0, v1, v2 geo.Vec3, m Material) *Triangle {
+	mesh := NewMesh([]geo.Vec3{v0, v1, v2}, nil, nil, m)
+	mesh.faces = []MeshFace{{V: [3]int32{0, 1, 2}, N: [3]int32{NoIndex, NoIndex, NoIndex}, T: [3]int32{NoIndex, NoIndex, NoIndex}}}
+	return &Triangle{mesh: mesh}
+}
+
+// Hit implements the Hitable interface for Triangle using
+// the Möller–Trumbore intersection algorithm
+func (tr *Triangle) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	m := tr.mesh
+	f := &m.faces[tr.face]
+	v0 := m.vertices[f.V[0]]
+	edge1 := m.vertices[f.V[1]].Sub(v0)
+	edge2 := m.vertices[f.V[2]].Sub(v0)
+	pvec := r.Dir().Cross(edge2)
+	det := edge1.Dot(pvec)
+	if math32.Abs(det) < 1e-12 {
+		return false
+	}
+	invDet := 1 / det
+	tvec := r.Orig().Sub(v0)
+	b1 := tvec.Dot(pvec) * invDet
+	if b1 < 0 || b1 > 1 {
+		return false
+	}
+	qvec := tvec.Cross(edge1)
+	b2 := r.Dir().Dot(qvec) * invDet
+	if b2 < 0 || b1+b2 > 1 {
+		return false
+	}
+	t := edge2.Dot(qvec) * invDet
+	if t >= tMax || t <= tMin {
+		return false
+	}
+	b0 := 1 - b1 - b2
+	rec.t = t
+	rec.p = r.At(t)
+	if f.N[0] != NoIndex {
+		n := m.normals[f.N[0]].Mul(b0).Add(m.normals[f.N[1]].Mul(b1)).Add(m.normals[f.N[2]].Mul(b2))
+		rec.normal = n.Normed()
+	} else {
+		rec.normal = edge1.Cross(edge2).Normed()
+	}
+	if f.T[0] != NoIndex {
+		uv0, uv1, uv2 := m.uvs[f.T[0]], m.uvs[f.T[1]], m.uvs[f.T[2]]
+		rec.u = b0*uv0[0] + b1*uv1[0] + b2*uv2[0]
+		rec.v = b0*uv0[1] + b1*uv1[1] + b2*uv2[1]
+		// Solve the edges for the derivative of the position by u
+		du1, dv1 := uv1[0]-uv0[0], uv1[1]-uv0[1]
+		du2, dv2 := uv2[0]-uv0[0], uv2[1]-uv0[1]
+		if det := du1*dv2 - dv1*du2; det != 0 {
+			rec.tangent = edge1.Mul(dv2).Sub(edge2.Mul(dv1)).Mul(1 / det)
+		} else {
+			rec.tangent = edge1
+		}
+	} else {
+		rec.u, rec.v = b1, b2
+		rec.tangent = edge1
+	}
+	rec.material = m.material
+	return true
+}
+
+// BoundingBox implements the Hitable interface for Triangle
+func (tr *Triangle) BoundingBox() (bool, geo.Aabb) {
+	m := tr.mesh
+	f := &m.faces[tr.face]
+	v0, v1, v2 := m.vertices[f.V[0]], m.vertices[f.V[1]], m.vertices[f.V[2]]
+	small := geo.NewVec3(min(v0.X(), v1.X(), v2.X()), min(v0.Y(), v1.Y(), v2.Y()), min(v0.Z(), v1.Z(), v2.Z()))
+	big := geo.NewVec3(max(v0.X(), v1.X(), v2.X()), max(v0.Y(), v1.Y(), v2.Y()), max(v0.Z(), v1.Z(), v2.Z()))
+	return true, geo.NewAabb(small, big).Padded(rectPadding)
+}
+
+// PdfValue implements the Sampleable interface for Triangle
+func (tr *Triangle) PdfValue(origin, dir geo.Vec3) float32 {
+	m := tr.mesh
+	f := &m.faces[tr.face]
+	v0 := m.vertices[f.V[0]]
+	n := m.vertices[f.V[1]].Sub(v0).Cross(m.vertices[f.V[2]].Sub(v0))
+	area := n.Len()
+	if area == 0 {
+		return 0
+	}
+	return areaPdf(tr, area/2, n.Mul(1/area), origin, dir)
+}
+
+// Random implements the Sampleable interface for Triangle
+// by choosing a uniformly distributed point on its surface
+func (tr *Triangle) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	m := tr.mesh
+	f := &m.faces[tr.face]
+	su := math32.Sqrt(rng.Float32())
+	b1, b2 := su*(1-rng.Float32()), su*rng.Float32()
+	v0 := m.vertices[f.V[0]]
+	p := v0.Add(m.vertices[f.V[1]].Sub(v0).Mul(b1)).Add(m.vertices[f.V[2]].Sub(v0).Mul(b2))
+	return p.Sub(origin)
+}
+
+// WithMaterial returns a copy of m using material mat
+// which shares all vertex and face data with m
+func (m *Mesh) WithMaterial(mat Material) *Mesh {
+	c := *m
+	c.material = mat
+	return &c
+}
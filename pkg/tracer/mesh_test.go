@@ -0,0 +1,45 @@
+package tracer_test
+
+import (
+	"testing"
+
+	"github.com/robquant/tracer/pkg/geo"
+	"github.com/robquant/tracer/pkg/tracer"
+)
+
+func TestMeshAddFace(t *testing.T) {
+	vertices := []geo.Vec3{geo.NewVec3(0, 0, 0), geo.NewVec3(1, 0, 0), geo.NewVec3(0, 1, 0)}
+	normals := []geo.Vec3{geo.UnitZ}
+	uvs := [][2]float32{{0, 0}, {1, 0}, {0, 1}}
+	none := [3]int32{tracer.NoIndex, tracer.NoIndex, tracer.NoIndex}
+	tests := []struct {
+		name string
+		face tracer.MeshFace
+		ok   bool
+	}{
+		{"positions only", tracer.MeshFace{V: [3]int32{0, 1, 2}, N: none, T: none}, true},
+		{"all attributes", tracer.MeshFace{V: [3]int32{0, 1, 2}, N: [3]int32{0, 0, 0}, T: [3]int32{0, 1, 2}}, true},
+		{"vertex out of range", tracer.MeshFace{V: [3]int32{0, 1, 3}, N: none, T: none}, false},
+		{"negative vertex", tracer.MeshFace{V: [3]int32{-1, 1, 2}, N: none, T: none}, false},
+		{"normal out of range", tracer.MeshFace{V: [3]int32{0, 1, 2}, N: [3]int32{0, 1, 0}, T: none}, false},
+		{"mixed normals", tracer.MeshFace{V: [3]int32{0, 1, 2}, N: [3]int32{0, tracer.NoIndex, 0}, T: none}, false},
+		{"mixed texture coordinates", tracer.MeshFace{V: [3]int32{0, 1, 2}, N: none, T: [3]int32{tracer.NoIndex, 1, 2}}, false},
+		{"negative texture coordinate", tracer.MeshFace{V: [3]int32{0, 1, 2}, N: none, T: [3]int32{0, -2, 2}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := tracer.NewMesh(vertices, normals, uvs, nil)
+			err := m.AddFace(tt.face)
+			if (err == nil) != tt.ok {
+				t.Fatalf("AddFace returned %v, want ok=%v", err, tt.ok)
+			}
+			want := 0
+			if tt.ok {
+				want = 1
+			}
+			if m.Len() != want {
+				t.Errorf("mesh has %d faces, want %d", m.Len(), want)
+			}
+		})
+	}
+}
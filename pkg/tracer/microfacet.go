@@ -0,0 +1,371 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// ggx is the Trowbridge-Reitz distribution of microfacet normals.
+// All directions are given in a local frame with the surface normal along +Z.
+type ggx struct {
+	alphaX, alphaY float32
+}
+
+// newGGX maps the perceptually linear roughness in [0, 1]
+// to the width of the distribution
+func newGGX(roughness float32) ggx {
+	alpha := roughness * roughness
+	return ggx{alpha, alpha}
+}
+
+// smooth reports whether the surface is so smooth that
+// it is better treated as a perfect specular surface
+func (g ggx) smooth() bool {
+	return max(g.alphaX, g.alphaY) < 1e-3
+}
+
+// d is the density of microfacets with normal wm
+func (g ggx) d(wm geo.Vec3) float32 {
+	x, y := wm.X()/g.alphaX, wm.Y()/g.alphaY
+	e := x*x + y*y + wm.Z()*wm.Z()
+	return 1 / (math32.Pi * g.alphaX * g.alphaY * e * e)
+}
+
+// lambda is the auxiliary function of Smith's shadowing term
+func (g ggx) lambda(w geo.Vec3) float32 {
+	z2 := w.Z() * w.Z()
+	if z2 == 0 {
+		return math32.Inf(1)
+	}
+	x, y := g.alphaX*w.X(), g.alphaY*w.Y()
+	return (math32.Sqrt(1+(x*x+y*y)/z2) - 1) / 2
+}
+
+// g1 is the fraction of microfacets visible from w
+func (g ggx) g1(w geo.Vec3) float32 {
+	return 1 / (1 + g.lambda(w))
+}
+
+// g is the fraction of microfacets visible from both wo and wi
+func (g ggx) g(wo, wi geo.Vec3) float32 {
+	return 1 / (1 + g.lambda(wo) + g.lambda(wi))
+}
+
+// visiblePdf is the density of normals wm visible from w,
+// which sampleVisible chooses from. Normals facing away from w,
+// or from -w if it is below the surface, are never chosen.
+func (g ggx) visiblePdf(w, wm geo.Vec3) float32 {
+	cosTheta := math32.Abs(w.Z())
+	if cosTheta == 0 {
+		return 0
+	}
+	cosVisible := w.Dot(wm)
+	if w.Z() < 0 {
+		cosVisible = -cosVisible
+	}
+	return g.g1(w) / cosTheta * g.d(wm) * max(cosVisible, 0)
+}
+
+// sampleVisible samples a microfacet normal visible from w using the
+// method of Heitz, "Sampling the GGX Distribution of Visible Normals"
+func (g ggx) sampleVisible(w geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	// Transform w to the hemisphere configuration
+	wh := geo.NewVec3(g.alphaX*w.X(), g.alphaY*w.Y(), w.Z()).Normed()
+	if wh.Z() < 0 {
+		wh = wh.Neg()
+	}
+	t1 := geo.UnitX
+	if wh.Z() < 0.99999 {
+		t1 = geo.UnitZ.Cross(wh).Normed()
+	}
+	t2 := wh.Cross(t1)
+	// Sample the projected disk and warp it to the visible hemisphere
+	r := math32.Sqrt(rng.Float32())
+	phi := 2 * math32.Pi * rng.Float32()
+	px, py := r*math32.Cos(phi), r*math32.Sin(phi)
+	h := math32.Sqrt(1 - px*px)
+	s := (1 + wh.Z()) / 2
+	py = (1-s)*h + s*py
+	pz := math32.Sqrt(max(0, 1-px*px-py*py))
+	nh := t1.Mul(px).Add(t2.Mul(py)).Add(wh.Mul(pz))
+	return geo.NewVec3(g.alphaX*nh.X(), g.alphaY*nh.Y(), max(1e-6, nh.Z())).Normed()
+}
+
+// reflectLocal mirrors w at the normal n
+func reflectLocal(w, n geo.Vec3) geo.Vec3 {
+	return n.Mul(2 * w.Dot(n)).Sub(w)
+}
+
+// refractLocal refracts w, which points away from the surface, through
+// the interface with normal n and relative index of refraction eta.
+// It returns false on total internal reflection.
+func refractLocal(w, n geo.Vec3, eta float32) (geo.Vec3, bool) {
+	cosThetaI := n.Dot(w)
+	if cosThetaI < 0 {
+		eta = 1 / eta
+		cosThetaI = -cosThetaI
+		n = n.Neg()
+	}
+	sin2ThetaT := max(0, 1-cosThetaI*cosThetaI) / (eta * eta)
+	if sin2ThetaT >= 1 {
+		return geo.Vec3{}, false
+	}
+	cosThetaT := math32.Sqrt(1 - sin2ThetaT)
+	return w.Neg().Mul(1 / eta).Add(n.Mul(cosThetaI/eta - cosThetaT)), true
+}
+
+// fresnelDielectric is the unpolarized Fresnel reflectance of an interface
+// with relative index of refraction eta, cosThetaI is negative for light
+// arriving from the inside
+func fresnelDielectric(cosThetaI, eta float32) float32 {
+	cosThetaI = min(max(cosThetaI, -1), 1)
+	if cosThetaI < 0 {
+		eta = 1 / eta
+		cosThetaI = -cosThetaI
+	}
+	sin2ThetaT := (1 - cosThetaI*cosThetaI) / (eta * eta)
+	if sin2ThetaT >= 1 {
+		return 1
+	}
+	cosThetaT := math32.Sqrt(1 - sin2ThetaT)
+	parallel := (eta*cosThetaI - cosThetaT) / (eta*cosThetaI + cosThetaT)
+	perpendicular := (cosThetaI - eta*cosThetaT) / (cosThetaI + eta*cosThetaT)
+	return (parallel*parallel + perpendicular*perpendicular) / 2
+}
+
+// fresnelConductor is the Fresnel reflectance of a conductor with complex
+// index of refraction eta + ik for a single color channel
+func fresnelConductor(cosThetaI, eta, k float32) float32 {
+	cos2 := min(cosThetaI*cosThetaI, 1)
+	sin2 := 1 - cos2
+	t0 := eta*eta - k*k - sin2
+	a2plusb2 := math32.Sqrt(t0*t0 + 4*eta*eta*k*k)
+	t1 := a2plusb2 + cos2
+	a := math32.Sqrt(max(0, (a2plusb2+t0)/2))
+	t2 := 2 * cosThetaI * a
+	rs := (t1 - t2) / (t1 + t2)
+	t3 := cos2*a2plusb2 + sin2*sin2
+	t4 := t2 * sin2
+	rp := rs * (t3 - t4) / (t3 + t4)
+	return (rp + rs) / 2
+}
+
+// ComplexIOR is the complex index of refraction Eta + iK of a conductor
+// for each color channel
+type ComplexIOR struct {
+	Eta, K Color
+}
+
+// Measured indices of refraction of common metals
+var (
+	IORGold      = ComplexIOR{Eta: NewColor(0.143, 0.375, 1.442), K: NewColor(3.983, 2.386, 1.603)}
+	IORCopper    = ComplexIOR{Eta: NewColor(0.200, 0.924, 1.102), K: NewColor(3.913, 2.453, 2.142)}
+	IORAluminium = ComplexIOR{Eta: NewColor(1.657, 0.880, 0.521), K: NewColor(9.224, 6.270, 4.837)}
+)
+
+// fresnel returns the reflectance of each color channel
+func (c ComplexIOR) fresnel(cosThetaI float32) Color {
+	return NewColor(
+		fresnelConductor(cosThetaI, c.Eta.R(), c.K.R()),
+		fresnelConductor(cosThetaI, c.Eta.G(), c.K.G()),
+		fresnelConductor(cosThetaI, c.Eta.B(), c.K.B()),
+	)
+}
+
+// Conductor is a rough metal whose color follows from its complex index
+// of refraction, modelled by GGX microfacets. A roughness of zero gives
+// a perfect mirror.
+type Conductor struct {
+	ior          ComplexIOR
+	distribution ggx
+}
+
+// NewConductor constructs a new Conductor from a complex
+// index of refraction and a roughness between 0 and 1
+func NewConductor(ior ComplexIOR, roughness float32) *Conductor {
+	return &Conductor{ior: ior, distribution: newGGX(min(max(roughness, 0), 1))}
+}
+
+// Sample implements the Material interface for Conductor
+// by sampling the microfacet normals visible from wo
+func (c *Conductor) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	onb := geo.NewONB(faceForward(h.Normal(), wo.Neg()))
+	woL := onb.ToLocal(wo)
+	if woL.Z() <= 0 {
+		return ScatterSample{}, false
+	}
+	if c.distribution.smooth() {
+		wi := geo.NewVec3(-woL.X(), -woL.Y(), woL.Z())
+		return ScatterSample{Dir: onb.Local(wi), Weight: c.ior.fresnel(woL.Z()), Specular: true}, true
+	}
+	wm := c.distribution.sampleVisible(woL, rng)
+	wiL := reflectLocal(woL, wm)
+	if wiL.Z() <= 0 {
+		return ScatterSample{}, false
+	}
+	// f cos / pdf simplifies to F G / G1
+	weight := c.ior.fresnel(woL.Dot(wm)).Mul(c.distribution.g(woL, wiL) / c.distribution.g1(woL))
+	pdf := c.distribution.visiblePdf(woL, wm) / (4 * woL.Dot(wm))
+	return ScatterSample{Dir: onb.Local(wiL), Weight: weight, Pdf: pdf}, true
+}
+
+// localFrame transforms wo and wi to the shading frame of a one sided surface
+// and reports whether both are above it
+func localFrame(wo, wi geo.Vec3, h *HitRecord) (geo.Vec3, geo.Vec3, bool) {
+	onb := geo.NewONB(faceForward(h.Normal(), wo.Neg()))
+	woL, wiL := onb.ToLocal(wo), onb.ToLocal(wi)
+	return woL, wiL, woL.Z() > 0 && wiL.Z() > 0
+}
+
+// Eval implements the Material interface for Conductor
+func (c *Conductor) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	woL, wiL, ok := localFrame(wo, wi, h)
+	if !ok || c.distribution.smooth() {
+		return Black
+	}
+	wm := woL.Add(wiL).Normed()
+	f := c.distribution.d(wm) * c.distribution.g(woL, wiL) / (4 * woL.Z())
+	return c.ior.fresnel(woL.Dot(wm)).Mul(f)
+}
+
+// Pdf implements the Material interface for Conductor
+func (c *Conductor) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	woL, wiL, ok := localFrame(wo, wi, h)
+	if !ok || c.distribution.smooth() {
+		return 0
+	}
+	wm := woL.Add(wiL).Normed()
+	return c.distribution.visiblePdf(woL, wm) / (4 * woL.Dot(wm))
+}
+
+// RoughDielectric is a dielectric like frosted glass whose surface is made
+// of GGX microfacets, which both reflect and refract. A roughness of zero
+// gives a smooth surface like Dielectric.
+type RoughDielectric struct {
+	refIdx       float32
+	distribution ggx
+	absorption
+}
+
+// NewRoughDielectric constructs a new RoughDielectric with refractive
+// index refIdx and a roughness between 0 and 1
+func NewRoughDielectric(refIdx, roughness float32) *RoughDielectric {
+	return &RoughDielectric{refIdx: refIdx, distribution: newGGX(min(max(roughness, 0), 1))}
+}
+
+// Sample implements the Material interface for RoughDielectric, choosing
+// between reflection and refraction by the Fresnel reflectance of the
+// sampled microfacet
+func (d *RoughDielectric) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	// The frame keeps the outward normal so that the sign of
+	// woL.Z() tells from which side the surface was hit
+	onb := geo.NewONB(h.Normal())
+	woL := onb.ToLocal(wo)
+	if woL.Z() == 0 {
+		return ScatterSample{}, false
+	}
+	if d.distribution.smooth() {
+		r := fresnelDielectric(woL.Z(), d.refIdx)
+		if rng.Float32() < r {
+			wi := geo.NewVec3(-woL.X(), -woL.Y(), woL.Z())
+			return ScatterSample{Dir: onb.Local(wi), Weight: NewColor(1, 1, 1), Specular: true}, true
+		}
+		wi, ok := refractLocal(woL, geo.UnitZ, d.refIdx)
+		if !ok {
+			return ScatterSample{}, false
+		}
+		etap := d.relativeEta(woL, wi)
+		return ScatterSample{Dir: onb.Local(wi), Weight: NewColor(1, 1, 1).Mul(1 / (etap * etap)), Specular: true}, true
+	}
+	wi, ok := d.sampleLocal(woL, rng)
+	if !ok {
+		return ScatterSample{}, false
+	}
+	f, pdf := d.evalLocal(woL, wi)
+	if pdf == 0 {
+		return ScatterSample{}, false
+	}
+	return ScatterSample{Dir: onb.Local(wi), Weight: f.Mul(1 / pdf), Pdf: pdf}, true
+}
+
+// sampleLocal chooses a direction for a rough surface in the
+// local frame of the outward normal, whose pdf is given by evalLocal
+func (d *RoughDielectric) sampleLocal(wo geo.Vec3, rng *rand.Rand) (geo.Vec3, bool) {
+	wm := d.distribution.sampleVisible(wo, rng)
+	r := fresnelDielectric(wo.Dot(wm), d.refIdx)
+	if rng.Float32() < r {
+		wi := reflectLocal(wo, wm)
+		return wi, wi.Z()*wo.Z() > 0
+	}
+	wi, ok := refractLocal(wo, wm, d.refIdx)
+	return wi, ok && wi.Z()*wo.Z() < 0
+}
+
+// relativeEta is the ratio of the refractive indices on the sides of wi and wo
+func (d *RoughDielectric) relativeEta(wo, wi geo.Vec3) float32 {
+	if wo.Z()*wi.Z() > 0 {
+		return 1
+	}
+	if wo.Z() > 0 {
+		return d.refIdx
+	}
+	return 1 / d.refIdx
+}
+
+// evalLocal returns f cos and the pdf of Sample choosing wi
+// for directions in the local frame of the outward normal
+func (d *RoughDielectric) evalLocal(wo, wi geo.Vec3) (Color, float32) {
+	cosThetaO, cosThetaI := wo.Z(), wi.Z()
+	if cosThetaO == 0 || cosThetaI == 0 {
+		return Black, 0
+	}
+	etap := d.relativeEta(wo, wi)
+	// The generalized half vector is the microfacet normal of both cases
+	wm := wi.Mul(etap).Add(wo)
+	if wm.LenSq() == 0 {
+		return Black, 0
+	}
+	wm = wm.Normed()
+	if wm.Z() < 0 {
+		wm = wm.Neg()
+	}
+	// Microfacets facing away from either direction contribute nothing
+	if wm.Dot(wi)*cosThetaI < 0 || wm.Dot(wo)*cosThetaO < 0 {
+		return Black, 0
+	}
+	dist := d.distribution
+	r := fresnelDielectric(wo.Dot(wm), d.refIdx)
+	if etap == 1 {
+		f := dist.d(wm) * dist.g(wo, wi) * r / (4 * math32.Abs(cosThetaO))
+		pdf := dist.visiblePdf(wo, wm) / (4 * math32.Abs(wo.Dot(wm))) * r
+		return NewColor(f, f, f), pdf
+	}
+	denom := wi.Dot(wm) + wo.Dot(wm)/etap
+	denom *= denom
+	// Radiance is compressed into the smaller solid angle inside the denser medium
+	f := dist.d(wm) * (1 - r) * dist.g(wo, wi) * math32.Abs(wi.Dot(wm)*wo.Dot(wm)/(cosThetaO*denom)) / (etap * etap)
+	pdf := dist.visiblePdf(wo, wm) * math32.Abs(wi.Dot(wm)) / denom * (1 - r)
+	return NewColor(f, f, f), pdf
+}
+
+// Eval implements the Material interface for RoughDielectric
+func (d *RoughDielectric) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	if d.distribution.smooth() {
+		return Black
+	}
+	onb := geo.NewONB(h.Normal())
+	f, _ := d.evalLocal(onb.ToLocal(wo), onb.ToLocal(wi))
+	return f
+}
+
+// Pdf implements the Material interface for RoughDielectric
+func (d *RoughDielectric) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	if d.distribution.smooth() {
+		return 0
+	}
+	onb := geo.NewONB(h.Normal())
+	_, pdf := d.evalLocal(onb.ToLocal(wo), onb.ToLocal(wi))
+	return pdf
+}
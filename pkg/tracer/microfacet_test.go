@@ -0,0 +1,163 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// integrateHemisphere integrates f over the upper hemisphere with the
+// midpoint rule in cos theta and phi, which have uniform solid angle
+func integrateHemisphere(f func(w geo.Vec3) float32) float32 {
+	const nz, nphi = 2000, 400
+	var sum float64
+	for i := 0; i < nz; i++ {
+		z := (float32(i) + 0.5) / nz
+		r := math32.Sqrt(1 - z*z)
+		for j := 0; j < nphi; j++ {
+			sin, cos := math32.Sincos((float32(j) + 0.5) * 2 * math32.Pi / nphi)
+			sum += float64(f(geo.NewVec3(r*cos, r*sin, z)))
+		}
+	}
+	return float32(sum * 2 * math.Pi / (nz * nphi))
+}
+
+// TestGGXNormalization checks that the projected areas of the microfacets
+// add up to the area of the surface, seen from above and from wo
+func TestGGXNormalization(t *testing.T) {
+	wo := geo.NewVec3(0.6, -0.3, 0.5).Normed()
+	for _, g := range []ggx{{0.5, 0.5}, {0.3, 0.6}, {0.8, 0.8}} {
+		projected := integrateHemisphere(func(wm geo.Vec3) float32 {
+			return g.d(wm) * wm.Z()
+		})
+		if !near(projected, 1, 0.01) {
+			t.Errorf("alpha %g, %g: microfacets project to %g, want 1", g.alphaX, g.alphaY, projected)
+		}
+		visible := integrateHemisphere(func(wm geo.Vec3) float32 {
+			return g.g1(wo) * max(wo.Dot(wm), 0) * g.d(wm)
+		})
+		if !near(visible, wo.Z(), 0.01) {
+			t.Errorf("alpha %g, %g: visible microfacets project to %g, want %g", g.alphaX, g.alphaY, visible, wo.Z())
+		}
+		for _, w := range []geo.Vec3{wo, wo.Neg()} {
+			pdf := integrateHemisphere(func(wm geo.Vec3) float32 {
+				return g.visiblePdf(w, wm)
+			})
+			if !near(pdf, 1, 0.01) {
+				t.Errorf("alpha %g, %g: visiblePdf from %v integrates to %g, want 1", g.alphaX, g.alphaY, w, pdf)
+			}
+		}
+	}
+}
+
+func TestFresnel(t *testing.T) {
+	// At normal incidence a conductor reflects ((n-1)^2+k^2) / ((n+1)^2+k^2)
+	for _, ior := range []struct{ eta, k float32 }{{0.143, 3.983}, {1.657, 9.224}, {1.5, 0}} {
+		want := ((ior.eta-1)*(ior.eta-1) + ior.k*ior.k) / ((ior.eta+1)*(ior.eta+1) + ior.k*ior.k)
+		if got := fresnelConductor(1, ior.eta, ior.k); !near(got, want, 1e-4) {
+			t.Errorf("fresnelConductor(1, %g, %g) = %g, want %g", ior.eta, ior.k, got, want)
+		}
+	}
+	// Without absorption a conductor is a dielectric
+	for _, cos := range []float32{1, 0.8, 0.5, 0.2, 0.05} {
+		if c, d := fresnelConductor(cos, 1.5, 0), fresnelDielectric(cos, 1.5); !near(c, d, 1e-3) {
+			t.Errorf("at cos %g: fresnelConductor gives %g and fresnelDielectric %g", cos, c, d)
+		}
+	}
+	// At Brewster's angle no light polarized parallel to the plane of
+	// incidence is reflected, leaving half of the perpendicular reflectance
+	const eta = 1.5
+	cosB := math32.Cos(math32.Atan(eta))
+	cosT := math32.Sqrt(1 - (1-cosB*cosB)/(eta*eta))
+	rs := (cosB - eta*cosT) / (cosB + eta*cosT)
+	if got, want := fresnelDielectric(cosB, eta), rs*rs/2; !near(got, want, 1e-4) {
+		t.Errorf("fresnelDielectric at Brewster's angle = %g, want %g", got, want)
+	}
+	// Beyond the critical angle of sin 1/eta light inside is reflected totally
+	cosC := math32.Sqrt(1 - 1/(eta*eta))
+	if got := fresnelDielectric(-cosC*0.99, eta); got != 1 {
+		t.Errorf("fresnelDielectric beyond the critical angle = %g, want 1", got)
+	}
+	if got, want := fresnelDielectric(-1, eta), float32(0.04); !near(got, want, 1e-4) {
+		t.Errorf("fresnelDielectric(-1, %g) = %g, want %g", eta, got, want)
+	}
+}
+
+// albedo is the mean weight of the samples of m, the fraction of light
+// from wo it scatters. Transmitted samples are scaled back by etap^2,
+// which turns the radiance they carry into energy.
+func albedo(m Material, wo geo.Vec3, refIdx float32) float32 {
+	rec := HitRecord{normal: geo.UnitZ, material: m}
+	rng := rand.New(rand.NewSource(1))
+	const n = 100000
+	var sum float32
+	for i := 0; i < n; i++ {
+		s, ok := m.Sample(wo, &rec, rng)
+		if !ok {
+			continue
+		}
+		w := s.Weight.G()
+		if s.Dir.Z()*wo.Z() < 0 {
+			etap := refIdx
+			if wo.Z() < 0 {
+				etap = 1 / refIdx
+			}
+			w *= etap * etap
+		}
+		sum += w
+	}
+	return sum / n
+}
+
+// TestMicrofacetEnergy is a white furnace test: single scattering off a
+// perfect reflector loses a little energy at grazing angles and high
+// roughness but never gains any, and smooth surfaces lose none
+func TestMicrofacetEnergy(t *testing.T) {
+	mirror := ComplexIOR{Eta: NewColor(1, 1, 1), K: NewColor(1e4, 1e4, 1e4)}
+	tests := []struct {
+		name     string
+		material Material
+		refIdx   float32
+		wo       geo.Vec3
+		min      float32
+	}{
+		{"smooth conductor", NewConductor(mirror, 0), 1, geo.NewVec3(0.3, 0, 0.9).Normed(), 0.999},
+		{"glossy conductor", NewConductor(mirror, 0.2), 1, geo.NewVec3(0.3, 0, 0.9).Normed(), 0.99},
+		{"rough conductor", NewConductor(mirror, 0.8), 1, geo.NewVec3(0.3, 0, 0.9).Normed(), 0.5},
+		{"grazing rough conductor", NewConductor(mirror, 0.8), 1, geo.NewVec3(0.95, 0, 0.1).Normed(), 0.5},
+		{"smooth dielectric", NewRoughDielectric(1.5, 0), 1.5, geo.NewVec3(0.3, 0, 0.9).Normed(), 0.99},
+		{"glossy dielectric", NewRoughDielectric(1.5, 0.2), 1.5, geo.NewVec3(0.3, 0, 0.9).Normed(), 0.97},
+		{"rough dielectric", NewRoughDielectric(1.5, 0.8), 1.5, geo.NewVec3(0.3, 0, 0.9).Normed(), 0.8},
+		{"rough dielectric from inside", NewRoughDielectric(1.5, 0.5), 1.5, geo.NewVec3(0.3, 0, -0.9).Normed(), 0.8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := albedo(tt.material, tt.wo, tt.refIdx)
+			if a > 1.01 || a < tt.min {
+				t.Errorf("albedo %g, want in [%g, 1]", a, tt.min)
+			}
+		})
+	}
+}
+
+// TestConductorAlbedo compares the albedo of a perfectly reflecting
+// conductor of roughness 1 seen from straight above with its closed form.
+// The microfacet normals are then uniformly distributed with D = 1/pi and
+// G = 2 cos / (1 + cos), so f cos integrates to 1 - ln 2.
+func TestConductorAlbedo(t *testing.T) {
+	c := NewConductor(ComplexIOR{Eta: NewColor(1, 1, 1), K: NewColor(1e4, 1e4, 1e4)}, 1)
+	rec := HitRecord{normal: geo.UnitZ}
+	want := float32(1 - math.Ln2)
+	integral := integrateHemisphere(func(wi geo.Vec3) float32 {
+		return c.Eval(geo.UnitZ, wi, &rec).G()
+	})
+	if !near(integral, want, 0.01) {
+		t.Errorf("Eval integrates to %g, want %g", integral, want)
+	}
+	if got := albedo(c, geo.UnitZ, 1); !near(got, want, 0.01) {
+		t.Errorf("samples have a mean weight of %g, want %g", got, want)
+	}
+}
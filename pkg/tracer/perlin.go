@@ -0,0 +1,116 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+const perlinPointCount = 256
+
+// perlin generates gradient noise from random unit vectors on a lattice
+type perlin struct {
+	randVec      [perlinPointCount]geo.Vec3
+	permX, permY [perlinPointCount]int
+	permZ        [perlinPointCount]int
+}
+
+func newPerlin(rng *rand.Rand) *perlin {
+	p := &perlin{}
+	for i := range p.randVec {
+		p.randVec[i] = RandomInUnitSphere(rng).Normed()
+	}
+	for _, perm := range []*[perlinPointCount]int{&p.permX, &p.permY, &p.permZ} {
+		for i := range perm {
+			perm[i] = i
+		}
+		rng.Shuffle(perlinPointCount, func(i, j int) { perm[i], perm[j] = perm[j], perm[i] })
+	}
+	return p
+}
+
+// noise returns smoothly varying noise in [-1, 1]
+func (pn *perlin) noise(p geo.Vec3) float32 {
+	fx, fy, fz := math32.Floor(p.X()), math32.Floor(p.Y()), math32.Floor(p.Z())
+	u, v, w := p.X()-fx, p.Y()-fy, p.Z()-fz
+	i, j, k := int(fx), int(fy), int(fz)
+
+	var c [2][2][2]geo.Vec3
+	for di := 0; di < 2; di++ {
+		for dj := 0; dj < 2; dj++ {
+			for dk := 0; dk < 2; dk++ {
+				c[di][dj][dk] = pn.randVec[pn.permX[(i+di)&255]^pn.permY[(j+dj)&255]^pn.permZ[(k+dk)&255]]
+			}
+		}
+	}
+
+	// Hermite smoothing of the interpolation weights
+	uu := u * u * (3 - 2*u)
+	vv := v * v * (3 - 2*v)
+	ww := w * w * (3 - 2*w)
+	var accum float32
+	for di := 0; di < 2; di++ {
+		for dj := 0; dj < 2; dj++ {
+			for dk := 0; dk < 2; dk++ {
+				fi, fj, fk := float32(di), float32(dj), float32(dk)
+				weight := geo.NewVec3(u-fi, v-fj, w-fk)
+				accum += (fi*uu + (1-fi)*(1-uu)) *
+					(fj*vv + (1-fj)*(1-vv)) *
+					(fk*ww + (1-fk)*(1-ww)) * c[di][dj][dk].Dot(weight)
+			}
+		}
+	}
+	return accum
+}
+
+// turbulence sums depth octaves of noise with increasing frequency
+func (pn *perlin) turbulence(p geo.Vec3, depth int) float32 {
+	var accum float32
+	weight := float32(1)
+	for i := 0; i < depth; i++ {
+		accum += weight * pn.noise(p)
+		weight *= 0.5
+		p = p.Mul(2)
+	}
+	return math32.Abs(accum)
+}
+
+// NoiseKind selects the pattern of a NoiseTexture
+type NoiseKind uint8
+
+const (
+	// NoisePerlin is plain Perlin noise
+	NoisePerlin NoiseKind = iota
+	// NoiseTurbulence sums several octaves of noise
+	NoiseTurbulence
+	// NoiseMarble uses turbulence to distort stripes along the z axis
+	NoiseMarble
+)
+
+// NoiseTexture is a grayscale solid texture based on Perlin noise
+type NoiseTexture struct {
+	noise *perlin
+	kind  NoiseKind
+	scale float32
+}
+
+// NewNoiseTexture creates a new NoiseTexture with the given frequency scale.
+// The noise lattice is generated from seed, so equal seeds give equal textures.
+func NewNoiseTexture(kind NoiseKind, scale float32, seed int64) *NoiseTexture {
+	return &NoiseTexture{noise: newPerlin(NewRand(seed)), kind: kind, scale: scale}
+}
+
+// Value implements the Texture interface for NoiseTexture
+func (n *NoiseTexture) Value(u, v float32, p geo.Vec3) Color {
+	var gray float32
+	switch n.kind {
+	case NoiseTurbulence:
+		gray = n.noise.turbulence(p.Mul(n.scale), 7)
+	case NoiseMarble:
+		gray = 0.5 * (1 + math32.Sin(n.scale*p.Z()+10*n.noise.turbulence(p, 7)))
+	default:
+		gray = 0.5 * (1 + n.noise.noise(p.Mul(n.scale)))
+	}
+	return NewColor(gray, gray, gray)
+}
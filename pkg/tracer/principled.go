@@ -0,0 +1,309 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// PrincipledParams are the parameters of the Principled material.
+// Apart from BaseColor and IOR all of them range from 0 to 1.
+type PrincipledParams struct {
+	// BaseColor is the diffuse albedo of dielectrics, the specular
+	// color of metals and the tint of transmitted light
+	BaseColor Texture
+	// Metallic blends between a dielectric and a metal
+	Metallic float32
+	// Roughness widens the specular highlights of all but the clearcoat
+	Roughness float32
+	// Specular scales the reflectance of dielectrics at normal incidence,
+	// 0.5 corresponds to the 4% of most plastics
+	Specular float32
+	// SpecularTint tints the dielectric reflection towards the base color
+	SpecularTint float32
+	// Sheen adds a soft retro-reflective layer like the fuzz on cloth
+	Sheen float32
+	// SheenTint tints the sheen towards the base color
+	SheenTint float32
+	// Clearcoat adds a second, colorless specular layer like car paint
+	Clearcoat float32
+	// ClearcoatGloss narrows the highlights of the clearcoat
+	ClearcoatGloss float32
+	// Transmission blends between an opaque and a transparent dielectric
+	Transmission float32
+	// Anisotropic stretches the highlights along the direction in which
+	// the u surface coordinate increases
+	Anisotropic float32
+	// IOR is the index of refraction of the transmitting part
+	IOR float32
+}
+
+// DefaultPrincipledParams returns the parameters of a grey plastic
+func DefaultPrincipledParams() PrincipledParams {
+	return PrincipledParams{
+		BaseColor:      NewSolidColor(0.8, 0.8, 0.8),
+		Roughness:      0.5,
+		Specular:       0.5,
+		SheenTint:      0.5,
+		ClearcoatGloss: 1,
+		IOR:            1.5,
+	}
+}
+
+// Principled is an uber-material following the Disney principled BSDF
+// with a diffuse, a specular, a clearcoat and a transmission lobe.
+// Directions are sampled from one lobe chosen at random and weighted
+// by the density of all lobes.
+type Principled struct {
+	p                PrincipledParams
+	distribution     ggx
+	clearcoat        float32
+	dielectric       RoughDielectric
+	diffuseWeight    float32
+	transmitWeight   float32
+	clearcoatOpacity float32
+}
+
+// NewPrincipled constructs a new Principled material,
+// parameters outside of their range are clamped
+func NewPrincipled(p PrincipledParams) *Principled {
+	clamp := func(v *float32) { *v = min(max(*v, 0), 1) }
+	for _, v := range []*float32{&p.Metallic, &p.Roughness, &p.Specular, &p.SpecularTint, &p.Sheen,
+		&p.SheenTint, &p.Clearcoat, &p.ClearcoatGloss, &p.Transmission, &p.Anisotropic} {
+		clamp(v)
+	}
+	if p.BaseColor == nil {
+		p.BaseColor = NewSolidColor(0.8, 0.8, 0.8)
+	}
+	if p.IOR <= 0 {
+		p.IOR = 1.5
+	}
+	aspect := math32.Sqrt(1 - 0.9*p.Anisotropic)
+	alpha := p.Roughness * p.Roughness
+	distribution := ggx{max(1e-3, alpha/aspect), max(1e-3, alpha*aspect)}
+	return &Principled{
+		p:                p,
+		distribution:     distribution,
+		clearcoat:        lerp(0.1, 0.001, p.ClearcoatGloss),
+		dielectric:       RoughDielectric{refIdx: p.IOR, distribution: distribution},
+		diffuseWeight:    (1 - p.Metallic) * (1 - p.Transmission),
+		transmitWeight:   (1 - p.Metallic) * p.Transmission,
+		clearcoatOpacity: 0.25 * p.Clearcoat,
+	}
+}
+
+func lerp(a, b, t float32) float32 {
+	return a + (b-a)*t
+}
+
+func lerpColor(a, b Color, t float32) Color {
+	return a.Mul(1 - t).Add(b.Mul(t))
+}
+
+// schlickWeight is the Fresnel factor of Schlick's approximation
+func schlickWeight(cosine float32) float32 {
+	x := min(max(1-cosine, 0), 1)
+	x2 := x * x
+	return x2 * x2 * x
+}
+
+// tint returns c normalized to luminance one
+func tint(c Color) Color {
+	lum := luminance(c)
+	if lum <= 0 {
+		return NewColor(1, 1, 1)
+	}
+	return c.Mul(1 / lum)
+}
+
+// frame returns the shading frame, opaque materials are two sided
+// while transparent ones need to know from which side they are hit.
+// Anisotropic highlights are stretched along the tangent of the surface.
+func (m *Principled) frame(wo geo.Vec3, h *HitRecord) geo.ONB {
+	n := h.Normal()
+	if m.transmitWeight == 0 {
+		n = faceForward(n, wo.Neg())
+	}
+	if m.p.Anisotropic > 0 {
+		return geo.NewONBFromTangent(n, h.tangent)
+	}
+	return geo.NewONB(n)
+}
+
+// lobes returns the probabilities of sampling the diffuse,
+// specular, clearcoat and transmission lobe
+func (m *Principled) lobes(wo geo.Vec3) (float32, float32, float32, float32) {
+	if wo.Z() <= 0 {
+		// Only light refracted into the material arrives from inside
+		if m.transmitWeight > 0 {
+			return 0, 0, 0, 1
+		}
+		return 0, 0, 0, 0
+	}
+	diffuse, specular := m.diffuseWeight, 1-m.transmitWeight
+	sum := diffuse + specular + m.clearcoatOpacity + m.transmitWeight
+	return diffuse / sum, specular / sum, m.clearcoatOpacity / sum, m.transmitWeight / sum
+}
+
+// evalLocal returns f cos and the pdf of Sample choosing wi
+// in the local shading frame
+func (m *Principled) evalLocal(wo, wi geo.Vec3, base Color) (Color, float32) {
+	pDiffuse, pSpecular, pClearcoat, pTransmit := m.lobes(wo)
+	f := Black
+	var pdf float32
+	if wo.Z() > 0 && wi.Z() > 0 {
+		cosL, cosV := wi.Z(), wo.Z()
+		wm := wo.Add(wi).Normed()
+		cosD := wi.Dot(wm)
+		fd := schlickWeight(cosD)
+		if m.diffuseWeight > 0 {
+			// Burley's diffuse with retro-reflection at grazing angles
+			fd90 := 0.5 + 2*m.p.Roughness*cosD*cosD
+			fl, fv := schlickWeight(cosL), schlickWeight(cosV)
+			retro := (1 + (fd90-1)*fl) * (1 + (fd90-1)*fv)
+			diffuse := base.Mul(retro / math32.Pi * m.diffuseWeight)
+			sheen := lerpColor(NewColor(1, 1, 1), tint(base), m.p.SheenTint).Mul(m.p.Sheen * fd * (1 - m.p.Metallic))
+			f = f.Add(diffuse.Add(sheen).Mul(cosL))
+			pdf += pDiffuse * cosL / math32.Pi
+		}
+		dist := m.distribution
+		specTint := lerpColor(NewColor(1, 1, 1), tint(base), m.p.SpecularTint)
+		f0 := lerpColor(specTint.Mul(0.08*m.p.Specular), base, m.p.Metallic)
+		fresnel := lerpColor(f0, NewColor(1, 1, 1), fd)
+		specular := dist.d(wm) * dist.g(wo, wi) / (4 * cosV) * (1 - m.transmitWeight)
+		f = f.Add(fresnel.Mul(specular))
+		pdf += pSpecular * dist.visiblePdf(wo, wm) / (4 * wo.Dot(wm))
+		if m.clearcoatOpacity > 0 {
+			coat := ggx{0.25, 0.25}
+			fc := lerp(0.04, 1, fd)
+			d := gtr1(wm.Z(), m.clearcoat)
+			f = f.Add(NewColor(1, 1, 1).Mul(m.clearcoatOpacity * d * fc * coat.g(wo, wi) / (4 * cosV)))
+			pdf += pClearcoat * d * wm.Z() / (4 * wo.Dot(wm))
+		}
+	}
+	if pTransmit > 0 {
+		ft, pdfT := m.dielectric.evalLocal(wo, wi)
+		if wo.Z()*wi.Z() < 0 {
+			// Light passes the surface twice, entering and leaving
+			ft = ft.MulVec(geo.NewVec3(math32.Sqrt(base.R()), math32.Sqrt(base.G()), math32.Sqrt(base.B())))
+		}
+		f = f.Add(ft.Mul(m.transmitWeight))
+		pdf += pTransmit * pdfT
+	}
+	return f, pdf
+}
+
+// gtr1 is the Generalized-Trowbridge-Reitz distribution with exponent
+// one used for the clearcoat
+func gtr1(cosTheta, alpha float32) float32 {
+	a2 := alpha * alpha
+	t := 1 + (a2-1)*cosTheta*cosTheta
+	return (a2 - 1) / (math32.Pi * math32.Log(a2) * t)
+}
+
+// sampleGTR1 samples a microfacet normal from gtr1
+func sampleGTR1(alpha float32, rng *rand.Rand) geo.Vec3 {
+	a2 := alpha * alpha
+	cosTheta := math32.Sqrt(max(0, (1-math32.Pow(a2, 1-rng.Float32()))/(1-a2)))
+	sinTheta := math32.Sqrt(max(0, 1-cosTheta*cosTheta))
+	phi := 2 * math32.Pi * rng.Float32()
+	return geo.NewVec3(sinTheta*math32.Cos(phi), sinTheta*math32.Sin(phi), cosTheta)
+}
+
+// Sample implements the Material interface for Principled
+func (m *Principled) Sample(wo geo.Vec3, h *HitRecord, rng *rand.Rand) (ScatterSample, bool) {
+	onb := m.frame(wo, h)
+	woL := onb.ToLocal(wo)
+	pDiffuse, pSpecular, pClearcoat, pTransmit := m.lobes(woL)
+	if pDiffuse+pSpecular+pClearcoat+pTransmit == 0 {
+		return ScatterSample{}, false
+	}
+	var wi geo.Vec3
+	switch u := rng.Float32(); {
+	case u < pDiffuse:
+		wi = randomCosineDirection(rng)
+	case u < pDiffuse+pSpecular:
+		wi = reflectLocal(woL, m.distribution.sampleVisible(woL, rng))
+	case u < pDiffuse+pSpecular+pClearcoat:
+		wi = reflectLocal(woL, sampleGTR1(m.clearcoat, rng))
+	default:
+		var ok bool
+		if wi, ok = m.dielectric.sampleLocal(woL, rng); !ok {
+			return ScatterSample{}, false
+		}
+	}
+	f, pdf := m.evalLocal(woL, wi, m.p.BaseColor.Value(h.u, h.v, h.p))
+	if pdf <= 0 {
+		return ScatterSample{}, false
+	}
+	return ScatterSample{Dir: onb.Local(wi), Weight: f.Mul(1 / pdf), Pdf: pdf}, true
+}
+
+// Eval implements the Material interface for Principled
+func (m *Principled) Eval(wo, wi geo.Vec3, h *HitRecord) Color {
+	onb := m.frame(wo, h)
+	f, _ := m.evalLocal(onb.ToLocal(wo), onb.ToLocal(wi), m.p.BaseColor.Value(h.u, h.v, h.p))
+	return f
+}
+
+// Pdf implements the Material interface for Principled
+func (m *Principled) Pdf(wo, wi geo.Vec3, h *HitRecord) float32 {
+	onb := m.frame(wo, h)
+	_, pdf := m.evalLocal(onb.ToLocal(wo), onb.ToLocal(wi), Black)
+	return pdf
+}
+
+// GLTFMetallicRoughness holds the parameters of a glTF 2.0
+// pbrMetallicRoughness material and the common extensions
+// KHR_materials_transmission and KHR_materials_ior
+type GLTFMetallicRoughness struct {
+	// BaseColorFactor is the linear RGBA base color, alpha is ignored
+	BaseColorFactor [4]float32
+	// BaseColorTexture is multiplied with BaseColorFactor if not nil
+	BaseColorTexture Texture
+	MetallicFactor   float32
+	RoughnessFactor  float32
+	// TransmissionFactor is the fraction of light transmitted
+	TransmissionFactor float32
+	// IOR defaults to 1.5 if zero
+	IOR float32
+}
+
+// DefaultGLTFMetallicRoughness returns the defaults of the glTF specification
+func DefaultGLTFMetallicRoughness() GLTFMetallicRoughness {
+	return GLTFMetallicRoughness{BaseColorFactor: [4]float32{1, 1, 1, 1}, MetallicFactor: 1, RoughnessFactor: 1, IOR: 1.5}
+}
+
+// Principled maps g to the parameters of the Principled material.
+// glTF derives the dielectric reflectance from the IOR, which maps to
+// Specular by F0 = 0.08 Specular.
+func (g GLTFMetallicRoughness) Principled() PrincipledParams {
+	p := DefaultPrincipledParams()
+	factor := NewColor(g.BaseColorFactor[0], g.BaseColorFactor[1], g.BaseColorFactor[2])
+	p.BaseColor = &SolidColor{color: factor}
+	if g.BaseColorTexture != nil {
+		p.BaseColor = &scaledTexture{texture: g.BaseColorTexture, scale: factor}
+	}
+	p.Metallic = g.MetallicFactor
+	p.Roughness = g.RoughnessFactor
+	p.Transmission = g.TransmissionFactor
+	p.SheenTint = 0
+	if g.IOR > 0 {
+		p.IOR = g.IOR
+	}
+	f0 := (p.IOR - 1) / (p.IOR + 1)
+	p.Specular = min(f0*f0/0.08, 1)
+	return p
+}
+
+// scaledTexture multiplies the color of a texture by a constant factor
+type scaledTexture struct {
+	texture Texture
+	scale   Color
+}
+
+// Value implements the Texture interface for scaledTexture
+func (s *scaledTexture) Value(u, v float32, p geo.Vec3) Color {
+	return s.texture.Value(u, v, p).MulVec(s.scale.Vec3)
+}
@@ -0,0 +1,115 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Quad is a parallelogram spanned by the edges u and v starting at corner q.
+// Its normal u x v follows the right hand rule.
+type Quad struct {
+	q, u, v  geo.Vec3
+	normal   geo.Vec3
+	d        float32
+	w        geo.Vec3
+	material Material
+}
+
+// NewQuad constructs a new Quad from a corner and two edge vectors
+func NewQuad(q, u, v geo.Vec3, m Material) *Quad {
+	n := u.Cross(v)
+	normal := n.Normed()
+	return &Quad{
+		q:        q,
+		u:        u,
+		v:        v,
+		normal:   normal,
+		d:        normal.Dot(q),
+		w:        n.Mul(1 / n.Dot(n)),
+		material: m,
+	}
+}
+
+// Hit implements the Hitable interface for Quad
+func (q *Quad) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	denom := q.normal.Dot(r.Dir())
+	if math32.Abs(denom) < 1e-8 {
+		return false
+	}
+	t := (q.d - q.normal.Dot(r.Orig())) / denom
+	if !(t > tMin && t < tMax) {
+		return false
+	}
+	p := r.At(t)
+	planar := p.Sub(q.q)
+	alpha := q.w.Dot(planar.Cross(q.v))
+	beta := q.w.Dot(q.u.Cross(planar))
+	if alpha < 0 || alpha > 1 || beta < 0 || beta > 1 {
+		return false
+	}
+	rec.t = t
+	rec.p = p
+	rec.normal = q.normal
+	rec.u, rec.v = alpha, beta
+	rec.tangent = q.u
+	rec.material = q.material
+	return true
+}
+
+// BoundingBox implements the Hitable interface for Quad
+func (q *Quad) BoundingBox() (bool, geo.Aabb) {
+	corners := [4]geo.Vec3{q.q, q.q.Add(q.u), q.q.Add(q.v), q.q.Add(q.u).Add(q.v)}
+	small, big := corners[0], corners[0]
+	for _, c := range corners[1:] {
+		small = geo.NewVec3(min(small.X(), c.X()), min(small.Y(), c.Y()), min(small.Z(), c.Z()))
+		big = geo.NewVec3(max(big.X(), c.X()), max(big.Y(), c.Y()), max(big.Z(), c.Z()))
+	}
+	return true, geo.NewAabb(small, big).Padded(rectPadding)
+}
+
+// PdfValue implements the Sampleable interface for Quad
+func (q *Quad) PdfValue(origin, dir geo.Vec3) float32 {
+	return areaPdf(q, q.u.Cross(q.v).Len(), q.normal, origin, dir)
+}
+
+// Random implements the Sampleable interface for Quad
+func (q *Quad) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	p := q.q.Add(q.u.Mul(rng.Float32())).Add(q.v.Mul(rng.Float32()))
+	return p.Sub(origin)
+}
+
+// Box is an axis aligned box made of six outward facing quads
+type Box struct {
+	min, max geo.Vec3
+	sides    HitableList
+}
+
+// NewBox constructs a new Box between the opposite corners a and b
+func NewBox(a, b geo.Vec3, m Material) *Box {
+	small := geo.NewVec3(min(a.X(), b.X()), min(a.Y(), b.Y()), min(a.Z(), b.Z()))
+	big := geo.NewVec3(max(a.X(), b.X()), max(a.Y(), b.Y()), max(a.Z(), b.Z()))
+	dx := geo.NewVec3(big.X()-small.X(), 0, 0)
+	dy := geo.NewVec3(0, big.Y()-small.Y(), 0)
+	dz := geo.NewVec3(0, 0, big.Z()-small.Z())
+	sides := HitableList{
+		NewQuad(geo.NewVec3(small.X(), small.Y(), big.Z()), dx, dy, m),       // front
+		NewQuad(geo.NewVec3(big.X(), small.Y(), big.Z()), dz.Neg(), dy, m),   // right
+		NewQuad(geo.NewVec3(big.X(), small.Y(), small.Z()), dx.Neg(), dy, m), // back
+		NewQuad(geo.NewVec3(small.X(), small.Y(), small.Z()), dz, dy, m),     // left
+		NewQuad(geo.NewVec3(small.X(), big.Y(), big.Z()), dx, dz.Neg(), m),   // top
+		NewQuad(geo.NewVec3(small.X(), small.Y(), small.Z()), dx, dz, m),     // bottom
+	}
+	return &Box{min: small, max: big, sides: sides}
+}
+
+// Hit implements the Hitable interface for Box
+func (b *Box) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	return b.sides.Hit(r, tMin, tMax, rec)
+}
+
+// BoundingBox implements the Hitable interface for Box
+func (b *Box) BoundingBox() (bool, geo.Aabb) {
+	return true, geo.NewAabb(b.min, b.max).Padded(rectPadding)
+}
@@ -0,0 +1,43 @@
+package tracer
+
+import "math/rand"
+
+// splitMix is a small, fast rand.Source64 which can be reseeded cheaply,
+// allowing every pixel to use its own random number stream
+type splitMix struct {
+	state uint64
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	return mix64(s.state)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// mix64 is the finalizer of SplitMix64, a bijective hash of x
+func mix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// NewRand returns a new random number generator which produces
+// the same sequence for the same seed
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(&splitMix{state: uint64(seed)})
+}
+
+// pixelSeed derives the seed of the random number stream of pixel x, y
+// so that it does not depend on which worker renders the pixel
+func pixelSeed(seed int64, x, y int) int64 {
+	h := mix64(uint64(seed) ^ 0x5851f42d4c957f2d)
+	h = mix64(h ^ uint64(uint32(y)))
+	return int64(mix64(h ^ uint64(uint32(x))<<32))
+}
@@ -0,0 +1,154 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// rectPadding is the thickness of the bounding boxes of flat shapes
+const rectPadding = 1e-4
+
+// XYRect is an axis aligned rectangle in the plane z = k
+// with its normal pointing towards +Z
+type XYRect struct {
+	x0, x1, y0, y1, k float32
+	material          Material
+}
+
+// NewXYRect constructs a new XYRect spanning x0..x1 and y0..y1
+func NewXYRect(x0, x1, y0, y1, k float32, m Material) *XYRect {
+	return &XYRect{x0, x1, y0, y1, k, m}
+}
+
+// Hit implements the Hitable interface for XYRect
+func (r *XYRect) Hit(ray *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	t := (r.k - ray.Orig().Z()) / ray.Dir().Z()
+	if !(t > tMin && t < tMax) {
+		return false
+	}
+	p := ray.At(t)
+	if p.X() < r.x0 || p.X() > r.x1 || p.Y() < r.y0 || p.Y() > r.y1 {
+		return false
+	}
+	rec.t = t
+	rec.p = p
+	rec.normal = geo.NewVec3(0, 0, 1)
+	rec.u = (p.X() - r.x0) / (r.x1 - r.x0)
+	rec.v = (p.Y() - r.y0) / (r.y1 - r.y0)
+	rec.tangent = geo.UnitX
+	rec.material = r.material
+	return true
+}
+
+// BoundingBox implements the Hitable interface for XYRect
+func (r *XYRect) BoundingBox() (bool, geo.Aabb) {
+	return true, geo.NewAabb(geo.NewVec3(r.x0, r.y0, r.k), geo.NewVec3(r.x1, r.y1, r.k)).Padded(rectPadding)
+}
+
+// XZRect is an axis aligned rectangle in the plane y = k
+// with its normal pointing towards +Y
+type XZRect struct {
+	x0, x1, z0, z1, k float32
+	material          Material
+}
+
+// NewXZRect constructs a new XZRect spanning x0..x1 and z0..z1
+func NewXZRect(x0, x1, z0, z1, k float32, m Material) *XZRect {
+	return &XZRect{x0, x1, z0, z1, k, m}
+}
+
+// Hit implements the Hitable interface for XZRect
+func (r *XZRect) Hit(ray *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	t := (r.k - ray.Orig().Y()) / ray.Dir().Y()
+	if !(t > tMin && t < tMax) {
+		return false
+	}
+	p := ray.At(t)
+	if p.X() < r.x0 || p.X() > r.x1 || p.Z() < r.z0 || p.Z() > r.z1 {
+		return false
+	}
+	rec.t = t
+	rec.p = p
+	rec.normal = geo.NewVec3(0, 1, 0)
+	rec.u = (p.X() - r.x0) / (r.x1 - r.x0)
+	rec.v = (p.Z() - r.z0) / (r.z1 - r.z0)
+	rec.tangent = geo.UnitX
+	rec.material = r.material
+	return true
+}
+
+// BoundingBox implements the Hitable interface for XZRect
+func (r *XZRect) BoundingBox() (bool, geo.Aabb) {
+	return true, geo.NewAabb(geo.NewVec3(r.x0, r.k, r.z0), geo.NewVec3(r.x1, r.k, r.z1)).Padded(rectPadding)
+}
+
+// YZRect is an axis aligned rectangle in the plane x = k
+// with its normal pointing towards +X
+type YZRect struct {
+	y0, y1, z0, z1, k float32
+	material          Material
+}
+
+// NewYZRect constructs a new YZRect spanning y0..y1 and z0..z1
+func NewYZRect(y0, y1, z0, z1, k float32, m Material) *YZRect {
+	return &YZRect{y0, y1, z0, z1, k, m}
+}
+
+// Hit implements the Hitable interface for YZRect
+func (r *YZRect) Hit(ray *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	t := (r.k - ray.Orig().X()) / ray.Dir().X()
+	if !(t > tMin && t < tMax) {
+		return false
+	}
+	p := ray.At(t)
+	if p.Y() < r.y0 || p.Y() > r.y1 || p.Z() < r.z0 || p.Z() > r.z1 {
+		return false
+	}
+	rec.t = t
+	rec.p = p
+	rec.normal = geo.NewVec3(1, 0, 0)
+	rec.u = (p.Y() - r.y0) / (r.y1 - r.y0)
+	rec.v = (p.Z() - r.z0) / (r.z1 - r.z0)
+	rec.tangent = geo.UnitY
+	rec.material = r.material
+	return true
+}
+
+// BoundingBox implements the Hitable interface for YZRect
+func (r *YZRect) BoundingBox() (bool, geo.Aabb) {
+	return true, geo.NewAabb(geo.NewVec3(r.k, r.y0, r.z0), geo.NewVec3(r.k, r.y1, r.z1)).Padded(rectPadding)
+}
+
+// PdfValue implements the Sampleable interface for XYRect
+func (r *XYRect) PdfValue(origin, dir geo.Vec3) float32 {
+	area := (r.x1 - r.x0) * (r.y1 - r.y0)
+	return areaPdf(r, area, geo.UnitZ, origin, dir)
+}
+
+// Random implements the Sampleable interface for XYRect
+func (r *XYRect) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	return geo.NewVec3(r.x0+rng.Float32()*(r.x1-r.x0), r.y0+rng.Float32()*(r.y1-r.y0), r.k).Sub(origin)
+}
+
+// PdfValue implements the Sampleable interface for XZRect
+func (r *XZRect) PdfValue(origin, dir geo.Vec3) float32 {
+	area := (r.x1 - r.x0) * (r.z1 - r.z0)
+	return areaPdf(r, area, geo.UnitY, origin, dir)
+}
+
+// Random implements the Sampleable interface for XZRect
+func (r *XZRect) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	return geo.NewVec3(r.x0+rng.Float32()*(r.x1-r.x0), r.k, r.z0+rng.Float32()*(r.z1-r.z0)).Sub(origin)
+}
+
+// PdfValue implements the Sampleable interface for YZRect
+func (r *YZRect) PdfValue(origin, dir geo.Vec3) float32 {
+	area := (r.y1 - r.y0) * (r.z1 - r.z0)
+	return areaPdf(r, area, geo.UnitX, origin, dir)
+}
+
+// Random implements the Sampleable interface for YZRect
+func (r *YZRect) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	return geo.NewVec3(r.k, r.y0+rng.Float32()*(r.y1-r.y0), r.z0+rng.Float32()*(r.z1-r.z0)).Sub(origin)
+}
@@ -0,0 +1,127 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// RenderOptions control resolution, quality and parallelism of Render
+type RenderOptions struct {
+	// Width and Height are the resolution of the resulting Film
+	Width, Height int
+	// Samples is the number of samples per pixel
+	Samples int
+	// MaxDepth limits the number of bounces of a path, defaults to 50
+	MaxDepth int
+	// Workers is the number of parallel renderers, defaults to the number of CPUs
+	Workers int
+	// BlockSize is the edge length of the square tiles handed to
+	// the workers, defaults to 50
+	BlockSize int
+	// Spectral renders with wavelengths instead of RGB colors, which is
+	// slower and noisier but shows the dispersion of dielectrics
+	Spectral bool
+	// Seed determines the random numbers used for sampling, the same seed
+	// gives the same image regardless of Workers and BlockSize
+	Seed int64
+}
+
+// DefaultRenderOptions returns the options used by cmd/tracer
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		Width:     600,
+		Height:    400,
+		Samples:   10,
+		MaxDepth:  50,
+		Workers:   runtime.NumCPU(),
+		BlockSize: 50,
+	}
+}
+
+func (o *RenderOptions) validate() error {
+	if o.Width <= 0 || o.Height <= 0 {
+		return errors.New("tracer: resolution must be positive")
+	}
+	if o.Samples <= 0 {
+		return errors.New("tracer: number of samples must be positive")
+	}
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = 50
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.BlockSize <= 0 {
+		o.BlockSize = 50
+	}
+	return nil
+}
+
+// Render renders scene as seen through camera into a new Film.
+// The image is split into square blocks which are rendered by
+// opts.Workers goroutines. If ctx is cancelled before all blocks
+// are done, Render stops early and returns the context's error.
+func Render(ctx context.Context, scene *Scene, camera Camera, opts RenderOptions) (*Film, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	film := NewFilm(opts.Width, opts.Height)
+	tracer := newPathTracer(scene, &opts)
+
+	wg := sync.WaitGroup{}
+	blockQueue := make(chan image.Rectangle)
+	for cpu := 0; cpu < opts.Workers; cpu++ {
+		wg.Add(1)
+		go func(queue <-chan image.Rectangle) {
+			defer wg.Done()
+			randGen := NewRand(opts.Seed)
+			for block := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				renderBlock(block, tracer, camera, film, &opts, randGen)
+			}
+		}(blockQueue)
+	}
+
+feed:
+	for x := 0; x < opts.Width; x += opts.BlockSize {
+		for y := 0; y < opts.Height; y += opts.BlockSize {
+			r := image.Rect(x, y, min(x+opts.BlockSize, opts.Width), min(y+opts.BlockSize, opts.Height))
+			select {
+			case blockQueue <- r:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(blockQueue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return film, nil
+}
+
+func renderBlock(block image.Rectangle, tracer *pathTracer, camera Camera, film *Film, opts *RenderOptions, randGen *rand.Rand) {
+	nx, ny, ns := opts.Width, opts.Height, opts.Samples
+	for y := block.Min.Y; y < block.Max.Y; y++ {
+		for x := block.Min.X; x < block.Max.X; x++ {
+			randGen.Seed(pixelSeed(opts.Seed, x, y))
+			col := NewColor(0, 0, 0)
+			for s := 0; s < ns; s++ {
+				u := (float32(x) + randGen.Float32()) / float32(nx)
+				v := (float32(ny-y) + randGen.Float32()) / float32(ny)
+				ray := camera.GetRay(u, v, randGen)
+				col = col.Add(tracer.colorAt(&ray, randGen))
+			}
+			col.Scale(1. / float32(ns))
+			film.Set(x, y, col)
+		}
+	}
+}
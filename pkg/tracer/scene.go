@@ -0,0 +1,23 @@
+package tracer
+
+// Scene holds everything a Renderer needs to know about
+// the world apart from the camera
+type Scene struct {
+	World Hitable
+	// Background is the light arriving along rays leaving the scene,
+	// if nil the DefaultSky is used. A SampleableEnvironment is also
+	// sampled like the Lights.
+	Background Environment
+	// Atmosphere is an optional medium filling the scene, like fog or haze
+	Atmosphere *Atmosphere
+	// Lights are sampled explicitly at every diffuse scattering event.
+	// The shapes of AreaLights must also be part of World to be visible.
+	Lights []Light
+}
+
+// NewScene constructs a Scene from a list of Hitables,
+// organizing them in a bounding volume hierarchy
+func NewScene(l HitableList) *Scene {
+	bvh := NewBvhNodeFromList(l)
+	return &Scene{World: &bvh}
+}
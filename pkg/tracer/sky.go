@@ -0,0 +1,206 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+const (
+	// sunAngularRadius is half the apparent diameter of the sun in radians
+	sunAngularRadius = 0.2665 * math32.Pi / 180
+	// sunLuminance is the luminance of the sun outside
+	// the atmosphere in kcd/m², the unit of the sky model
+	sunLuminance = 1.6e6
+	// skyScale converts kcd/m² to radiance. The sun at the zenith, which
+	// covers 6.8e-5 sr, then lights a white surface to a radiance of about
+	// 0.5 in a clear sky, to which the sky itself adds
+	skyScale = 0.02
+)
+
+// SunSky is the daylight sky of Preetham, Shirley and Smits, "A Practical
+// Analytic Model for Daylight", together with the disk of the sun.
+// Below the horizon it continues the sky at the horizon. The sun is
+// sampled explicitly, which gives sharp shadows without noise.
+type SunSky struct {
+	sun       geo.Vec3
+	turbidity float32
+	intensity float32
+	// zenith is the xyY color of the zenith
+	zenith [3]float32
+	// perez holds the coefficients A to E of the distributions
+	// of x, y and Y, divided by their value at the zenith
+	perez [3][5]float32
+	norm  [3]float32
+	// sunRadiance is the color of the sun disk, black below the horizon
+	sunRadiance Color
+	// sinHalfRadius is the sine of half the angular radius of the sun,
+	// from which directions inside the disk are computed accurately
+	sinHalfRadius float32
+}
+
+// NewSunSky creates a SunSky for the direction towards the sun and the
+// turbidity of the atmosphere, from 2 for a very clear to about 10 for a
+// hazy sky
+func NewSunSky(sun geo.Vec3, turbidity float32) *SunSky {
+	s := &SunSky{sun: sun.Normed(), turbidity: turbidity, intensity: 1}
+	s.sinHalfRadius = math32.Sin(sunAngularRadius / 2)
+	// The model only holds for the sun above the horizon
+	thetaS := math32.Acos(min(max(s.sun.Y(), 0), 1))
+	thetaS = min(thetaS, math32.Pi/2-0.01)
+	t := turbidity
+
+	chi := (4.0/9 - t/120) * (math32.Pi - 2*thetaS)
+	s.zenith[2] = (4.0453*t-4.9710)*math32.Tan(chi) - 0.2155*t + 2.4192
+	th2, th3 := thetaS*thetaS, thetaS*thetaS*thetaS
+	s.zenith[0] = t*t*(0.00166*th3-0.00375*th2+0.00209*thetaS) +
+		t*(-0.02903*th3+0.06377*th2-0.03202*thetaS+0.00394) +
+		(0.11693*th3 - 0.21196*th2 + 0.06052*thetaS + 0.25886)
+	s.zenith[1] = t*t*(0.00275*th3-0.00610*th2+0.00317*thetaS) +
+		t*(-0.04214*th3+0.08970*th2-0.04153*thetaS+0.00516) +
+		(0.15346*th3 - 0.26756*th2 + 0.06670*thetaS + 0.26688)
+
+	s.perez = [3][5]float32{
+		{-0.0193*t - 0.2592, -0.0665*t + 0.0008, -0.0004*t + 0.2125, -0.0641*t - 0.8989, -0.0033*t + 0.0452},
+		{-0.0167*t - 0.2608, -0.0950*t + 0.0092, -0.0079*t + 0.2102, -0.0441*t - 1.6537, -0.0109*t + 0.0529},
+		{0.1787*t - 1.4630, -0.3554*t + 0.4275, -0.0227*t + 5.3251, 0.1206*t - 2.5771, -0.0670*t + 0.3703},
+	}
+	for i := range s.norm {
+		s.norm[i] = s.zenith[i] / perez(s.perez[i], 0, thetaS)
+	}
+	if s.sun.Y() > 0 {
+		s.sunRadiance = sunTransmittance(s.sun.Y(), turbidity).Mul(sunLuminance)
+	}
+	return s
+}
+
+// SetIntensity scales the radiance of s by i
+func (s *SunSky) SetIntensity(i float32) {
+	s.intensity = i
+}
+
+// perez is the luminance distribution of the sky
+// relative to the sun at angle gamma
+func perez(c [5]float32, theta, gamma float32) float32 {
+	cosGamma := math32.Cos(gamma)
+	return (1 + c[0]*math32.Exp(c[1]/math32.Cos(theta))) *
+		(1 + c[2]*math32.Exp(c[3]*gamma) + c[4]*cosGamma*cosGamma)
+}
+
+// sunTransmittance is the fraction of sunlight at the red, green and blue
+// wavelengths which passes Rayleigh and aerosol scattering in the
+// atmosphere for the sun at cosine cosTheta from the zenith
+func sunTransmittance(cosTheta, turbidity float32) Color {
+	thetaDeg := math32.Acos(cosTheta) * 180 / math32.Pi
+	// Relative optical mass of the air the sunlight passes
+	m := 1 / (cosTheta + 0.15*math32.Pow(93.885-thetaDeg, -1.253))
+	beta := 0.04608*turbidity - 0.04586
+	var c [3]float32
+	for i, lambda := range [3]float32{0.61, 0.55, 0.465} {
+		rayleigh := 0.008735 * math32.Pow(lambda, -4.08)
+		aerosol := beta * math32.Pow(lambda, -1.3)
+		c[i] = math32.Exp(-m * (rayleigh + aerosol))
+	}
+	return NewColor(c[0], c[1], c[2])
+}
+
+// sky returns the radiance of the sky without the sun
+func (s *SunSky) sky(dir geo.Vec3) Color {
+	// Continue the horizon below it
+	cosTheta := max(dir.Y(), 0.001)
+	theta := math32.Acos(cosTheta)
+	gamma := math32.Acos(min(max(dir.Dot(s.sun), -1), 1))
+	var xyY [3]float32
+	for i := range xyY {
+		xyY[i] = s.norm[i] * perez(s.perez[i], theta, gamma)
+	}
+	x, y, lum := xyY[0], xyY[1], xyY[2]
+	c := xyzToSRGB(geo.NewVec3(x*lum/y, lum, (1-x-y)*lum/y))
+	return NewColor(max(c.R(), 0), max(c.G(), 0), max(c.B(), 0))
+}
+
+// inSun reports whether the unit direction dir hits the sun disk
+func (s *SunSky) inSun(dir geo.Vec3) bool {
+	// The chord between the directions is accurate for tiny angles
+	return dir.Sub(s.sun).LenSq() < 4*s.sinHalfRadius*s.sinHalfRadius
+}
+
+// Radiance implements the Environment interface for SunSky
+func (s *SunSky) Radiance(dir geo.Vec3) Color {
+	d := dir.Normed()
+	c := s.sky(d)
+	if s.inSun(d) {
+		c = c.Add(s.sunRadiance)
+	}
+	return c.Mul(skyScale * s.intensity)
+}
+
+// sunProbability is the chance of sampling the sun instead of the sky
+func (s *SunSky) sunProbability() float32 {
+	if s.sunRadiance == Black {
+		return 0
+	}
+	return 0.5
+}
+
+// PdfValue implements the SampleableEnvironment interface for SunSky
+func (s *SunSky) PdfValue(dir geo.Vec3) float32 {
+	p := s.sunProbability()
+	pdf := (1 - p) / (4 * math32.Pi)
+	if p > 0 && s.inSun(dir.Normed()) {
+		// The solid angle of a cone is 2π(1 - cos r) = 4π sin²(r/2)
+		solidAngle := 4 * math32.Pi * s.sinHalfRadius * s.sinHalfRadius
+		pdf += p / solidAngle
+	}
+	return pdf
+}
+
+// Random implements the SampleableEnvironment interface for SunSky,
+// choosing between the sun disk and the whole sky
+func (s *SunSky) Random(rng *rand.Rand) geo.Vec3 {
+	if rng.Float32() >= s.sunProbability() {
+		return randomUnitVector(rng)
+	}
+	// Uniform in solid angle: sin²(θ/2) is uniform up to sin²(radius/2)
+	halfTheta := math32.Asin(math32.Sqrt(rng.Float32()) * s.sinHalfRadius)
+	sinTheta, cosTheta := math32.Sincos(2 * halfTheta)
+	sinPhi, cosPhi := math32.Sincos(2 * math32.Pi * rng.Float32())
+	onb := geo.NewONB(s.sun)
+	return onb.Local(geo.NewVec3(sinTheta*cosPhi, sinTheta*sinPhi, cosTheta))
+}
+
+// SunDirection returns the unit direction towards the sun at elevation
+// above the horizon and azimuth clockwise from north in degrees. North
+// is the negative z axis and east the positive x axis.
+func SunDirection(elevation, azimuth float32) geo.Vec3 {
+	sinEl, cosEl := math32.Sincos(elevation * math32.Pi / 180)
+	sinAz, cosAz := math32.Sincos(azimuth * math32.Pi / 180)
+	return geo.NewVec3(cosEl*sinAz, sinEl, -cosEl*cosAz)
+}
+
+// SunPosition returns the elevation and azimuth of the sun in degrees as
+// used by SunDirection, seen from latitude and longitude in degrees
+// (north and east positive) at time t. It follows the NOAA approximation,
+// which is accurate to a fraction of a degree.
+func SunPosition(latitude, longitude float64, t time.Time) (elevation, azimuth float32) {
+	t = t.UTC()
+	hours := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	// Fractional year in radians
+	g := 2 * math.Pi / 365 * (float64(t.YearDay()-1) + (hours-12)/24)
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) -
+		0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) -
+		0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) -
+		0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+	solarMinutes := hours*60 + eqTime + 4*longitude
+	hourAngle := (solarMinutes/4 - 180) * math.Pi / 180
+	lat := latitude * math.Pi / 180
+	sinEl := math.Sin(lat)*math.Sin(decl) + math.Cos(lat)*math.Cos(decl)*math.Cos(hourAngle)
+	el := math.Asin(max(min(sinEl, 1), -1))
+	// Azimuth from south towards west, turned to start from north
+	az := math.Atan2(math.Sin(hourAngle), math.Cos(hourAngle)*math.Sin(lat)-math.Tan(decl)*math.Cos(lat)) + math.Pi
+	return float32(el * 180 / math.Pi), float32(az * 180 / math.Pi)
+}
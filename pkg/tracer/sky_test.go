@@ -0,0 +1,48 @@
+package tracer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// TestSunSkyPdf integrates over the sphere and the sun disk with
+// directions from Random weighted by 1/PdfValue, which only gives
+// their solid angles if PdfValue is the density of Random
+func TestSunSkyPdf(t *testing.T) {
+	s := NewSunSky(SunDirection(40, 120), 3)
+	rng := rand.New(rand.NewSource(1))
+	const n = 200000
+	var sphere, disk float64
+	for i := 0; i < n; i++ {
+		dir := s.Random(rng)
+		if l := dir.Len(); math32.Abs(l-1) > 1e-4 {
+			t.Fatalf("Random returned %v of length %g", dir, l)
+		}
+		pdf := float64(s.PdfValue(dir))
+		sphere += 1 / pdf
+		if s.inSun(dir) {
+			disk += 1 / pdf
+		}
+	}
+	sphere /= n
+	disk /= n
+	if want := 4 * math.Pi; math.Abs(sphere-want) > 0.01*want {
+		t.Errorf("sphere integrates to %g sr, want %g", sphere, want)
+	}
+	if want := 2 * math.Pi * (1 - math.Cos(float64(sunAngularRadius))); math.Abs(disk-want) > 0.01*want {
+		t.Errorf("sun disk integrates to %g sr, want %g", disk, want)
+	}
+}
+
+func TestSunSkyPdfWithoutSun(t *testing.T) {
+	s := NewSunSky(SunDirection(-10, 0), 3)
+	for _, dir := range []geo.Vec3{geo.UnitY, SunDirection(-10, 0)} {
+		if pdf, want := s.PdfValue(dir), 1/(4*math32.Pi); math32.Abs(pdf-want) > 1e-6 {
+			t.Errorf("PdfValue(%v) = %g below the horizon, want %g", dir, pdf, want)
+		}
+	}
+}
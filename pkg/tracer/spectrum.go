@@ -0,0 +1,151 @@
+package tracer
+
+import (
+	"math/rand"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Range of visible wavelengths in nm sampled by spectral rendering
+const (
+	lambdaMin = 380
+	lambdaMax = 780
+)
+
+// wavelengths are the wavelengths in nm a spectral path carries in the
+// three channels of its colors. The first is the hero wavelength, the
+// others are spread evenly over the visible range.
+type wavelengths [3]float32
+
+func sampleWavelengths(rng *rand.Rand) wavelengths {
+	const span = lambdaMax - lambdaMin
+	hero := rng.Float32() * span
+	var w wavelengths
+	for i := range w {
+		w[i] = lambdaMin + math32.Mod(hero+float32(i)*span/3, span)
+	}
+	return w
+}
+
+// spectrum upsamples the RGB color c to the spectral values at w.
+// A nil w stands for RGB rendering and returns c unchanged.
+func (w *wavelengths) spectrum(c Color) Color {
+	if w == nil {
+		return c
+	}
+	return NewColor(rgbToSpectrum(c, w[0]), rgbToSpectrum(c, w[1]), rgbToSpectrum(c, w[2]))
+}
+
+// rgb converts the spectral radiance at w to linear sRGB
+// by integrating it against the CIE color matching functions
+func (w *wavelengths) rgb(radiance Color) Color {
+	var xyz geo.Vec3
+	for i, l := range [3]float32{radiance.R(), radiance.G(), radiance.B()} {
+		if l != 0 {
+			xyz = xyz.Add(cieXYZ(w[i]).Mul(l))
+		}
+	}
+	// Uniformly sampled wavelengths have a density of 1 / (lambdaMax - lambdaMin)
+	return spectralBasis.xyzToRGB(xyz.Mul((lambdaMax - lambdaMin) / (3 * spectralBasis.yIntegral)))
+}
+
+// cieGaussian is the piecewise Gaussian of the analytic fit
+// of the CIE 1931 color matching functions
+func cieGaussian(lambda, mu, sigma1, sigma2 float32) float32 {
+	sigma := sigma2
+	if lambda < mu {
+		sigma = sigma1
+	}
+	t := (lambda - mu) / sigma
+	return math32.Exp(-0.5 * t * t)
+}
+
+// cieXYZ approximates the CIE 1931 color matching functions at lambda
+// following Wyman, Sloan and Shirley, "Simple Analytic Approximations
+// to the CIE XYZ Color Matching Functions"
+func cieXYZ(lambda float32) geo.Vec3 {
+	x := 1.056*cieGaussian(lambda, 599.8, 37.9, 31.0) + 0.362*cieGaussian(lambda, 442.0, 16.0, 26.7) -
+		0.065*cieGaussian(lambda, 501.1, 20.4, 26.2)
+	y := 0.821*cieGaussian(lambda, 568.8, 46.9, 40.5) + 0.286*cieGaussian(lambda, 530.9, 16.3, 31.1)
+	z := 1.217*cieGaussian(lambda, 437.0, 11.8, 36.0) + 0.681*cieGaussian(lambda, 459.0, 26.0, 13.8)
+	return geo.NewVec3(x, y, z)
+}
+
+// basisFunction is one of three smooth step spectra covering the long,
+// middle and short wavelengths, which add up to one everywhere
+func basisFunction(i int, lambda float32) float32 {
+	const width = 4
+	short := 1 / (1 + math32.Exp(-(lambda-485)/width))
+	long := 1 / (1 + math32.Exp(-(lambda-590)/width))
+	switch i {
+	case 0:
+		return long
+	case 1:
+		return short - long
+	}
+	return 1 - short
+}
+
+// spectralBasis holds the constants of the RGB to spectrum conversion
+var spectralBasis = newSpectralBasis()
+
+type spectralBasisConstants struct {
+	// yIntegral normalizes Y of a constant spectrum to one
+	yIntegral float32
+	// whiteX and whiteZ are X and Z of a constant spectrum
+	whiteX, whiteZ float32
+	// fromRGB maps a color to the weights of the basis functions
+	// whose spectrum converts back to the same color
+	fromRGB geo.Mat4
+}
+
+// xyzToRGB converts XYZ of the equal energy white point to linear sRGB,
+// adapting white so that a constant spectrum stays neutral
+func (b *spectralBasisConstants) xyzToRGB(xyz geo.Vec3) Color {
+	return xyzToSRGB(geo.NewVec3(xyz.X()*0.95047/b.whiteX, xyz.Y(), xyz.Z()*1.08883/b.whiteZ))
+}
+
+// xyzToSRGB converts XYZ of the D65 white point to linear sRGB
+func xyzToSRGB(xyz geo.Vec3) Color {
+	x, y, z := xyz.X(), xyz.Y(), xyz.Z()
+	return NewColor(
+		3.2404542*x-1.5371385*y-0.4985314*z,
+		-0.9692660*x+1.8760108*y+0.0415560*z,
+		0.0556434*x-0.2040259*y+1.0572252*z,
+	)
+}
+
+func newSpectralBasis() spectralBasisConstants {
+	var b spectralBasisConstants
+	var white geo.Vec3
+	for lambda := float32(lambdaMin); lambda <= lambdaMax; lambda++ {
+		white = white.Add(cieXYZ(lambda))
+	}
+	b.yIntegral, b.whiteX, b.whiteZ = white.Y(), white.X()/white.Y(), white.Z()/white.Y()
+	// Columns of toRGB are the colors of the basis functions
+	var toRGB [4][4]float32
+	toRGB[3][3] = 1
+	for i := 0; i < 3; i++ {
+		var xyz geo.Vec3
+		for lambda := float32(lambdaMin); lambda <= lambdaMax; lambda++ {
+			xyz = xyz.Add(cieXYZ(lambda).Mul(basisFunction(i, lambda)))
+		}
+		c := b.xyzToRGB(xyz.Mul(1 / b.yIntegral))
+		toRGB[0][i], toRGB[1][i], toRGB[2][i] = c.R(), c.G(), c.B()
+	}
+	fromRGB, ok := geo.NewMat4(toRGB).Inverse()
+	if !ok {
+		panic("tracer: singular spectral basis")
+	}
+	b.fromRGB = fromRGB
+	return b
+}
+
+// rgbToSpectrum returns the value at lambda of a smooth spectrum
+// which has the color c
+func rgbToSpectrum(c Color, lambda float32) float32 {
+	weights := spectralBasis.fromRGB.MulDir(c.Vec3)
+	s := weights.X()*basisFunction(0, lambda) + weights.Y()*basisFunction(1, lambda) + weights.Z()*basisFunction(2, lambda)
+	return max(s, 0)
+}
@@ -0,0 +1,118 @@
+package tracer
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// TestIORCurves compares the dispersion formulas with catalog
+// indices at the Fraunhofer d, F and C lines
+func TestIORCurves(t *testing.T) {
+	tests := []struct {
+		name   string
+		curve  IORCurve
+		lambda float32
+		want   float32
+	}{
+		{"BK7 d", SellmeierBK7, 587.6, 1.5168},
+		{"BK7 F", SellmeierBK7, 486.1, 1.5224},
+		{"BK7 C", SellmeierBK7, 656.3, 1.5143},
+		{"fused silica d", SellmeierFusedSilica, 587.6, 1.4585},
+		{"fused silica F", SellmeierFusedSilica, 486.1, 1.4632},
+		{"diamond", SellmeierDiamond, sodiumD, 2.4175},
+		{"cauchy", Cauchy{A: 1.5, B: 0.01}, 500, 1.54},
+	}
+	for _, tt := range tests {
+		if got := tt.curve.IOR(tt.lambda); !near(got, tt.want, 2e-4) {
+			t.Errorf("%s: IOR(%g) = %.5f, want %.4f", tt.name, tt.lambda, got, tt.want)
+		}
+	}
+	// The Abbe number (nd - 1) / (nF - nC) of BK7 is 64.17
+	nd, nF, nC := SellmeierBK7.IOR(587.6), SellmeierBK7.IOR(486.1), SellmeierBK7.IOR(656.3)
+	if v := (nd - 1) / (nF - nC); !near(v, 64.17, 0.01) {
+		t.Errorf("Abbe number of BK7 is %.2f, want 64.17", v)
+	}
+}
+
+func TestSampleWavelengths(t *testing.T) {
+	const span float32 = lambdaMax - lambdaMin
+	rng := rand.New(rand.NewSource(1))
+	const n = 100000
+	var bins [4]int
+	for i := 0; i < n; i++ {
+		w := sampleWavelengths(rng)
+		for j, l := range w {
+			if l < lambdaMin || l >= lambdaMax {
+				t.Fatalf("wavelength %g outside the visible range", l)
+			}
+			// The others follow the hero a third of the range apart
+			if next := w[(j+1)%3]; !near(math32.Mod(next-l+span, span), span/3, 1e-3) {
+				t.Fatalf("wavelengths %v are not evenly spread", w)
+			}
+		}
+		bins[int((w[0]-lambdaMin)/span*4)]++
+	}
+	// The hero wavelength is uniformly distributed
+	for i, b := range bins {
+		if f := float32(b) / n; math32.Abs(f-0.25) > 0.01 {
+			t.Errorf("hero wavelength falls into quarter %d of the range with probability %g", i, f)
+		}
+	}
+}
+
+// TestSpectrumRoundTrip checks that the spectrum of a color integrated
+// against the color matching functions over evenly spaced hero
+// wavelengths gives back the color, so that spectral rendering of a scene
+// without dispersion matches RGB rendering
+func TestSpectrumRoundTrip(t *testing.T) {
+	const span float32 = lambdaMax - lambdaMin
+	const n = 1000
+	for _, c := range []Color{NewColor(1, 1, 1), NewColor(0.2, 0.5, 0.8), NewColor(0.9, 0.3, 0.1), NewColor(0.05, 0.05, 0.05)} {
+		var sum geo.Vec3
+		for k := 0; k < n; k++ {
+			hero := (float32(k) + 0.5) * span / (3 * n)
+			w := wavelengths{lambdaMin + hero, lambdaMin + hero + span/3, lambdaMin + hero + 2*span/3}
+			sum = sum.Add(w.rgb(w.spectrum(c)).Vec3)
+		}
+		got := Color{sum.Mul(1.0 / n)}
+		if !near(got.R(), c.R(), 0.01) || !near(got.G(), c.G(), 0.01) || !near(got.B(), c.B(), 0.01) {
+			t.Errorf("%v comes back as %v", c, got)
+		}
+	}
+	if s := (*wavelengths)(nil).spectrum(NewColor(0.1, 0.2, 0.3)); s != NewColor(0.1, 0.2, 0.3) {
+		t.Errorf("RGB rendering changes colors to %v", s)
+	}
+}
+
+// TestDispersiveRefraction checks that a dispersive dielectric refracts
+// every wavelength by Snell's law with its own index of refraction
+func TestDispersiveRefraction(t *testing.T) {
+	d := NewDispersiveDielectric(SellmeierBK7)
+	const sinThetaI = 0.6
+	wo := geo.NewVec3(-sinThetaI, 0, 0.8)
+	rng := rand.New(rand.NewSource(1))
+	for _, lambda := range []float32{400, 486.1, sodiumD, 656.3, 750} {
+		rec := HitRecord{normal: geo.UnitZ, material: d, wavelength: lambda}
+		refracted := 0
+		for i := 0; i < 100 && refracted == 0; i++ {
+			s, ok := d.Sample(wo, &rec, rng)
+			if !ok || s.Dir.Z() > 0 {
+				continue
+			}
+			refracted++
+			if !s.Dispersive {
+				t.Errorf("refraction at %g nm is not dispersive", lambda)
+			}
+			sinThetaT := s.Dir.X() / s.Dir.Len()
+			if want := sinThetaI / SellmeierBK7.IOR(lambda); !near(sinThetaT, want, 1e-4) {
+				t.Errorf("at %g nm sin theta_t = %g, want %g", lambda, sinThetaT, want)
+			}
+		}
+		if refracted == 0 {
+			t.Errorf("no refraction at %g nm", lambda)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tracer
 
 import (
+	"math"
 	"math/rand"
 
 	"github.com/chewxy/math32"
@@ -21,10 +22,14 @@ func NewSphere(center geo.Vec3, r float32, m Material) *Sphere {
 
 // Hit calculates if geo.Ray r hits the sphere between tMin and tMax
 func (s *Sphere) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
-	oc := r.Orig().Sub(s.center)
+	return hitSphere(s.center, s.radius, s.material, r, tMin, tMax, rec)
+}
+
+func hitSphere(center geo.Vec3, radius float32, m Material, r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	oc := r.Orig().Sub(center)
 	a := r.LenSq()
 	b := r.Dir().Dot(oc)
-	c := oc.LenSq() - s.radius*s.radius
+	c := oc.LenSq() - radius*radius
 	discriminant := b*b - a*c
 	if discriminant > 0 {
 		sqrt := math32.Sqrt(discriminant)
@@ -33,8 +38,10 @@ func (s *Sphere) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
 			p := r.At(temp)
 			rec.t = temp
 			rec.p = p
-			rec.normal = p.Sub(s.center).Mul(1.0 / s.radius)
-			rec.material = s.material
+			rec.normal = p.Sub(center).Mul(1.0 / radius)
+			rec.u, rec.v = sphereUV(rec.normal)
+			rec.tangent = sphereTangent(rec.normal)
+			rec.material = m
 			return true
 		}
 		temp = (-b + sqrt) / a
@@ -42,19 +49,104 @@ func (s *Sphere) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
 			p := r.At(temp)
 			rec.t = temp
 			rec.p = p
-			rec.normal = p.Sub(s.center).Mul(1.0 / s.radius)
-			rec.material = s.material
+			rec.normal = p.Sub(center).Mul(1.0 / radius)
+			rec.u, rec.v = sphereUV(rec.normal)
+			rec.tangent = sphereTangent(rec.normal)
+			rec.material = m
 			return true
 		}
 	}
 	return false
 }
 
+// sphereUV maps a point on the unit sphere to u, v coordinates where u is
+// the angle around the Y axis starting at -X and v the angle from -Y to +Y
+func sphereUV(p geo.Vec3) (float32, float32) {
+	theta := math32.Acos(max(-1, min(1, -p.Y())))
+	phi := math32.Atan2(-p.Z(), p.X()) + math32.Pi
+	return phi / (2 * math32.Pi), theta / math32.Pi
+}
+
+// sphereTangent returns the direction in which u of sphereUV increases
+// at the point p on the unit sphere, which vanishes at the poles
+func sphereTangent(p geo.Vec3) geo.Vec3 {
+	return geo.NewVec3(p.Z(), 0, -p.X())
+}
+
 func (s *Sphere) BoundingBox() (bool, geo.Aabb) {
 	return true, *geo.NewAabb(s.center.Sub(geo.NewVec3(s.radius, s.radius, s.radius)),
 		s.center.Add(geo.NewVec3(s.radius, s.radius, s.radius)))
 }
 
+// PdfValue implements the Sampleable interface for Sphere
+func (s *Sphere) PdfValue(origin, dir geo.Vec3) float32 {
+	var rec HitRecord
+	r := geo.NewRay(origin, dir, 0)
+	if !s.Hit(&r, 0.001, math.MaxFloat32, &rec) {
+		return 0
+	}
+	distSq := s.center.Sub(origin).LenSq()
+	if distSq <= s.radius*s.radius {
+		return 1 / (4 * math32.Pi)
+	}
+	cosThetaMax := math32.Sqrt(1 - s.radius*s.radius/distSq)
+	return 1 / (2 * math32.Pi * (1 - cosThetaMax))
+}
+
+// Random implements the Sampleable interface for Sphere by choosing a
+// direction inside the cone of directions the sphere covers from origin.
+// From inside the sphere all directions are equally likely.
+func (s *Sphere) Random(origin geo.Vec3, rng *rand.Rand) geo.Vec3 {
+	direction := s.center.Sub(origin)
+	distSq := direction.LenSq()
+	if distSq <= s.radius*s.radius {
+		return randomUnitVector(rng)
+	}
+	onb := geo.NewONB(direction.Mul(1 / math32.Sqrt(distSq)))
+	cosThetaMax := math32.Sqrt(1 - s.radius*s.radius/distSq)
+	z := 1 + rng.Float32()*(cosThetaMax-1)
+	sinTheta := math32.Sqrt(max(0, 1-z*z))
+	phi := 2 * math32.Pi * rng.Float32()
+	return onb.Local(geo.NewVec3(sinTheta*math32.Cos(phi), sinTheta*math32.Sin(phi), z))
+}
+
+// MovingSphere is a sphere whose center moves linearly from center0
+// at time0 to center1 at time1
+type MovingSphere struct {
+	center0, center1 geo.Vec3
+	time0, time1     float32
+	radius           float32
+	material         Material
+}
+
+// NewMovingSphere constructs a new MovingSphere
+func NewMovingSphere(center0, center1 geo.Vec3, time0, time1 float32, r float32, m Material) *MovingSphere {
+	return &MovingSphere{center0, center1, time0, time1, r, m}
+}
+
+// Center returns the center of the sphere at time t, which is
+// clamped to the interval of the motion
+func (s *MovingSphere) Center(t float32) geo.Vec3 {
+	if s.time1 == s.time0 {
+		return s.center0
+	}
+	f := min(max((t-s.time0)/(s.time1-s.time0), 0), 1)
+	return s.center0.Add(s.center1.Sub(s.center0).Mul(f))
+}
+
+// Hit calculates if geo.Ray r hits the sphere at the time of the ray between tMin and tMax
+func (s *MovingSphere) Hit(r *geo.Ray, tMin, tMax float32, rec *HitRecord) bool {
+	return hitSphere(s.Center(r.Time()), s.radius, s.material, r, tMin, tMax, rec)
+}
+
+// BoundingBox returns a box enclosing the sphere during its whole motion
+func (s *MovingSphere) BoundingBox() (bool, geo.Aabb) {
+	radius := geo.NewVec3(s.radius, s.radius, s.radius)
+	box0 := geo.NewAabb(s.center0.Sub(radius), s.center0.Add(radius))
+	box1 := geo.NewAabb(s.center1.Sub(radius), s.center1.Add(radius))
+	return true, geo.SurroundingBox(*box0, *box1)
+}
+
 func RandomInUnitSphere(r *rand.Rand) geo.Vec3 {
 	vec := geo.NewVec3(1.0, 1.0, 1.0)
 	for vec.LenSq() >= 1.0 {
@@ -0,0 +1,155 @@
+package tracer
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG decoding for image textures
+	_ "image/png"  // register PNG decoding for image textures
+	"io/fs"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+// Texture is an interface to describe colors varying over a surface
+type Texture interface {
+	// Value returns the color at surface coordinates u, v and point p
+	Value(u, v float32, p geo.Vec3) Color
+}
+
+// SolidColor is a Texture with the same color everywhere
+type SolidColor struct {
+	color Color
+}
+
+// NewSolidColor creates a new SolidColor from r,g,b values
+func NewSolidColor(r, g, b float32) *SolidColor {
+	return &SolidColor{color: NewColor(r, g, b)}
+}
+
+// Value implements the Texture interface for SolidColor
+func (s *SolidColor) Value(u, v float32, p geo.Vec3) Color {
+	return s.color
+}
+
+// CheckerTexture alternates between two textures in a 3D checker pattern
+type CheckerTexture struct {
+	odd, even Texture
+	scale     float32
+}
+
+// NewCheckerTexture creates a new CheckerTexture with cells
+// of edge length 1/scale
+func NewCheckerTexture(odd, even Texture, scale float32) *CheckerTexture {
+	return &CheckerTexture{odd: odd, even: even, scale: scale}
+}
+
+// Value implements the Texture interface for CheckerTexture
+func (c *CheckerTexture) Value(u, v float32, p geo.Vec3) Color {
+	x := int(math32.Floor(c.scale * p.X()))
+	y := int(math32.Floor(c.scale * p.Y()))
+	z := int(math32.Floor(c.scale * p.Z()))
+	if (x+y+z)%2 == 0 {
+		return c.even.Value(u, v, p)
+	}
+	return c.odd.Value(u, v, p)
+}
+
+// WrapMode determines how texture coordinates outside of [0, 1] are treated
+type WrapMode uint8
+
+const (
+	// WrapRepeat tiles the texture
+	WrapRepeat WrapMode = iota
+	// WrapClamp repeats the border pixels
+	WrapClamp
+	// WrapMirror tiles the texture, mirroring every other tile
+	WrapMirror
+)
+
+// ImageTexture maps an image onto the u, v coordinates of a surface
+// using bilinear filtering
+type ImageTexture struct {
+	width, height int
+	pix           []Color
+	wrap          WrapMode
+}
+
+// NewImageTexture creates an ImageTexture from img, which is assumed to be sRGB encoded
+func NewImageTexture(img image.Image, wrap WrapMode) *ImageTexture {
+	bounds := img.Bounds()
+	t := &ImageTexture{width: bounds.Dx(), height: bounds.Dy(), pix: make([]Color, bounds.Dx()*bounds.Dy()), wrap: wrap}
+	for y := 0; y < t.height; y++ {
+		for x := 0; x < t.width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			t.pix[y*t.width+x] = NewColor(srgbToLinear(float32(r)/0xffff),
+				srgbToLinear(float32(g)/0xffff), srgbToLinear(float32(b)/0xffff))
+		}
+	}
+	return t
+}
+
+// LoadImageTexture decodes the PNG or JPEG file name from fsys into an ImageTexture
+func LoadImageTexture(fsys fs.FS, name string, wrap WrapMode) (*ImageTexture, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return NewImageTexture(img, wrap), nil
+}
+
+func srgbToLinear(c float32) float32 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math32.Pow((c+0.055)/1.055, 2.4)
+}
+
+func wrapIndex(i, n int, mode WrapMode) int {
+	switch mode {
+	case WrapClamp:
+		return min(max(i, 0), n-1)
+	case WrapMirror:
+		period := 2 * n
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - 1 - i
+		}
+		return i
+	}
+	i %= n
+	if i < 0 {
+		i += n
+	}
+	return i
+}
+
+func (t *ImageTexture) texel(x, y int) Color {
+	x = wrapIndex(x, t.width, t.wrap)
+	y = wrapIndex(y, t.height, t.wrap)
+	return t.pix[y*t.width+x]
+}
+
+// Value implements the Texture interface for ImageTexture,
+// v = 0 is the bottom row of the image
+func (t *ImageTexture) Value(u, v float32, p geo.Vec3) Color {
+	if t.width == 0 || t.height == 0 {
+		return Black
+	}
+	x := u*float32(t.width) - 0.5
+	y := (1-v)*float32(t.height) - 0.5
+	x0, y0 := math32.Floor(x), math32.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	top := t.texel(ix, iy).Mul(1 - fx).Add(t.texel(ix+1, iy).Mul(fx))
+	bottom := t.texel(ix, iy+1).Mul(1 - fx).Add(t.texel(ix+1, iy+1).Mul(fx))
+	return top.Mul(1 - fy).Add(bottom.Mul(fy))
+}
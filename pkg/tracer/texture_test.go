@@ -0,0 +1,147 @@
+package tracer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/chewxy/math32"
+	"github.com/robquant/tracer/pkg/geo"
+)
+
+func nearColor(a, b Color) bool {
+	return math32.Abs(a.R()-b.R()) < 1e-5 && math32.Abs(a.G()-b.G()) < 1e-5 && math32.Abs(a.B()-b.B()) < 1e-5
+}
+
+func TestCheckerTexture(t *testing.T) {
+	odd, even := NewSolidColor(1, 0, 0), NewSolidColor(0, 0, 1)
+	tests := []struct {
+		scale float32
+		p     geo.Vec3
+		want  *SolidColor
+	}{
+		{1, geo.NewVec3(0.5, 0.5, 0.5), even},
+		{1, geo.NewVec3(1.5, 0.5, 0.5), odd},
+		{1, geo.NewVec3(-0.5, 0.5, 0.5), odd},
+		{1, geo.NewVec3(-0.5, -0.5, 0.5), even},
+		{1, geo.NewVec3(-0.5, -0.5, -0.5), odd},
+		{1, geo.NewVec3(-1.5, 0.5, 0.5), even},
+		{2, geo.NewVec3(0.6, 0.1, 0.1), odd},
+		{2, geo.NewVec3(-0.6, 0.1, 0.1), even},
+	}
+	c := NewCheckerTexture(odd, even, 1)
+	for _, tt := range tests {
+		c.scale = tt.scale
+		if got, want := c.Value(0, 0, tt.p), tt.want.Value(0, 0, tt.p); got != want {
+			t.Errorf("scale %g at %v: got %v, want %v", tt.scale, tt.p, got, want)
+		}
+	}
+}
+
+func TestSrgbToLinear(t *testing.T) {
+	tests := []struct {
+		c, want float32
+	}{
+		{0, 0},
+		{0.02, 0.02 / 12.92},
+		{0.04045, 0.04045 / 12.92},
+		{0.5, 0.21404114},
+		{1, 1},
+	}
+	for _, tt := range tests {
+		if got := srgbToLinear(tt.c); !near(got, tt.want, 1e-5) {
+			t.Errorf("srgbToLinear(%g) = %g, want %g", tt.c, got, tt.want)
+		}
+	}
+	// Both segments meet at the cutoff
+	if below, above := srgbToLinear(0.04045), srgbToLinear(0.04046); !near(below, above, 1e-3) {
+		t.Errorf("srgbToLinear jumps from %g to %g at the cutoff", below, above)
+	}
+}
+
+func TestWrapIndex(t *testing.T) {
+	tests := []struct {
+		i    int
+		mode WrapMode
+		want int
+	}{
+		{0, WrapRepeat, 0},
+		{2, WrapRepeat, 0},
+		{-1, WrapRepeat, 1},
+		{-4, WrapRepeat, 0},
+		{0, WrapClamp, 0},
+		{2, WrapClamp, 1},
+		{-1, WrapClamp, 0},
+		{7, WrapClamp, 1},
+		{1, WrapMirror, 1},
+		{2, WrapMirror, 1},
+		{3, WrapMirror, 0},
+		{4, WrapMirror, 0},
+		{-1, WrapMirror, 0},
+		{-2, WrapMirror, 1},
+		{-3, WrapMirror, 1},
+	}
+	for _, tt := range tests {
+		if got := wrapIndex(tt.i, 2, tt.mode); got != tt.want {
+			t.Errorf("wrapIndex(%d, 2, %d) = %d, want %d", tt.i, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestImageTexture(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.RGBA{0, 255, 0, 255})
+	img.Set(0, 1, color.RGBA{0, 0, 255, 255})
+	img.Set(1, 1, color.RGBA{188, 188, 188, 255})
+	red, green, blue := NewColor(1, 0, 0), NewColor(0, 1, 0), NewColor(0, 0, 1)
+	g := srgbToLinear(188.0 / 255)
+	gray := NewColor(g, g, g)
+	mix := func(a, b Color) Color { return a.Add(b).Mul(0.5) }
+
+	tests := []struct {
+		name string
+		wrap WrapMode
+		u, v float32
+		want Color
+	}{
+		// Texel centers give the decoded texels, v = 0 is the bottom row
+		{"top left", WrapRepeat, 0.25, 0.75, red},
+		{"top right", WrapRepeat, 0.75, 0.75, green},
+		{"bottom left", WrapRepeat, 0.25, 0.25, blue},
+		{"bottom right", WrapRepeat, 0.75, 0.25, gray},
+		// Halfway between texel centers weights both equally
+		{"top middle", WrapRepeat, 0.5, 0.75, mix(red, green)},
+		{"left middle", WrapRepeat, 0.25, 0.5, mix(red, blue)},
+		{"center", WrapRepeat, 0.5, 0.5, mix(mix(red, green), mix(blue, gray))},
+		{"quarter", WrapRepeat, 0.375, 0.75, red.Mul(0.75).Add(green.Mul(0.25))},
+		// At the left edge the texel left of the image is the right one
+		// when repeating and the edge texel itself otherwise
+		{"repeat edge", WrapRepeat, 0, 0.75, mix(red, green)},
+		{"clamp edge", WrapClamp, 0, 0.75, red},
+		{"mirror edge", WrapMirror, 0, 0.75, red},
+		{"repeat beyond", WrapRepeat, 1.25, 0.75, red},
+		{"clamp beyond", WrapClamp, 1.25, 0.75, green},
+		{"mirror beyond", WrapMirror, 1.25, 0.75, green},
+		{"repeat before", WrapRepeat, -0.75, 0.75, red},
+		{"clamp before", WrapClamp, -0.75, 0.25, blue},
+		{"mirror before", WrapMirror, -0.75, 0.75, green},
+		{"mirror below", WrapMirror, 0.25, -0.25, blue},
+		{"clamp below", WrapClamp, 0.75, -3, gray},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tex := NewImageTexture(img, tt.wrap)
+			if got := tex.Value(tt.u, tt.v, geo.Vec3{}); !nearColor(got, tt.want) {
+				t.Errorf("Value(%g, %g) = %v, want %v", tt.u, tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageTextureEmpty(t *testing.T) {
+	tex := NewImageTexture(image.NewRGBA(image.Rect(0, 0, 0, 0)), WrapRepeat)
+	if got := tex.Value(0.5, 0.5, geo.Vec3{}); got != Black {
+		t.Errorf("empty image gives %v, want black", got)
+	}
+}
@@ -0,0 +1,101 @@
+package tracer
+
+import (
+	"fmt"
+
+	"github.com/chewxy/math32"
+)
+
+// ToneOperator maps scene referred radiance onto the displayable range [0, 1]
+type ToneOperator uint8
+
+const (
+	// ToneLinear clamps values above 1
+	ToneLinear ToneOperator = iota
+	// ToneReinhard maps x to x / (1 + x)
+	ToneReinhard
+	// ToneReinhardExtended is Reinhard's operator mapping the white point to 1
+	ToneReinhardExtended
+	// ToneACES is Narkowicz' fit of the ACES filmic curve
+	ToneACES
+	// ToneHable is John Hable's Uncharted 2 filmic curve
+	ToneHable
+)
+
+var toneOperatorNames = []string{"linear", "reinhard", "reinhard-extended", "aces", "hable"}
+
+func (t ToneOperator) String() string {
+	if int(t) < len(toneOperatorNames) {
+		return toneOperatorNames[t]
+	}
+	return fmt.Sprintf("ToneOperator(%d)", t)
+}
+
+// ParseToneOperator returns the ToneOperator with the given name, which is
+// one of linear, reinhard, reinhard-extended, aces and hable
+func ParseToneOperator(name string) (ToneOperator, error) {
+	for i, n := range toneOperatorNames {
+		if n == name {
+			return ToneOperator(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tone operator %q", name)
+}
+
+// DisplayTransform converts linear radiance into display values:
+// exposure, tone mapping, clamping and the sRGB transfer function
+type DisplayTransform struct {
+	// Exposure in stops, radiance is scaled by 2^Exposure
+	Exposure float32
+	// Operator is the tone mapping curve
+	Operator ToneOperator
+	// WhitePoint is the smallest radiance mapped to white by ToneReinhardExtended
+	// and ToneHable, zero selects 4 and 11.2 respectively
+	WhitePoint float32
+}
+
+// Apply returns the tone mapped, linear color of c in [0, 1]
+func (d DisplayTransform) Apply(c Color) Color {
+	scale := math32.Exp2(d.Exposure)
+	return NewColor(d.apply(c.R()*scale), d.apply(c.G()*scale), d.apply(c.B()*scale))
+}
+
+func (d DisplayTransform) apply(x float32) float32 {
+	if !(x > 0) {
+		// Also catches NaNs
+		return 0
+	}
+	switch d.Operator {
+	case ToneReinhard:
+		x = x / (1 + x)
+	case ToneReinhardExtended:
+		w := d.WhitePoint
+		if w <= 0 {
+			w = 4
+		}
+		x = x * (1 + x/(w*w)) / (1 + x)
+	case ToneACES:
+		x = (x * (2.51*x + 0.03)) / (x*(2.43*x+0.59) + 0.14)
+	case ToneHable:
+		w := d.WhitePoint
+		if w <= 0 {
+			w = 11.2
+		}
+		const exposureBias = 2
+		x = hable(exposureBias*x) / hable(w)
+	}
+	return min(max(x, 0), 1)
+}
+
+func hable(x float32) float32 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
+
+// LinearToSRGB applies the piecewise sRGB transfer function to c in [0, 1]
+func LinearToSRGB(c float32) float32 {
+	if c <= 0.0031308 {
+		return 12.92 * c
+	}
+	return 1.055*math32.Pow(c, 1/2.4) - 0.055
+}
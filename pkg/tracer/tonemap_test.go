@@ -0,0 +1,102 @@
+package tracer
+
+import (
+	"testing"
+
+	"github.com/chewxy/math32"
+)
+
+func TestParseToneOperator(t *testing.T) {
+	for _, op := range []ToneOperator{ToneLinear, ToneReinhard, ToneReinhardExtended, ToneACES, ToneHable} {
+		got, err := ParseToneOperator(op.String())
+		if err != nil || got != op {
+			t.Errorf("ParseToneOperator(%q) = %v, %v", op.String(), got, err)
+		}
+	}
+	for _, name := range []string{"", "ACES", "filmic", "reinhard_extended"} {
+		if _, err := ParseToneOperator(name); err == nil {
+			t.Errorf("ParseToneOperator(%q) accepted an unknown operator", name)
+		}
+	}
+}
+
+func TestDisplayTransformApply(t *testing.T) {
+	tests := []struct {
+		name string
+		d    DisplayTransform
+		x    float32
+		want float32
+	}{
+		{"linear", DisplayTransform{}, 0.5, 0.5},
+		{"linear clamps", DisplayTransform{}, 2, 1},
+		{"negative", DisplayTransform{}, -1, 0},
+		{"nan", DisplayTransform{Operator: ToneReinhard}, math32.NaN(), 0},
+		{"exposure", DisplayTransform{Exposure: 1}, 0.25, 0.5},
+		{"negative exposure", DisplayTransform{Exposure: -2}, 2, 0.5},
+		{"reinhard", DisplayTransform{Operator: ToneReinhard}, 1, 0.5},
+		{"reinhard 3", DisplayTransform{Operator: ToneReinhard}, 3, 0.75},
+		{"reinhard exposure", DisplayTransform{Operator: ToneReinhard, Exposure: 1}, 0.5, 0.5},
+		{"reinhard-extended white", DisplayTransform{Operator: ToneReinhardExtended}, 4, 1},
+		{"reinhard-extended custom white", DisplayTransform{Operator: ToneReinhardExtended, WhitePoint: 2}, 2, 1},
+		{"reinhard-extended", DisplayTransform{Operator: ToneReinhardExtended, WhitePoint: 2}, 1, 0.625},
+		{"aces", DisplayTransform{Operator: ToneACES}, 1, 2.54 / 3.16},
+		{"hable white", DisplayTransform{Operator: ToneHable}, 11.2 / 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.d.Apply(NewColor(tt.x, tt.x, tt.x))
+			for _, c := range []float32{got.R(), got.G(), got.B()} {
+				if !near(c, tt.want, 1e-5) {
+					t.Errorf("Apply(%g) = %g, want %g", tt.x, c, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestFilmicCurves checks that the filmic curves rise monotonically from
+// 0 and stay in [0, 1] over a wide range of radiance
+func TestFilmicCurves(t *testing.T) {
+	for _, op := range []ToneOperator{ToneReinhard, ToneReinhardExtended, ToneACES, ToneHable} {
+		d := DisplayTransform{Operator: op}
+		if y := d.apply(0); y != 0 {
+			t.Errorf("%v maps 0 to %g", op, y)
+		}
+		prev := float32(0)
+		for x := float32(1e-4); x < 1e4; x *= 1.05 {
+			y := d.apply(x)
+			if y < prev || y < 0 || y > 1 {
+				t.Errorf("%v maps %g to %g after %g", op, x, y, prev)
+				break
+			}
+			prev = y
+		}
+	}
+}
+
+func TestLinearToSRGB(t *testing.T) {
+	tests := []struct {
+		c, want float32
+	}{
+		{0, 0},
+		{0.001, 0.01292},
+		{0.0031308, 12.92 * 0.0031308},
+		{0.5, 0.7353570},
+		{1, 1},
+	}
+	for _, tt := range tests {
+		if got := LinearToSRGB(tt.c); !near(got, tt.want, 1e-5) {
+			t.Errorf("LinearToSRGB(%g) = %g, want %g", tt.c, got, tt.want)
+		}
+	}
+	// Both segments meet at the cutoff
+	if below, above := LinearToSRGB(0.0031308), LinearToSRGB(0.0031309); !near(below, above, 1e-3) {
+		t.Errorf("LinearToSRGB jumps from %g to %g at the cutoff", below, above)
+	}
+	// It inverts the decoding of sRGB textures
+	for _, c := range []float32{0.01, 0.2, 0.5, 0.9} {
+		if got := LinearToSRGB(srgbToLinear(c)); !near(got, c, 1e-4) {
+			t.Errorf("LinearToSRGB(srgbToLinear(%g)) = %g", c, got)
+		}
+	}
+}